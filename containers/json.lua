@@ -1,6 +1,18 @@
 -- example reporting script which demonstrates a custom
 -- done() function that prints results as JSON
 
+-- init sets the request method and a body of the given size, passed as script arguments
+-- after the URL, i.e: wrk -s json.lua <url> -- POST 1024. wrk calls it from wrk.init before
+-- formatting the request, so it must not call wrk.init itself
+init = function(args)
+   if args[1] ~= nil then
+      wrk.method = args[1]
+   end
+   if args[2] ~= nil and tonumber(args[2]) > 0 then
+      wrk.body = string.rep("x", tonumber(args[2]))
+   end
+end
+
 done = function(summary, latency, requests)
    io.stderr:write("{\n")
    io.stderr:write(string.format("\t\"requests\": %d,\n", summary.requests))
@@ -0,0 +1,30 @@
+-- pacing.lua extends recycle.lua waiting a think time plus a random jitter before each request of every
+-- connection, passed as the fourth and fifth script arguments in milliseconds, along with the jitter seed,
+-- i.e: wrk -s pacing.lua <url> -- GET 0 0 500 100 1234
+
+dofile("recycle.lua")
+
+local recycle_init = init
+local think_time = 0
+local jitter = 0
+local threads = 0
+
+setup = function(thread)
+   threads = threads + 1
+   thread:set("thread_id", threads)
+end
+
+init = function(args)
+   recycle_init(args)
+   think_time = tonumber(args[4]) or 0
+   jitter = tonumber(args[5]) or 0
+   -- Every thread gets its own jitter sequence
+   math.randomseed((tonumber(args[6]) or 0) + (thread_id or 0))
+end
+
+delay = function()
+   if jitter > 0 then
+      return think_time + math.random(0, jitter)
+   end
+   return think_time
+end
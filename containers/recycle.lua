@@ -0,0 +1,30 @@
+-- recycle.lua extends json.lua closing connections after a number of requests, passed as the
+-- third script argument, i.e: wrk -s recycle.lua <url> -- GET 0 100
+-- Requests are counted per thread, so connections serve that number of requests on average
+
+dofile("json.lua")
+
+local json_init = init
+local counter = 0
+local requests_per_connection = 0
+local default_request, close_request
+
+init = function(args)
+   json_init(args)
+   requests_per_connection = tonumber(args[3]) or 0
+   default_request = wrk.format()
+   local headers = {}
+   for k, v in pairs(wrk.headers) do
+      headers[k] = v
+   end
+   headers["Connection"] = "close"
+   close_request = wrk.format(nil, nil, headers)
+end
+
+request = function()
+   counter = counter + 1
+   if requests_per_connection > 0 and counter % requests_per_connection == 0 then
+      return close_request
+   end
+   return default_request
+end
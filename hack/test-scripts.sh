@@ -0,0 +1,46 @@
+#!/usr/bin/env bash
+# Smoke test of the wrk scripts of the client image: builds the image and runs each script against the
+# benchmark server, verifying they produce the JSON results parsed by ingress-perf
+set -euo pipefail
+
+ENGINE=${ENGINE:-podman}
+IMAGE=${IMAGE:-localhost/ingress-perf-client:test}
+SERVER_IMAGE=${SERVER_IMAGE:-quay.io/cloud-bulldozer/nginx:latest}
+SERVER=ingress-perf-scripts-test
+URL=http://localhost:8080/1024.html
+
+cleanup() {
+  ${ENGINE} rm -f ${SERVER} > /dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+${ENGINE} build -f containers/Containerfile -t ${IMAGE} ./containers
+${ENGINE} run -d --name ${SERVER} --network host ${SERVER_IMAGE} > /dev/null
+for _ in $(seq 30); do
+  if ${ENGINE} run --rm --network host ${IMAGE} curl -sf -o /dev/null ${URL}; then
+    break
+  fi
+  sleep 1
+done
+
+# run_script <script> <expected http errors: zero or any> <script arguments>
+run_script() {
+  local script=$1 errors=$2
+  shift 2
+  echo "Running ${script} -- $*"
+  # Results are printed as JSON to stderr
+  ${ENGINE} run --rm --network host ${IMAGE} timeout 60 wrk -t 1 -c 2 -d 3s -s ${script} ${URL} -- "$@" 2>&1 > /dev/null |
+    python3 -c '
+import json, sys
+result = json.load(sys.stdin)
+assert result["requests"] > 0, "no requests sent"
+assert sys.argv[1] == "any" or result["http_errors"] == 0, "unexpected HTTP errors"
+print("requests: %d, rps: %.0f, avg latency: %.0f us" % (result["requests"], result["rps"], result["avg_lat_us"]))
+' ${errors}
+}
+
+run_script json.lua zero GET 0
+# The static server rejects POST requests, only the request body handling is verified
+run_script json.lua any POST 1024
+run_script recycle.lua zero GET 0 10
+run_script pacing.lua zero GET 0 0 10 5 1234
@@ -0,0 +1,55 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Assets customizes the client and server pods, it applies to all the scenarios of a run
+type Assets struct {
+	Client PodOverrides `json:"client"`
+	Server PodOverrides `json:"server"`
+	// Ports replace the ports of the server service, routes target them by name or number
+	Ports []corev1.ServicePort `json:"ports"`
+}
+
+// PodOverrides replace the defaults of the benchmark pods, unset fields keep the defaults
+type PodOverrides struct {
+	// ServiceAccount of the pods, it must exist in the benchmark namespace
+	ServiceAccount string `json:"serviceAccount"`
+	// SecurityContext replaces the security context of the container
+	SecurityContext *corev1.SecurityContext `json:"securityContext"`
+	// PodSecurityContext sets the security context of the pod
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext"`
+	// HostAliases entries added to the hosts file of the pods, i.e: route hosts resolving to a router address
+	HostAliases []corev1.HostAlias `json:"hostAliases"`
+	// DNSPolicy and DNSConfig customize the resolver of the pods
+	DNSPolicy corev1.DNSPolicy     `json:"dnsPolicy"`
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig"`
+}
+
+// LoadAssets loads the assets configuration, fields follow the Kubernetes API conventions
+func LoadAssets(file string) (Assets, error) {
+	var assets Assets
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return assets, err
+	}
+	return assets, yaml.UnmarshalStrict(data, &assets)
+}
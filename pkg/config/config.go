@@ -15,7 +15,11 @@
 package config
 
 import (
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	yaml "gopkg.in/yaml.v3"
@@ -29,6 +33,11 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		RequestTimeout: time.Second,
 		Procs:          1,
 		Keepalive:      true,
+		// Flag tests whose samples deviate more than 10% from each other
+		VarianceThreshold: 0.1,
+		// Hung samples are restarted once, 5 minutes after their expected end
+		WatchdogMargin:  5 * time.Minute,
+		WatchdogRetries: 1,
 	}
 	if err := unmarshal(&defaultCfg); err != nil {
 		return err
@@ -42,8 +51,303 @@ func Load(cfg string) error {
 	if err != nil {
 		return err
 	}
-	data := yaml.NewDecoder(f)
+	defer f.Close()
+	return LoadReader(f)
+}
+
+// LoadReader loads the configuration from the given reader, it accepts YAML and JSON
+func LoadReader(r io.Reader) error {
+	Cfg = nil
+	data := yaml.NewDecoder(r)
 	data.KnownFields(true)
-	err = data.Decode(&Cfg)
-	return err
+	if err := data.Decode(&Cfg); err != nil {
+		return err
+	}
+	Cfg = expandTLSMatrix(Cfg)
+	Cfg = expandIngressControllers(Cfg)
+	Cfg = expandZoneComparison(Cfg)
+	Cfg = expandTimeoutSweep(Cfg)
+	for i, cfg := range Cfg {
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("invalid scenario %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.StickySessions && c.Termination == "passthrough" {
+		return fmt.Errorf("stickySessions is not supported with passthrough termination")
+	}
+	if c.StickySessions && (c.Tenants != 0 || c.SNIHosts != 0) {
+		return fmt.Errorf("stickySessions can't be combined with tenants or sniHosts")
+	}
+	switch c.AddressFamily {
+	case "", "ipv4", "ipv6":
+	default:
+		return fmt.Errorf("unsupported addressFamily %q, allowed values are ipv4 and ipv6", c.AddressFamily)
+	}
+	// Without SNI the router can't route passthrough connections
+	if c.AddressFamily != "" && c.Termination == "passthrough" {
+		return fmt.Errorf("addressFamily is not supported with passthrough termination")
+	}
+	if c.RouterAddress != "" && (c.AddressFamily != "" || c.Termination == "passthrough") {
+		return fmt.Errorf("routerAddress can't be combined with addressFamily or passthrough termination")
+	}
+	if c.RequestsPerConnection != 0 && (c.Tool != "wrk" || !c.Keepalive || c.RequestsPerConnection < 0) {
+		return fmt.Errorf("requestsPerConnection must be positive, it requires keepalive and it's only supported by wrk")
+	}
+	if c.ThinkTime < 0 || c.ThinkTimeJitter < 0 || (c.ThinkTimeJitter > 0 && c.ThinkTime == 0) {
+		return fmt.Errorf("thinkTime and thinkTimeJitter can't be negative, and thinkTimeJitter requires thinkTime")
+	}
+	if (c.Tool == "bulk" || c.Tool == "idle") && (len(c.RequestMix) > 0 || c.RequestRate != 0) {
+		return fmt.Errorf("requestMix and requestRate are not supported by %s", c.Tool)
+	}
+	// Idle connections are held for the whole sample
+	if c.Tool == "idle" && (c.HTTP2 || c.ResultInterval != 0) {
+		return fmt.Errorf("http2 and resultInterval are not supported by idle")
+	}
+	if c.ThinkTime > 0 && c.Tool != "wrk" {
+		return fmt.Errorf("thinkTime is only supported by wrk")
+	}
+	// The handshake probe is pinned as well, the load must run with the same TLS setup that is indexed
+	if (c.TLSVersion != "" || c.CipherSuites != "") && c.Tool != "bulk" {
+		return fmt.Errorf("tlsVersion, cipherSuites and tlsMatrix are only supported by bulk")
+	}
+	// hloader doesn't report the transferred bytes, the compression gains can't be measured with it
+	if c.AcceptEncoding != "" && c.Tool != "wrk" && c.Tool != "bulk" {
+		return fmt.Errorf("acceptEncoding is only supported by wrk and bulk")
+	}
+	if c.ProxyProtocol && c.Tool != "bulk" {
+		return fmt.Errorf("proxyProtocol is only supported by bulk")
+	}
+	if c.RequestHeaderSize != 0 && c.RequestHeaderCount == 0 {
+		return fmt.Errorf("requestHeaderSize requires requestHeaderCount")
+	}
+	if c.RateLimit != nil {
+		if c.RateLimit.ConcurrentTCP < 0 || c.RateLimit.RateHTTP < 0 || c.RateLimit.RateTCP < 0 {
+			return fmt.Errorf("rateLimit limits can't be negative")
+		}
+		if c.RateLimit.ConcurrentTCP == 0 && c.RateLimit.RateHTTP == 0 && c.RateLimit.RateTCP == 0 {
+			return fmt.Errorf("rateLimit requires concurrentTCP, rateHTTP or rateTCP")
+		}
+	}
+	if c.Certificate != nil {
+		if c.Termination != "edge" && c.Termination != "reencrypt" {
+			return fmt.Errorf("certificate is only supported with edge and reencrypt terminations")
+		}
+		if (c.Certificate.CACert == "") != (c.Certificate.CAKey == "") {
+			return fmt.Errorf("certificate caCert and caKey must be set together")
+		}
+		if c.Certificate.DestinationCA != "" && c.Termination != "reencrypt" {
+			return fmt.Errorf("certificate destinationCA requires the reencrypt termination")
+		}
+		switch c.Certificate.KeyType {
+		case "", "rsa":
+			if c.Certificate.KeySize != 0 && c.Certificate.KeySize != 2048 && c.Certificate.KeySize != 3072 && c.Certificate.KeySize != 4096 {
+				return fmt.Errorf("unsupported rsa certificate keySize %d, allowed values are 2048, 3072 and 4096", c.Certificate.KeySize)
+			}
+		case "ecdsa":
+			if c.Certificate.KeySize != 0 && c.Certificate.KeySize != 256 && c.Certificate.KeySize != 384 {
+				return fmt.Errorf("unsupported ecdsa certificate keySize %d, allowed values are 256 and 384", c.Certificate.KeySize)
+			}
+		default:
+			return fmt.Errorf("unsupported certificate keyType %q, allowed values are rsa and ecdsa", c.Certificate.KeyType)
+		}
+	}
+	if c.IPWhitelist < 0 || c.IPWhitelist > maxIPWhitelist {
+		return fmt.Errorf("ipWhitelist must be between 0 and %d", maxIPWhitelist)
+	}
+	if len(c.IPWhitelistSources) > 0 && c.IPWhitelist == 0 {
+		return fmt.Errorf("ipWhitelistSources requires ipWhitelist")
+	}
+	for _, cidr := range c.IPWhitelistSources {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid ipWhitelistSources CIDR %q: %w", cidr, err)
+		}
+	}
+	switch c.Balance {
+	case "", "roundrobin", "leastconn", "source", "random":
+	default:
+		return fmt.Errorf("unsupported balance %q, allowed values are roundrobin, leastconn, source and random", c.Balance)
+	}
+	if len(c.BackendWeights) > 0 {
+		// Routes support up to 3 alternate backends
+		if len(c.BackendWeights) < 2 || len(c.BackendWeights) > 4 {
+			return fmt.Errorf("backendWeights requires between 2 and 4 weights")
+		}
+		var total int
+		for _, w := range c.BackendWeights {
+			if w < 0 || w > 256 {
+				return fmt.Errorf("backendWeights must be between 0 and 256")
+			}
+			total += w
+		}
+		if total == 0 {
+			return fmt.Errorf("backendWeights requires a non-zero weight")
+		}
+	}
+	switch c.Aggregation {
+	case "", AggregationMean, AggregationMedian, AggregationTrimmedMean, AggregationBest:
+	default:
+		return fmt.Errorf("unsupported aggregation %q, allowed values are mean, median, trimmed-mean and best", c.Aggregation)
+	}
+	for k := range c.Labels {
+		if k == "" {
+			return fmt.Errorf("labels can't have an empty key")
+		}
+	}
+	labels := make(map[string]bool)
+	for _, t := range c.RequestMix {
+		if !strings.HasPrefix(t.Path, "/") {
+			return fmt.Errorf("requestMix paths must start with /")
+		}
+		if t.Weight <= 0 || t.BodySize < 0 {
+			return fmt.Errorf("requestMix weights must be positive and body sizes can't be negative")
+		}
+		if (t.Method != "" || t.BodySize != 0) && c.Tool == "hloader" {
+			return fmt.Errorf("requestMix methods and body sizes are not supported by hloader")
+		}
+		if labels[t.Label()] {
+			return fmt.Errorf("duplicated requestMix target %q, set distinct names", t.Label())
+		}
+		labels[t.Label()] = true
+	}
+	// Tenant routes are created once with the default ports
+	if c.TargetPort != "" && c.Tenants != 0 {
+		return fmt.Errorf("targetPort can't be combined with tenants")
+	}
+	if c.ResultInterval != 0 && (c.ResultInterval < time.Second || c.ResultInterval >= c.Duration) {
+		return fmt.Errorf("resultInterval must be at least 1s and shorter than the duration")
+	}
+	if c.ErrorCaptureThreshold < 0 {
+		return fmt.Errorf("errorCaptureThreshold can't be negative")
+	}
+	if c.VarianceThreshold < 0 {
+		return fmt.Errorf("varianceThreshold can't be negative")
+	}
+	if c.RouterStatsInterval != 0 && c.RouterStatsInterval < time.Second {
+		return fmt.Errorf("routerStatsInterval must be at least 1s")
+	}
+	if c.WatchdogMargin < 0 || c.WatchdogRetries < 0 {
+		return fmt.Errorf("watchdogMargin and watchdogRetries can't be negative")
+	}
+	switch c.Ingress {
+	case "", IngressRouter:
+	case IngressContour, IngressIstio:
+		// Envoy based ingresses serve the bundled server through their own resources rather than routes
+		if c.Termination != "http" {
+			return fmt.Errorf("ingress %s only supports the http termination", c.Ingress)
+		}
+		if c.Tenants > 0 || c.SNIHosts > 0 || c.RoutePropagation > 0 || c.RouterAddress != "" || c.AddressFamily != "" ||
+			c.IngressController != "" || c.DrainRouterNode != 0 || c.Tuning != "" || c.StickySessions || len(c.RouteAnnotations) > 0 ||
+			c.RateLimit != nil || c.Balance != "" || len(c.BackendWeights) > 0 || c.IPWhitelist > 0 || c.Certificate != nil ||
+			c.RouterStatsInterval != 0 {
+			return fmt.Errorf("ingress %s can't be combined with tenants, sniHosts, routePropagation, routerAddress, addressFamily, ingressControllers, drainRouterNode, tuningPatch, routerStatsInterval or route settings", c.Ingress)
+		}
+	default:
+		return fmt.Errorf("unsupported ingress %q, allowed values are router, contour and istio", c.Ingress)
+	}
+	// Only the benchmark route is served by the targeted IngressController, the rest of settings assume the default one
+	if c.IngressController != "" && (c.Tenants > 0 || c.SNIHosts > 0 || c.RoutePropagation > 0 || c.RouterAddress != "") {
+		return fmt.Errorf("ingressControllers can't be combined with tenants, sniHosts, routePropagation or routerAddress")
+	}
+	if c.SweepGroup > 0 && (c.ComparisonGroup > 0 || c.ClientPlacement != "") {
+		return fmt.Errorf("timeoutSweep can't be combined with ingressControllers or zoneComparison")
+	}
+	if c.SweepGroup > 0 && c.RouteTimeout <= 0 {
+		return fmt.Errorf("timeoutSweep timeouts must be positive")
+	}
+	if c.ClientPlacement != "" && c.ComparisonGroup > 0 {
+		return fmt.Errorf("zoneComparison can't be combined with ingressControllers")
+	}
+	if c.MaxErrorRatio < 0 || c.MaxErrorRatio > 1 {
+		return fmt.Errorf("maxErrorRatio must be between 0 and 1")
+	}
+	// The error ratio is evaluated with the interim results of each interval
+	if c.MaxErrorRatio != 0 && c.ResultInterval == 0 {
+		return fmt.Errorf("maxErrorRatio requires resultInterval")
+	}
+	return nil
+}
+
+// expandIngressControllers replaces the scenarios with ingressControllers by one scenario per IngressController,
+// scenarios expanded from the same one share the comparison group
+func expandIngressControllers(cfgs []Config) []Config {
+	var expanded []Config
+	var group int
+	for _, cfg := range cfgs {
+		if len(cfg.IngressControllers) == 0 {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		group++
+		for _, ic := range cfg.IngressControllers {
+			c := cfg
+			c.IngressControllers = nil
+			c.IngressController = ic
+			c.ComparisonGroup = group
+			expanded = append(expanded, c)
+		}
+	}
+	return expanded
+}
+
+// expandTimeoutSweep replaces the scenarios with a timeoutSweep by one scenario per route timeout,
+// scenarios expanded from the same one share the sweep group
+func expandTimeoutSweep(cfgs []Config) []Config {
+	var expanded []Config
+	var group int
+	for _, cfg := range cfgs {
+		if len(cfg.TimeoutSweep) == 0 {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		group++
+		for _, timeout := range cfg.TimeoutSweep {
+			c := cfg
+			c.TimeoutSweep = nil
+			c.RouteTimeout = timeout
+			c.SweepGroup = group
+			expanded = append(expanded, c)
+		}
+	}
+	return expanded
+}
+
+// expandZoneComparison replaces the scenarios with zoneComparison by a same-zone and a cross-zone scenario
+func expandZoneComparison(cfgs []Config) []Config {
+	var expanded []Config
+	for _, cfg := range cfgs {
+		if !cfg.ZoneComparison {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		for _, placement := range []string{SameZone, CrossZone} {
+			c := cfg
+			c.ZoneComparison = false
+			c.ClientPlacement = placement
+			expanded = append(expanded, c)
+		}
+	}
+	return expanded
+}
+
+// expandTLSMatrix replaces the scenarios with a TLS matrix by one scenario per matrix entry
+func expandTLSMatrix(cfgs []Config) []Config {
+	var expanded []Config
+	for _, cfg := range cfgs {
+		if len(cfg.TLSMatrix) == 0 {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		for _, profile := range cfg.TLSMatrix {
+			c := cfg
+			c.TLSMatrix = nil
+			c.TLSVersion = profile.Version
+			c.CipherSuites = profile.CipherSuites
+			expanded = append(expanded, c)
+		}
+	}
+	return expanded
 }
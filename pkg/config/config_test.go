@@ -0,0 +1,131 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scenario returns a minimal valid scenario using the given termination, tool and extra settings
+func scenario(termination, tool, extra string) string {
+	return `- termination: ` + termination + `
+  tool: ` + tool + `
+  connections: 10
+  samples: 1
+  duration: 30s
+  path: /1024.html
+  concurrency: 1
+  serverReplicas: 1
+` + extra
+}
+
+func TestExpandTLSMatrix(t *testing.T) {
+	cfgs := []Config{
+		{Termination: "http"},
+		{Termination: "edge", TLSMatrix: []TLSProfile{
+			{Version: "1.2", CipherSuites: "ECDHE-RSA-AES128-GCM-SHA256"},
+			{Version: "1.3", CipherSuites: "TLS_AES_128_GCM_SHA256"},
+		}},
+	}
+	want := []Config{
+		{Termination: "http"},
+		{Termination: "edge", TLSVersion: "1.2", CipherSuites: "ECDHE-RSA-AES128-GCM-SHA256"},
+		{Termination: "edge", TLSVersion: "1.3", CipherSuites: "TLS_AES_128_GCM_SHA256"},
+	}
+	if got := expandTLSMatrix(cfgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("expandTLSMatrix = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadReaderDefaults(t *testing.T) {
+	if err := LoadReader(strings.NewReader(scenario("http", "hloader", ""))); err != nil {
+		t.Fatal(err)
+	}
+	if len(Cfg) != 1 {
+		t.Fatalf("got %d scenarios, want 1", len(Cfg))
+	}
+	cfg := Cfg[0]
+	if cfg.RequestTimeout != time.Second || cfg.Procs != 1 || !cfg.Keepalive || cfg.WatchdogMargin != 5*time.Minute ||
+		cfg.WatchdogRetries != 1 || cfg.VarianceThreshold != 0.1 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadReaderTLSMatrix(t *testing.T) {
+	matrix := scenario("edge", "bulk", `  tlsMatrix:
+  - version: "1.2"
+    cipherSuites: ECDHE-RSA-AES128-GCM-SHA256
+  - version: "1.3"
+`)
+	if err := LoadReader(strings.NewReader(matrix)); err != nil {
+		t.Fatal(err)
+	}
+	if len(Cfg) != 2 {
+		t.Fatalf("got %d scenarios, want 2", len(Cfg))
+	}
+	for i, version := range []string{"1.2", "1.3"} {
+		if Cfg[i].TLSVersion != version || Cfg[i].TLSMatrix != nil {
+			t.Errorf("scenario %d: tlsVersion %q and tlsMatrix %v, want %q and no matrix", i+1, Cfg[i].TLSVersion, Cfg[i].TLSMatrix, version)
+		}
+	}
+	if err := LoadReader(strings.NewReader(scenario("edge", "wrk", "  tlsMatrix:\n  - version: \"1.2\"\n"))); err == nil {
+		t.Error("expected the tlsMatrix to be rejected with wrk")
+	}
+}
+
+func TestLoadReaderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		termination string
+		tool        string
+		extra       string
+		wantErr     string
+	}{
+		{"valid", "http", "hloader", "", ""},
+		{"tls with bulk", "edge", "bulk", "  tlsVersion: \"1.3\"\n", ""},
+		{"tls with hloader", "edge", "hloader", "  tlsVersion: \"1.3\"\n", "only supported by bulk"},
+		{"ciphers with wrk", "edge", "wrk", "  cipherSuites: AES128-SHA\n", "only supported by bulk"},
+		{"stickySessions with passthrough", "passthrough", "hloader", "  stickySessions: true\n", "not supported with passthrough"},
+		{"acceptEncoding with wrk", "http", "wrk", "  acceptEncoding: gzip\n", ""},
+		{"acceptEncoding with hloader", "http", "hloader", "  acceptEncoding: gzip\n", "only supported by wrk and bulk"},
+		{"requestHeaderSize without requestHeaderCount", "http", "hloader", "  requestHeaderSize: 100\n", "requestHeaderSize requires requestHeaderCount"},
+		{"proxyProtocol with bulk", "http", "bulk", "  proxyProtocol: true\n", ""},
+		{"proxyProtocol with hloader", "http", "hloader", "  proxyProtocol: true\n", "only supported by bulk"},
+		{"unsupported addressFamily", "http", "hloader", "  addressFamily: ipv5\n", "unsupported addressFamily"},
+		{"unsupported aggregation", "http", "hloader", "  aggregation: max\n", "unsupported aggregation"},
+		{"short resultInterval", "http", "hloader", "  resultInterval: 100ms\n", "resultInterval must be at least 1s"},
+		{"maxErrorRatio without resultInterval", "http", "hloader", "  maxErrorRatio: 0.1\n", "maxErrorRatio requires resultInterval"},
+		{"short routerStatsInterval", "http", "hloader", "  routerStatsInterval: 10ms\n", "routerStatsInterval must be at least 1s"},
+		{"negative watchdogRetries", "http", "hloader", "  watchdogRetries: -1\n", "can't be negative"},
+		{"unsupported ingress", "http", "hloader", "  ingress: nginx\n", "unsupported ingress"},
+		{"contour with edge", "edge", "hloader", "  ingress: contour\n", "only supports the http termination"},
+		{"contour with tenants", "http", "hloader", "  ingress: contour\n  tenants: 2\n", "can't be combined"},
+		{"unknown field", "http", "hloader", "  unknown: true\n", "field unknown not found"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := LoadReader(strings.NewReader(scenario(tc.termination, tc.tool, tc.extra)))
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("got error %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EstimatedDuration returns the expected wall-clock time of the scenario, it doesn't account for
+// the time spent reconciling resources. Route propagation scenarios only account for the delays
+func (c Config) EstimatedDuration() time.Duration {
+	if c.RoutePropagation > 0 {
+		return time.Duration(c.RoutePropagation) * c.Delay
+	}
+	return time.Duration(c.Samples) * (c.Duration + c.Delay)
+}
+
+// notes returns a short description of the optional settings of the scenario
+func (c Config) notes() string {
+	var notes []string
+	if c.Warmup {
+		notes = append(notes, "warmup")
+	}
+	if c.RoutePropagation > 0 {
+		notes = append(notes, fmt.Sprintf("routePropagation=%d", c.RoutePropagation))
+	}
+	if c.Tuning != "" {
+		notes = append(notes, "tuningPatch")
+	}
+	if c.HTTP2 {
+		notes = append(notes, "http2")
+	}
+	if !c.Keepalive {
+		notes = append(notes, "keepalive=false")
+	}
+	if c.TLSVersion != "" {
+		notes = append(notes, "tlsVersion="+c.TLSVersion)
+	}
+	if c.CipherSuites != "" {
+		notes = append(notes, "cipherSuites="+c.CipherSuites)
+	}
+	if c.BackgroundRoutes > 0 {
+		notes = append(notes, fmt.Sprintf("backgroundRoutes=%d", c.BackgroundRoutes))
+	}
+	if c.Tenants > 0 {
+		notes = append(notes, fmt.Sprintf("tenants=%d", c.Tenants))
+	}
+	if c.SNIHosts > 0 {
+		notes = append(notes, fmt.Sprintf("sniHosts=%d", c.SNIHosts))
+	}
+	if c.StickySessions {
+		notes = append(notes, "stickySessions")
+	}
+	if len(c.RouteAnnotations) > 0 {
+		notes = append(notes, fmt.Sprintf("routeAnnotations=%d", len(c.RouteAnnotations)))
+	}
+	if c.DrainRouterNode != 0 {
+		notes = append(notes, fmt.Sprintf("drainRouterNode=%v", c.DrainRouterNode))
+	}
+	return strings.Join(notes, " ")
+}
+
+// PrintPlan prints the given scenarios along with their estimated duration and the total
+func PrintPlan(out io.Writer, cfgs []Config) error {
+	var total time.Duration
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tTOOL\tTERMINATION\tPATH\tSERVERS\tCONCURRENCY\tPROCS\tCONNECTIONS\tSAMPLES\tDURATION\tDELAY\tESTIMATED\tNOTES")
+	for i, c := range cfgs {
+		estimated := c.EstimatedDuration()
+		total += estimated
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%v\t%v\t%v\t%s\n",
+			i+1, c.Tool, c.Termination, c.Path, c.ServerReplicas, c.Concurrency, c.Procs, c.Connections,
+			c.Samples, c.Duration, c.Delay, estimated, c.notes())
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d tests, estimated duration %v (excluding deployment and reconciliation)\n", len(cfgs), total)
+	return err
+}
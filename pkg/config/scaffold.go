@@ -0,0 +1,119 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Scaffolding profiles
+const (
+	ProfileSmoke  = "smoke"
+	ProfileMatrix = "matrix"
+	ProfileScale  = "scale"
+)
+
+var terminations = []string{"http", "edge", "reencrypt", "passthrough"}
+
+// scaffoldScenario is the subset of the configuration written by Scaffold, durations are
+// kept as strings so they're rendered in a human-readable way
+type scaffoldScenario struct {
+	Termination    string `yaml:"termination"`
+	Connections    int    `yaml:"connections"`
+	Samples        int    `yaml:"samples"`
+	Duration       string `yaml:"duration"`
+	Path           string `yaml:"path"`
+	Concurrency    int32  `yaml:"concurrency"`
+	Tool           string `yaml:"tool"`
+	ServerReplicas int32  `yaml:"serverReplicas"`
+	RequestTimeout string `yaml:"requestTimeout"`
+	Delay          string `yaml:"delay,omitempty"`
+	Procs          int    `yaml:"procs,omitempty"`
+	Warmup         bool   `yaml:"warmup,omitempty"`
+}
+
+// Scaffold generates a starter configuration for the given profile. The number of worker nodes
+// is used to suggest the number of clients and server replicas
+func Scaffold(profile, tool string, workers int) ([]byte, error) {
+	var scenarios []scaffoldScenario
+	if workers < 1 {
+		workers = 1
+	}
+	// Leave most of the workers for the server replicas, and use ~1 client per 4 workers
+	concurrency := int32(workers / 4)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	serverReplicas := int32(workers * 5)
+	if serverReplicas > 90 {
+		serverReplicas = 90
+	}
+	base := scaffoldScenario{
+		Termination:    "http",
+		Connections:    200,
+		Samples:        2,
+		Duration:       "2m",
+		Path:           "/1024.html",
+		Concurrency:    concurrency,
+		Tool:           tool,
+		ServerReplicas: serverReplicas,
+		RequestTimeout: "10s",
+		Delay:          "10s",
+		Procs:          2,
+	}
+	switch profile {
+	case ProfileSmoke:
+		s := base
+		s.Samples = 1
+		s.Duration = "30s"
+		s.Connections = 20
+		s.Delay = ""
+		scenarios = append(scenarios, s)
+	case ProfileMatrix:
+		warmup := base
+		warmup.Samples = 1
+		warmup.Duration = "1m"
+		warmup.Warmup = true
+		warmup.Delay = ""
+		scenarios = append(scenarios, warmup)
+		for _, t := range terminations {
+			s := base
+			s.Termination = t
+			scenarios = append(scenarios, s)
+		}
+	case ProfileScale:
+		for _, t := range terminations {
+			for c := int32(1); c <= concurrency*2; c *= 2 {
+				s := base
+				s.Termination = t
+				s.Concurrency = c
+				scenarios = append(scenarios, s)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown profile %q, allowed profiles are %s, %s and %s", profile, ProfileSmoke, ProfileMatrix, ProfileScale)
+	}
+	if _, ok := map[string]bool{"wrk": true, "hloader": true}[tool]; !ok {
+		return nil, fmt.Errorf("tool %v not supported", tool)
+	}
+	out, err := yaml.Marshal(scenarios)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("# Generated by ingress-perf init, profile %s, %d worker nodes\n", profile, workers)
+	return append([]byte(header), out...), nil
+}
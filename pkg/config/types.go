@@ -14,10 +14,40 @@
 
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 var Cfg []Config
 
+// Methods aggregating the samples of a scenario into its summary
+const (
+	AggregationMean        = "mean"
+	AggregationMedian      = "median"
+	AggregationTrimmedMean = "trimmed-mean"
+	AggregationBest        = "best"
+)
+
+// Placements of the client pods relative to the zones of the router pods
+const (
+	SameZone  = "same-zone"
+	CrossZone = "cross-zone"
+)
+
+// RouterAddressAuto discovers the router address from the endpoint publishing strategy
+const RouterAddressAuto = "auto"
+
+// Ingress implementations serving the benchmark traffic
+const (
+	IngressRouter  = "router"
+	IngressContour = "contour"
+	IngressIstio   = "istio"
+)
+
+// maxIPWhitelist bounds the CIDRs of the ip_whitelist annotation, so it fits in the annotations size limit
+const maxIPWhitelist = 10000
+
 type Config struct {
 	UUID string `json:"-"` // Remove field from json as is already present in Result
 	// Termination benchmark termination type: allowed values are http, edge, reencrypt and reencrypt
@@ -52,11 +82,207 @@ type Config struct {
 	Keepalive bool `yaml:"keepalive" json:"keepalive"`
 	// Use HTTP2 protocol, if possible
 	HTTP2 bool `yaml:"http2" json:"http2"`
+	// RequestsPerConnection closes keepalive connections after this number of requests, 0 means no limit
+	RequestsPerConnection int `yaml:"requestsPerConnection" json:"requestsPerConnection,omitempty"`
+	// ThinkTime delay of each connection before sending a request, plus a random jitter up to ThinkTimeJitter
+	ThinkTime       time.Duration `yaml:"thinkTime" json:"thinkTime,omitempty"`
+	ThinkTimeJitter time.Duration `yaml:"thinkTimeJitter" json:"thinkTimeJitter,omitempty"`
+	// BackgroundRoutes number of inert routes pre-created before running the scenario
+	BackgroundRoutes int `yaml:"backgroundRoutes" json:"backgroundRoutes"`
+	// Tenants number of namespaces populated with a server, service and routes
+	Tenants int `yaml:"tenants" json:"tenants,omitempty"`
+	// TenantSample number of tenants receiving traffic. Default is all of them
+	TenantSample int `yaml:"tenantSample" json:"tenantSample,omitempty"`
+	// RoutePropagation number of route propagation latency measurements. When set, the scenario measures
+	// the time a new route takes to serve requests instead of running the benchmark tool
+	RoutePropagation int `yaml:"routePropagation" json:"routePropagation,omitempty"`
+	// DrainRouterNode drains the node of one of the router pods once this time has elapsed since the sample start
+	DrainRouterNode time.Duration `yaml:"drainRouterNode" json:"drainRouterNode,omitempty"`
+	// TLSVersion pins the client TLS version, i.e: 1.2, 1.3
+	TLSVersion string `yaml:"tlsVersion" json:"tlsVersion,omitempty"`
+	// CipherSuites colon separated list of cipher suites used by the client
+	CipherSuites string `yaml:"cipherSuites" json:"cipherSuites,omitempty"`
+	// TLSMatrix expands the scenario into one scenario per TLS version and cipher suites combination
+	TLSMatrix []TLSProfile `yaml:"tlsMatrix" json:"-"`
+	// SNIHosts number of routes with distinct hostnames the client processes are distributed across
+	SNIHosts int `yaml:"sniHosts" json:"sniHosts,omitempty"`
+	// AcceptEncoding value of the Accept-Encoding request header, i.e: gzip, br
+	AcceptEncoding string `yaml:"acceptEncoding" json:"acceptEncoding,omitempty"`
+	// RequestHeaderCount number of extra headers sent in each request, response headers aren't covered
+	RequestHeaderCount int `yaml:"requestHeaderCount" json:"requestHeaderCount,omitempty"`
+	// RequestHeaderSize size in bytes of the value of each extra request header
+	RequestHeaderSize int `yaml:"requestHeaderSize" json:"requestHeaderSize,omitempty"`
+	// StickySessions enables cookie based session affinity, each client process carries its own session cookie
+	StickySessions bool `yaml:"stickySessions" json:"stickySessions"`
+	// AddressFamily forces the address family used to reach the routes: ipv4 or ipv6
+	AddressFamily string `yaml:"addressFamily" json:"addressFamily,omitempty"`
+	// Ingress implementation serving the benchmark traffic: router, the OpenShift router, contour, an HTTPProxy of the
+	// Contour Envoy, or istio, a Gateway and a VirtualService of the Istio ingress gateway. Default is router
+	Ingress string `yaml:"ingress" json:"ingress,omitempty"`
+	// RouterAddress clients connect to this router address, or to the one discovered with auto, instead of resolving
+	// the route host, which is sent in the Host header
+	RouterAddress string `yaml:"routerAddress" json:"routerAddress,omitempty"`
+	// ProxyProtocol clients send the PROXY protocol header, required when the router endpoint publishing
+	// strategy uses the PROXY protocol and there isn't a load balancer injecting it
+	ProxyProtocol bool `yaml:"proxyProtocol" json:"proxyProtocol"`
+	// RouteAnnotations annotations added to the benchmark routes, i.e: haproxy.router.openshift.io/timeout
+	RouteAnnotations map[string]string `yaml:"routeAnnotations" json:"routeAnnotations,omitempty"`
+	// RateLimit enables the HAProxy rate limiting of the benchmark routes
+	RateLimit *RateLimit `yaml:"rateLimit" json:"rateLimit,omitempty"`
+	// Certificate serves the benchmark routes with a certificate generated for the scenario instead of the
+	// default certificate of the IngressController. Only edge and reencrypt terminations
+	Certificate *Certificate `yaml:"certificate" json:"certificate,omitempty"`
+	// Balance load-balancing algorithm of the benchmark routes: roundrobin, leastconn, source or random
+	Balance string `yaml:"balance" json:"balance,omitempty"`
+	// IPWhitelist number of CIDRs of the ip_whitelist annotation of the benchmark routes, to measure the cost of
+	// large ACLs. The CIDRs don't match any client, they're followed by the ipWhitelistSources
+	IPWhitelist int `yaml:"ipWhitelist" json:"ipWhitelist,omitempty"`
+	// IPWhitelistSources CIDRs allowed by the ip_whitelist annotation, defaults to any IPv4 and IPv6 address
+	IPWhitelistSources []string `yaml:"ipWhitelistSources" json:"ipWhitelistSources,omitempty"`
+	// BackendWeights weights of the backends of the benchmark routes, the first one is the bundled server and the
+	// rest are alternate backends, i.e: [90, 10]
+	BackendWeights []int `yaml:"backendWeights" json:"backendWeights,omitempty"`
+	// ClientSpread spreads the client pods evenly across the eligible nodes, so every node runs at most one client
+	// pod more than the rest, increasing the diversity of source IPs
+	ClientSpread bool `yaml:"clientSpread" json:"clientSpread"`
+	// ResultInterval splits each sample into consecutive tool executions of this duration, whose results are collected
+	// as they complete, so a client pod failure only loses the interval in progress. Connections are re-established
+	// in each execution, which skews the results compared with a continuous run
+	ResultInterval time.Duration `yaml:"resultInterval" json:"resultInterval,omitempty"`
+	// MaxErrorRatio aborts the sample, which is flagged as failed, as soon as the ratio of HTTP errors and timeouts
+	// to requests of a client pod exceeds this value after any of its intervals. Requires resultInterval
+	MaxErrorRatio float64 `yaml:"maxErrorRatio" json:"maxErrorRatio,omitempty"`
+	// ErrorCaptureThreshold captures the router logs and the events of the sample window when the HTTP errors
+	// plus timeouts of a sample exceed this value
+	ErrorCaptureThreshold int64 `yaml:"errorCaptureThreshold" json:"errorCaptureThreshold,omitempty"`
+	// RouterStatsInterval samples the HAProxy runtime socket of the router pods, show info and show stat, at this
+	// interval during the samples, 0 disables it
+	RouterStatsInterval time.Duration `yaml:"routerStatsInterval" json:"routerStatsInterval,omitempty"`
+	// VarianceThreshold flags the test as unstable when the coefficient of variation of the RPS or P99 latency
+	// across its samples exceeds this value, 0 disables it
+	VarianceThreshold float64 `yaml:"varianceThreshold" json:"varianceThreshold,omitempty"`
+	// WatchdogMargin time a sample can exceed its expected duration before its hung client pods are restarted
+	// and the sample is retried or failed, 0 disables it
+	WatchdogMargin time.Duration `yaml:"watchdogMargin" json:"watchdogMargin,omitempty"`
+	// WatchdogRetries times a sample interrupted by the watchdog is retried before it's failed
+	WatchdogRetries int `yaml:"watchdogRetries" json:"watchdogRetries,omitempty"`
+	// FreshClients recreates the client pods before each sample, so samples start from cold connections and are
+	// independent of each other
+	FreshClients bool `yaml:"freshClients" json:"freshClients,omitempty"`
+	// StartBarrier delays the start of all the client processes of a sample until this time after they're dispatched,
+	// so all of them start generating load at the same instant
+	StartBarrier time.Duration `yaml:"startBarrier" json:"startBarrier,omitempty"`
+	// TargetPort service port targeted by the scenario route, by name or number. Defaults to http, or https
+	// for reencrypt and passthrough terminations
+	TargetPort string `yaml:"targetPort" json:"targetPort,omitempty"`
+	// Aggregation method of the samples in the scenario summary: mean, median, trimmed-mean or best. Default is mean
+	Aggregation string `yaml:"aggregation" json:"aggregation,omitempty"`
+	// Labels arbitrary labels of the scenario, indexed along with its results, i.e: profile: edge-scale
+	Labels map[string]string `yaml:"labels" json:"labels,omitempty"`
+	// RequestMix weighted request targets, client processes are distributed across them proportionally to their weights
+	RequestMix []RequestTarget `yaml:"requestMix" json:"requestMix,omitempty"`
+	// IngressControllers expands the scenario into one scenario per IngressController, executed back-to-back,
+	// whose results are compared at the end of the last one. The first IngressController is the baseline
+	IngressControllers []string `yaml:"ingressControllers" json:"-"`
+	// IngressController targeted by the scenario, set by the ingressControllers expansion
+	IngressController string `yaml:"-" json:"ingressController,omitempty"`
+	// ComparisonGroup identifies the scenarios expanded from the same ingressControllers scenario, set by the expansion
+	ComparisonGroup int `yaml:"-" json:"comparisonGroup,omitempty"`
+	// TimeoutSweep expands the scenario into one scenario per route timeout, whose error rates are compared at the end
+	// of the last one. Meant to run against a slow backend
+	TimeoutSweep []time.Duration `yaml:"timeoutSweep" json:"-"`
+	// RouteTimeout of the benchmark routes, set by the timeoutSweep expansion
+	RouteTimeout time.Duration `yaml:"-" json:"routeTimeout,omitempty"`
+	// SweepGroup identifies the scenarios expanded from the same timeoutSweep scenario, set by the expansion
+	SweepGroup int `yaml:"-" json:"sweepGroup,omitempty"`
+	// ZoneComparison expands the scenario into two scenarios, with the client pods pinned to the zones of the router
+	// pods and to the rest of zones respectively
+	ZoneComparison bool `yaml:"zoneComparison" json:"-"`
+	// ClientPlacement of the client pods relative to the router zones, set by the zoneComparison expansion
+	ClientPlacement string `yaml:"-" json:"clientPlacement,omitempty"`
+	// Headers extra request headers set by the runner in each client process, i.e: session cookies
+	Headers []string `yaml:"-" json:"-"`
+	// Method and BodySize of the requests, set by the runner in each client process from the request mix
+	Method   string `yaml:"-" json:"-"`
+	BodySize int    `yaml:"-" json:"-"`
+	// Seed of the randomized decisions of the client process, set by the runner
+	Seed int64 `yaml:"-" json:"-"`
+}
+
+// RateLimit limits of the route rate limiting annotations, applied per source IP
+type RateLimit struct {
+	// ConcurrentTCP number of concurrent TCP connections
+	ConcurrentTCP int `yaml:"concurrentTCP" json:"concurrentTCP,omitempty"`
+	// RateHTTP number of HTTP requests within a 3 seconds window
+	RateHTTP int `yaml:"rateHTTP" json:"rateHTTP,omitempty"`
+	// RateTCP number of TCP connections within a 3 seconds window
+	RateTCP int `yaml:"rateTCP" json:"rateTCP,omitempty"`
+}
+
+// Certificate of the benchmark routes, generated for the host of each route
+type Certificate struct {
+	// CACert and CAKey PEM files of the CA issuing the certificates, they're self-signed when not set
+	CACert string `yaml:"caCert" json:"caCert,omitempty"`
+	CAKey  string `yaml:"caKey" json:"caKey,omitempty"`
+	// KeyType of the certificates, rsa or ecdsa. Default is rsa
+	KeyType string `yaml:"keyType" json:"keyType,omitempty"`
+	// KeySize in bits, 2048, 3072 or 4096 for rsa keys and 256 or 384 for ecdsa keys, the curve size.
+	// Defaults are 2048 and 256
+	KeySize int `yaml:"keySize" json:"keySize,omitempty"`
+	// DestinationCA PEM file of the CA verifying the backend certificate of reencrypt routes, defaults to the CA
+	// of the bundled server certificate
+	DestinationCA string `yaml:"destinationCA" json:"destinationCA,omitempty"`
+}
+
+// RequestTarget is a request of a weighted request mix
+type RequestTarget struct {
+	// Name identifies the target in the results, defaults to the method and path
+	Name string `yaml:"name" json:"name,omitempty"`
+	// Path of the requests, i.e: /1024.html
+	Path string `yaml:"path" json:"path"`
+	// Method of the requests, default is GET
+	Method string `yaml:"method" json:"method,omitempty"`
+	// BodySize size in bytes of the request body
+	BodySize int `yaml:"bodySize" json:"bodySize,omitempty"`
+	// Weight relative share of client processes sending this request
+	Weight int `yaml:"weight" json:"weight"`
+}
+
+// Label returns the name of the target in the results
+func (t RequestTarget) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	method := t.Method
+	if method == "" {
+		method = "GET"
+	}
+	return fmt.Sprintf("%s %s", method, t.Path)
+}
+
+type TLSProfile struct {
+	Version      string `yaml:"version"`
+	CipherSuites string `yaml:"cipherSuites"`
 }
 
+// PrometheusQueries infrastructure metrics of each sample. ELAPSED is replaced by the sample duration,
+// ROUTES_NAMESPACE by the namespace of the benchmark routes and CLIENT_NAMESPACE by the one of the client pods.
+// Queries of the client pods are skipped when they don't run in the target cluster
 var PrometheusQueries = map[string]string{
 	"avg_cpu_usage_router_pods":           "avg(avg_over_time(sum(irate(container_cpu_usage_seconds_total{name!='', namespace='openshift-ingress', pod=~'router-default.+'}[2m])) by (pod)[ELAPSED:]))",
 	"avg_memory_usage_router_pods_bytes":  "avg(avg_over_time(sum(container_memory_working_set_bytes{name!='', namespace='openshift-ingress', pod=~'router-default.+'}) by (pod)[ELAPSED:]))",
 	"avg_cpu_usage_router_nodes":          "avg(avg_over_time(sum(irate(node_cpu_seconds_total{mode!~'idle|steal'}[2m]) and on (instance) label_replace(kube_pod_info{namespace='openshift-ingress'},'instance', '$1', 'node', '(.+)')) by (instance)[ELAPSED:]))",
 	"avg_memory_usage_router_nodes_bytes": "avg(avg_over_time(sum(node_memory_MemTotal_bytes-node_memory_MemAvailable_bytes and on (instance) label_replace(kube_pod_info{namespace='openshift-ingress'},'instance', '$1', 'node', '(.+)')) by (instance)[ELAPSED:]))",
+	// CPU seconds consumed by the router pods during the sample, the base of the efficiency metrics
+	"cpu_seconds_router_pods": "sum(increase(container_cpu_usage_seconds_total{name!='', namespace='openshift-ingress', pod=~'router-default.+'}[ELAPSED]))",
+	// Energy consumed by the router and client nodes from Kepler, the platform power when available or else the CPU package one
+	"energy_joules_router_nodes": "(sum(increase(kepler_node_platform_joules_total[ELAPSED]) and on (instance) label_replace(kube_pod_info{namespace='openshift-ingress', pod=~'router-default.+'},'instance', '$1', 'node', '(.+)')) or sum(increase(kepler_node_package_joules_total[ELAPSED]) and on (instance) label_replace(kube_pod_info{namespace='openshift-ingress', pod=~'router-default.+'},'instance', '$1', 'node', '(.+)')))",
+	"energy_joules_client_nodes": "(sum(increase(kepler_node_platform_joules_total[ELAPSED]) and on (instance) label_replace(kube_pod_info{namespace='CLIENT_NAMESPACE', pod=~'ingress-perf-client.+'},'instance', '$1', 'node', '(.+)')) or sum(increase(kepler_node_package_joules_total[ELAPSED]) and on (instance) label_replace(kube_pod_info{namespace='CLIENT_NAMESPACE', pod=~'ingress-perf-client.+'},'instance', '$1', 'node', '(.+)')))",
+	// Coefficient of variation of the requests received by each backend server, measures the backend distribution skew
+	"backend_requests_cv": "stddev(sum(increase(haproxy_server_http_responses_total{exported_namespace='ROUTES_NAMESPACE', route=~'nginx-.+'}[ELAPSED])) by (server)) / avg(sum(increase(haproxy_server_http_responses_total{exported_namespace='ROUTES_NAMESPACE', route=~'nginx-.+'}[ELAPSED])) by (server))",
+	// Ratio of the requests received by the busiest backend server to the average, 1 means an even distribution
+	"backend_requests_max_avg_ratio": "max(sum(increase(haproxy_server_http_responses_total{exported_namespace='ROUTES_NAMESPACE', route=~'nginx-.+'}[ELAPSED])) by (server)) / avg(sum(increase(haproxy_server_http_responses_total{exported_namespace='ROUTES_NAMESPACE', route=~'nginx-.+'}[ELAPSED])) by (server))",
+	// Client saturation: CPU utilization of the busiest client node and ratio of CPU throttled periods of the client pods
+	"max_cpu_utilization_client_nodes": "max(avg_over_time((1 - avg(irate(node_cpu_seconds_total{mode='idle'}[2m])) by (instance) and on (instance) label_replace(kube_pod_info{namespace='CLIENT_NAMESPACE', pod=~'ingress-perf-client.+'},'instance', '$1', 'node', '(.+)'))[ELAPSED:]))",
+	"cpu_throttled_ratio_client_pods":  "sum(increase(container_cpu_cfs_throttled_periods_total{namespace='CLIENT_NAMESPACE', container='ingress-perf-client'}[ELAPSED])) / sum(increase(container_cpu_cfs_periods_total{namespace='CLIENT_NAMESPACE', container='ingress-perf-client'}[ELAPSED]))",
 }
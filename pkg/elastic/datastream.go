@@ -0,0 +1,203 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/indexers"
+)
+
+// DataStream indexes documents in an append-only data stream. Documents get the @timestamp field required by
+// data streams, copied from their timestamp, and they're written with the create action, the only one allowed
+type DataStream struct {
+	server string
+	name   string
+}
+
+// NewDataStream returns an indexer of the given data stream, the data stream is created by Elasticsearch with
+// the first document when there's a matching data stream index template, i.e: the one created with EnsureTemplate
+func NewDataStream(server, name string) (*DataStream, error) {
+	ds := &DataStream{server: strings.TrimRight(server, "/"), name: name}
+	if err := request(http.MethodGet, ds.server, nil, nil); err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", server, err)
+	}
+	return ds, nil
+}
+
+// Index writes the documents with the bulk API. Documents already written by a previous attempt, which have the
+// same ID, are reported as existing rather than duplicated
+func (ds *DataStream) Index(documents []interface{}, opts indexers.IndexingOpts) (string, error) {
+	return bulk(ds.server, ds.name, "create", true, documents)
+}
+
+// documentID returns a stable ID built from the run, test and sample identifiers of the document, so indexing it
+// again, i.e. when retrying after a partial failure or flushing spilled documents, doesn't create duplicates.
+// Documents without identifiers get an ID derived from their content
+func documentID(doc map[string]interface{}, data []byte) string {
+	str := func(m map[string]interface{}, field string) string {
+		if v, ok := m[field].(string); ok {
+			return v
+		}
+		return ""
+	}
+	var id string
+	switch {
+	case doc["jobConfig"] != nil:
+		if jobConfig, ok := doc["jobConfig"].(map[string]interface{}); ok {
+			id = fmt.Sprintf("%s-%s", str(doc, "uuid"), str(jobConfig, "name"))
+		}
+	case doc["metricName"] != nil:
+		if labels, ok := doc["labels"].(map[string]interface{}); ok {
+			scope := str(labels, "sampleId")
+			if scope == "" {
+				scope = str(labels, "testId")
+			}
+			id = fmt.Sprintf("%s-%s", scope, str(doc, "metricName"))
+		}
+	case doc["comparison"] != nil:
+		id = fmt.Sprintf("%s-comparison-%v", str(doc, "uuid"), doc["comparisonGroup"])
+	case doc["sweep"] != nil:
+		id = fmt.Sprintf("%s-sweep-%v", str(doc, "uuid"), doc["sweepGroup"])
+	case doc["latencies_ms"] != nil:
+		id = str(doc, "testId") + "-propagation"
+	default:
+		id = str(doc, "sampleId")
+	}
+	if id != "" {
+		return id
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// bulkMaxBytes bounds the size of the bulk requests, larger batches of documents are split in several requests
+const bulkMaxBytes = 5 << 20
+
+// bulk writes the documents in the given index or data stream with the given bulk action. Data streams require
+// the @timestamp field, which is copied from the timestamp of the documents
+func bulk(server, name, action string, timestamp bool, documents []interface{}) (string, error) {
+	start := time.Now()
+	stats := make(map[string]int)
+	var body, item bytes.Buffer
+	for _, document := range documents {
+		j, err := json.Marshal(document)
+		if err != nil {
+			return "", fmt.Errorf("cannot encode document %v: %w", document, err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(j, &doc); err != nil {
+			return "", err
+		}
+		if timestamp {
+			if ts, ok := doc["timestamp"]; ok {
+				doc["@timestamp"] = ts
+			} else {
+				doc["@timestamp"] = start.UTC()
+			}
+		}
+		item.Reset()
+		meta := map[string]interface{}{action: map[string]string{"_index": name, "_id": documentID(doc, j)}}
+		for _, line := range []interface{}{meta, doc} {
+			data, err := json.Marshal(line)
+			if err != nil {
+				return "", err
+			}
+			item.Write(data)
+			item.WriteByte('\n')
+		}
+		if body.Len() > 0 && body.Len()+item.Len() > bulkMaxBytes {
+			if err := bulkRequest(server, &body, stats); err != nil {
+				return "", err
+			}
+			body.Reset()
+		}
+		body.Write(item.Bytes())
+	}
+	if body.Len() > 0 {
+		if err := bulkRequest(server, &body, stats); err != nil {
+			return "", err
+		}
+	}
+	var statString string
+	for stat, val := range stats {
+		statString += fmt.Sprintf(" %s=%d", stat, val)
+	}
+	return fmt.Sprintf("Indexing finished in %v:%v", time.Since(start).Truncate(time.Millisecond), statString), nil
+}
+
+// bulkRequest sends a bulk request and adds the result of its items to stats. Documents written by a previous
+// attempt are counted as existing, and any other failed item fails the request
+func bulkRequest(server string, body io.Reader, stats map[string]int) error {
+	req, err := http.NewRequest(http.MethodPost, server+"/_bulk", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode, body: data}
+	}
+	var bulk struct {
+		Items []map[string]struct {
+			Status int    `json:"status"`
+			Result string `json:"result"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &bulk); err != nil {
+		return err
+	}
+	var failed int
+	var firstErr string
+	for _, item := range bulk.Items {
+		for _, r := range item {
+			switch {
+			case r.Status == http.StatusConflict:
+				stats["existing"]++
+			case r.Status >= 300:
+				failed++
+				if firstErr == "" {
+					firstErr = fmt.Sprintf("%s: %s", r.Error.Type, r.Error.Reason)
+				}
+			default:
+				stats[r.Result]++
+			}
+		}
+	}
+	if firstErr != "" {
+		return fmt.Errorf("%d documents failed, first error %s", failed, firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDocumentID(t *testing.T) {
+	contentID := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"sample", `{"uuid": "run", "sampleId": "run-1-1"}`, "run-1-1"},
+		{"job summary", `{"uuid": "run", "jobConfig": {"name": "ingress-perf"}}`, "run-ingress-perf"},
+		{"sample metric", `{"metricName": "cpu", "labels": {"testId": "run-1", "sampleId": "run-1-2"}}`, "run-1-2-cpu"},
+		{"test metric", `{"metricName": "cpu", "labels": {"testId": "run-1"}}`, "run-1-cpu"},
+		{"comparison", `{"uuid": "run", "comparison": {}, "comparisonGroup": 2}`, "run-comparison-2"},
+		{"sweep", `{"uuid": "run", "sweep": {}, "sweepGroup": 1}`, "run-sweep-1"},
+		{"propagation", `{"testId": "run-1", "latencies_ms": [1, 2]}`, "run-1-propagation"},
+		{"without identifiers", `{"rps": 1}`, contentID(`{"rps": 1}`)},
+		{"metric without labels", `{"metricName": "cpu"}`, contentID(`{"metricName": "cpu"}`)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := documentID(doc, []byte(tc.doc)); got != tc.want {
+				t.Errorf("documentID = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBulkChunks(t *testing.T) {
+	var requests, items int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var lines int
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, bulkMaxBytes)
+		for scanner.Scan() {
+			lines++
+		}
+		if r.ContentLength > bulkMaxBytes {
+			t.Errorf("bulk request of %d bytes exceeds %d", r.ContentLength, bulkMaxBytes)
+		}
+		var resp []string
+		for i := 0; i < lines/2; i++ {
+			resp = append(resp, `{"create":{"status":201,"result":"created"}}`)
+		}
+		items += lines / 2
+		fmt.Fprintf(w, `{"items":[%s]}`, strings.Join(resp, ","))
+	}))
+	defer server.Close()
+	var documents []interface{}
+	for i := 0; i < 5; i++ {
+		documents = append(documents, map[string]interface{}{"sampleId": fmt.Sprint(i), "payload": strings.Repeat("x", bulkMaxBytes/3)})
+	}
+	msg, err := bulk(server.URL, "ingress-performance", "create", true, documents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || items != len(documents) {
+		t.Errorf("got %d requests with %d documents, want 3 requests with %d documents", requests, items, len(documents))
+	}
+	if !strings.Contains(msg, "created=5") {
+		t.Errorf("unexpected indexing stats: %s", msg)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloud-bulldozer/go-commons/indexers"
+)
+
+// Index indexes documents in a regular index, documents with the same ID are overwritten
+type Index struct {
+	server string
+	name   string
+}
+
+// NewIndex returns an indexer of the given index, which is created by Elasticsearch with the first document
+func NewIndex(server, name string) (*Index, error) {
+	idx := &Index{server: strings.TrimRight(server, "/"), name: strings.ToLower(name)}
+	if err := request(http.MethodGet, idx.server, nil, nil); err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", server, err)
+	}
+	return idx, nil
+}
+
+// Index writes the documents with the bulk API, documents indexed by a previous attempt are updated
+func (idx *Index) Index(documents []interface{}, opts indexers.IndexingOpts) (string, error) {
+	return bulk(idx.server, idx.name, "index", false, documents)
+}
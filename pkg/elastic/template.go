@@ -0,0 +1,244 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// client sends the Elasticsearch requests, the server certificate is verified unless InsecureSkipVerify is set
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// InsecureSkipVerify disables the verification of the Elasticsearch server certificate, i.e. for self-signed ones
+func InsecureSkipVerify(skip bool) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: skip}
+	client.Transport = transport
+}
+
+// keywords are the string fields used in filters and aggregations
+var keywords = []string{
+	"uuid", "version", "pod", "node", "instanceType", "platform", "clusterType", "ocpVersion", "ocpMajorVersion",
+	"k8sVersion", "sdnType", "masterNodesType", "workerNodesType", "infraNodesType", "haproxyVersion",
+	"endpointPublishingStrategy", "namespace", "configHash", "clientImageDigests", "serverImageDigests", "testId",
+	"sampleId", "metricName", "jobName", "query", "ingressController", "baselineIngressController",
+	"routerZones", "clientZones", "externalClients", "service", "keyType", "certificateKey",
+	"ingress", "ingressVersion",
+}
+
+// doubles are the latency and throughput fields, which must not be mapped as long when the first value is integral
+var doubles = []string{
+	"total_avg_rps", "rps", "rps_stdev", "stdev_lat", "avg_lat_us", "max_lat_us", "p90_lat_us", "p95_lat_us", "p99_lat_us",
+	"avg_bytes_per_request", "avg_handshake_us", "recovery_time_s", "latencies_ms", "avg_propagation_ms",
+	"p50_propagation_ms", "p95_propagation_ms", "p99_propagation_ms", "max_propagation_ms", "value", "elapsedTime",
+	"rps_delta_pct", "avg_lat_delta_pct", "p99_lat_delta_pct", "total_throughput_bps",
+	"router_memory_per_connection_bytes", "error_ratio",
+	"limit_rps", "accepted_rps", "accuracy", "expected_share", "observed_share", "max_deviation",
+	"rps_per_router_core", "router_cpu_ms_per_1k_requests", "joules_per_million_requests",
+	"router_joules_per_million_requests", "low", "high", "level",
+}
+
+// mappings returns the field mappings of the result documents. Nested objects, like pods or tenants, share
+// the field names of the result, so the same types are applied at any depth through dynamic templates
+func mappings() map[string]interface{} {
+	templates := []map[string]interface{}{
+		{"infra_metrics": map[string]interface{}{
+			"path_match": "infra_metrics.*",
+			"mapping":    map[string]string{"type": "double"},
+		}},
+		{"labels": map[string]interface{}{
+			"path_match":         "labels.*",
+			"match_mapping_type": "string",
+			"mapping":            map[string]string{"type": "keyword"},
+		}},
+		{"config_strings": map[string]interface{}{
+			"path_match":         "config.*",
+			"match_mapping_type": "string",
+			"mapping":            map[string]string{"type": "keyword"},
+		}},
+	}
+	for _, field := range doubles {
+		templates = append(templates, map[string]interface{}{field: map[string]interface{}{
+			"match":   field,
+			"mapping": map[string]string{"type": "double"},
+		}})
+	}
+	for _, field := range keywords {
+		templates = append(templates, map[string]interface{}{field: map[string]interface{}{
+			"match":   field,
+			"mapping": map[string]string{"type": "keyword"},
+		}})
+	}
+	return map[string]interface{}{
+		"_meta":             map[string]int{"schemaVersion": tools.SchemaVersion},
+		"dynamic_templates": templates,
+		"properties": map[string]interface{}{
+			"timestamp":     map[string]string{"type": "date"},
+			"@timestamp":    map[string]string{"type": "date"},
+			"schemaVersion": map[string]string{"type": "integer"},
+			"endTimestamp":  map[string]string{"type": "date"},
+			"drain": map[string]interface{}{
+				"properties": map[string]interface{}{"timestamp": map[string]string{"type": "date"}},
+			},
+		},
+	}
+}
+
+// EnsureTemplate creates or updates the index template of the given index, so the result documents get the right
+// field mappings instead of the dynamic ones. When retention is set, an ILM policy deleting the indices older than
+// it is also created and referenced by the template. Templates only apply to indices created afterwards, so the
+// mappings of an existing index are verified and any mismatch is reported. With dataStream, the template creates
+// a data stream named after the index, whose documents are routed by their @timestamp field
+func EnsureTemplate(server, index string, retention time.Duration, dataStream bool) error {
+	server = strings.TrimRight(server, "/")
+	settings := map[string]interface{}{}
+	if retention > 0 {
+		policy := map[string]interface{}{
+			"policy": map[string]interface{}{
+				"phases": map[string]interface{}{
+					"hot": map[string]interface{}{"actions": map[string]interface{}{}},
+					"delete": map[string]interface{}{
+						"min_age": fmt.Sprintf("%ds", int64(retention.Seconds())),
+						"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+					},
+				},
+			},
+		}
+		if err := request(http.MethodPut, fmt.Sprintf("%s/_ilm/policy/%s", server, index), policy, nil); err != nil {
+			return fmt.Errorf("error creating ILM policy: %w", err)
+		}
+		settings["index.lifecycle.name"] = index
+		log.Infof("ILM policy %s deletes indices older than %v", index, retention)
+	}
+	var existing struct {
+		IndexTemplates []struct {
+			IndexTemplate struct {
+				Version int `json:"version"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	err := request(http.MethodGet, fmt.Sprintf("%s/_index_template/%s", server, index), nil, &existing)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("error getting index template: %w", err)
+	}
+	// Templates are stamped with the schema version, so they're only updated when it changes or ILM is enabled
+	if err != nil || retention > 0 || len(existing.IndexTemplates) == 0 || existing.IndexTemplates[0].IndexTemplate.Version != tools.SchemaVersion {
+		template := map[string]interface{}{
+			"index_patterns": []string{index, index + "-*"},
+			"version":        tools.SchemaVersion,
+			"priority":       100,
+			"template": map[string]interface{}{
+				"settings": settings,
+				"mappings": mappings(),
+			},
+		}
+		if dataStream {
+			template["data_stream"] = map[string]interface{}{}
+		}
+		if err := request(http.MethodPut, fmt.Sprintf("%s/_index_template/%s", server, index), template, nil); err != nil {
+			return fmt.Errorf("error creating index template: %w", err)
+		}
+		log.Infof("Index template %s created with schema version %d", index, tools.SchemaVersion)
+	}
+	return verifyMappings(server, index)
+}
+
+// verifyMappings warns about the fields of an existing index whose mapping differs from the template
+func verifyMappings(server, index string) error {
+	var indices map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := request(http.MethodGet, fmt.Sprintf("%s/%s/_mapping", server, index), nil, &indices); err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting index mappings: %w", err)
+	}
+	expected := map[string]string{"timestamp": "date"}
+	for _, field := range doubles {
+		expected[field] = "double"
+	}
+	for _, field := range keywords {
+		expected[field] = "keyword"
+	}
+	for name, idx := range indices {
+		for field, mapping := range idx.Mappings.Properties {
+			if want, ok := expected[field]; ok && mapping.Type != want {
+				log.Warnf("Field %s of index %s is mapped as %s instead of %s, reindex it or roll over to a new index to apply the template", field, name, mapping.Type, want)
+			}
+		}
+	}
+	return nil
+}
+
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.code, e.body)
+}
+
+func isNotFound(err error) bool {
+	se, ok := err.(*statusError)
+	return ok && se.code == http.StatusNotFound
+}
+
+func request(method, url string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode, body: data}
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Datasource plugin types supported by the generated dashboard
+const (
+	DatasourceElasticsearch = "elasticsearch"
+	DatasourceOpenSearch    = "grafana-opensearch-datasource"
+)
+
+// panelQuery filters the result documents of the selected runs and terminations, kube-burner
+// documents don't have the config field so they're left out
+const panelQuery = "uuid:$uuid AND config.termination:$termination"
+
+// Dashboard returns a Grafana dashboard displaying the results indexed in the given index. Panels use the
+// datasource selected in the datasource variable, which defaults to the given datasource name
+func Dashboard(datasource, datasourceType, index string) ([]byte, error) {
+	switch datasourceType {
+	case DatasourceElasticsearch, DatasourceOpenSearch:
+	default:
+		return nil, fmt.Errorf("unsupported datasource type %q, allowed types are %s and %s", datasourceType, DatasourceElasticsearch, DatasourceOpenSearch)
+	}
+	ds := map[string]string{"type": datasourceType, "uid": "${datasource}"}
+	panels := []map[string]interface{}{
+		timeseries(1, "RPS", "reqps", 0, ds, metric("1", "total_avg_rps", "")),
+		timeseries(2, "Latency percentiles", "µs", 12, ds,
+			metric("1", "avg_lat_us", ""),
+			metric("4", "p90_lat_us", ""),
+			metric("5", "p95_lat_us", ""),
+			metric("6", "p99_lat_us", ""),
+		),
+		timeseries(3, "Router pods CPU", "none", 0, ds, metric("1", "infra_metrics.avg_cpu_usage_router_pods", "")),
+		timeseries(4, "Router pods memory", "bytes", 12, ds, metric("1", "infra_metrics.avg_memory_usage_router_pods_bytes", "")),
+		summaryTable(5, ds),
+	}
+	dashboard := map[string]interface{}{
+		"title":         "Ingress performance",
+		"description":   fmt.Sprintf("ingress-perf results indexed in %s", index),
+		"tags":          []string{"ingress-perf"},
+		"editable":      true,
+		"schemaVersion": 36,
+		"time":          map[string]string{"from": "now-30d", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":    "datasource",
+					"label":   "Datasource",
+					"type":    "datasource",
+					"query":   datasourceType,
+					"current": map[string]string{"text": datasource, "value": datasource},
+				},
+				termsVariable("uuid", "UUID", "uuid", "", ds),
+				termsVariable("termination", "Termination", "config.termination", "uuid:$uuid", ds),
+			},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+func termsVariable(name, label, field, query string, ds map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"label":      label,
+		"type":       "query",
+		"datasource": ds,
+		"query":      fmt.Sprintf(`{"find": "terms", "field": %q, "query": %q}`, field, query),
+		"refresh":    2, // On time range change
+		"multi":      true,
+		"includeAll": true,
+		"sort":       1,
+	}
+}
+
+func metric(id, field, metricType string) map[string]interface{} {
+	if metricType == "" {
+		metricType = "avg"
+	}
+	return map[string]interface{}{"id": id, "type": metricType, "field": field}
+}
+
+// timeseries returns a half width panel with the given metrics of each termination over time
+func timeseries(id int, title, unit string, x int, ds map[string]string, metrics ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"title":      title,
+		"type":       "timeseries",
+		"datasource": ds,
+		"gridPos":    map[string]int{"h": 9, "w": 12, "x": x, "y": (id - 1) / 2 * 9},
+		"fieldConfig": map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"unit":   unit,
+				"custom": map[string]interface{}{"drawStyle": "line", "showPoints": "always", "spanNulls": true},
+			},
+		},
+		"targets": []map[string]interface{}{{
+			"refId":      "A",
+			"datasource": ds,
+			"query":      panelQuery,
+			"timeField":  "timestamp",
+			"metrics":    metrics,
+			"bucketAggs": []map[string]interface{}{
+				{"id": "2", "type": "terms", "field": "config.termination", "settings": map[string]string{"size": "10", "order": "asc", "orderBy": "_term", "min_doc_count": "1"}},
+				{"id": "3", "type": "date_histogram", "field": "timestamp", "settings": map[string]string{"interval": "auto", "min_doc_count": "1"}},
+			},
+		}},
+	}
+}
+
+// summaryTable returns a full width table with the averages of each run and termination
+func summaryTable(id int, ds map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"title":      "Summary by uuid and termination",
+		"type":       "table",
+		"datasource": ds,
+		"gridPos":    map[string]int{"h": 10, "w": 24, "x": 0, "y": 18},
+		"targets": []map[string]interface{}{{
+			"refId":      "A",
+			"datasource": ds,
+			"query":      panelQuery,
+			"timeField":  "timestamp",
+			"metrics": []map[string]interface{}{
+				metric("1", "total_avg_rps", ""),
+				metric("4", "avg_lat_us", ""),
+				metric("5", "p95_lat_us", ""),
+				metric("6", "p99_lat_us", ""),
+				metric("7", "http_errors", "sum"),
+				metric("8", "timeouts", "sum"),
+			},
+			"bucketAggs": []map[string]interface{}{
+				{"id": "2", "type": "terms", "field": "uuid", "settings": map[string]string{"size": "0", "order": "desc", "orderBy": "_term", "min_doc_count": "1"}},
+				{"id": "3", "type": "terms", "field": "config.termination", "settings": map[string]string{"size": "10", "order": "asc", "orderBy": "_term", "min_doc_count": "1"}},
+			},
+		}},
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package horreum
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// Config of the Horreum instance where runs are uploaded
+type Config struct {
+	// URL of the Horreum instance, i.e: https://horreum.example.com
+	URL string
+	// Test name of the Horreum test the runs are uploaded to
+	Test string
+	// Schema URI stamped in the $schema field of the uploaded runs, Horreum extracts the labels used in change
+	// detection through the schema
+	Schema string
+	// Owner team role owning the uploaded runs, i.e: perf-team
+	Owner string
+	// Access of the uploaded runs: PUBLIC, PROTECTED or PRIVATE
+	Access string
+	// Token Horreum API key
+	Token string
+}
+
+// Run is the document uploaded to Horreum, one per ingress-perf run
+type Run struct {
+	Schema   string                `json:"$schema"`
+	UUID     string                `json:"uuid"`
+	Version  string                `json:"version"`
+	Start    time.Time             `json:"start"`
+	Stop     time.Time             `json:"stop"`
+	Passed   bool                  `json:"passed"`
+	Metadata tools.ClusterMetadata `json:"metadata"`
+	Tests    []tools.TestSummary   `json:"tests"`
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Upload posts the run to Horreum and returns the id of the Horreum run
+func Upload(cfg Config, run Run) (string, error) {
+	run.Schema = cfg.Schema
+	body, err := json.Marshal(run)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("test", cfg.Test)
+	params.Set("start", fmt.Sprint(run.Start.UnixMilli()))
+	params.Set("stop", fmt.Sprint(run.Stop.UnixMilli()))
+	params.Set("owner", cfg.Owner)
+	params.Set("access", cfg.Access)
+	params.Set("schema", cfg.Schema)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/run/data?%s", strings.TrimSuffix(cfg.URL, "/"), params.Encode()), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Token != "" {
+		req.Header.Set("X-Horreum-API-Key", cfg.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("horreum upload failed with status %d: %s", resp.StatusCode, msg)
+	}
+	return strings.TrimSpace(string(msg)), nil
+}
@@ -16,21 +16,89 @@ package log
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path"
 	"runtime"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+const timestampFormat = "2006-01-02 15:04:05"
+
+// Context fields added to every log entry when using the JSON format
+var (
+	fields = logrus.Fields{}
+	lock   sync.RWMutex
+)
+
+func callerPrettyfier(f *runtime.Frame) (function string, file string) {
+	return "", fmt.Sprintf("%s:%d", path.Base(f.File), f.Line)
+}
+
 func init() {
 	logrus.SetReportCaller(true)
 	formatter := &logrus.TextFormatter{
-		TimestampFormat: "2006-01-02 15:04:05",
-		FullTimestamp:   true,
-		DisableColors:   true,
-		CallerPrettyfier: func(f *runtime.Frame) (function string, file string) {
-			return "", fmt.Sprintf("%s:%d", path.Base(f.File), f.Line)
-		},
+		TimestampFormat:  timestampFormat,
+		FullTimestamp:    true,
+		DisableColors:    true,
+		CallerPrettyfier: callerPrettyfier,
 	}
 	logrus.SetFormatter(formatter)
 }
+
+// SetFormat configures the log format, allowed formats are text and json
+func SetFormat(format string) error {
+	switch format {
+	case "text":
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat:  timestampFormat,
+			CallerPrettyfier: callerPrettyfier,
+		})
+		logrus.AddHook(&fieldsHook{})
+	default:
+		return fmt.Errorf("unsupported log format %q, allowed formats are text and json", format)
+	}
+	return nil
+}
+
+// SetField sets a context field, i.e: uuid, test, sample or phase. A nil value removes the field
+func SetField(key string, value interface{}) {
+	lock.Lock()
+	defer lock.Unlock()
+	if value == nil {
+		delete(fields, key)
+		return
+	}
+	fields[key] = value
+}
+
+// fieldsHook adds the context fields to each log entry
+type fieldsHook struct{}
+
+func (h *fieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fieldsHook) Fire(entry *logrus.Entry) error {
+	lock.RLock()
+	defer lock.RUnlock()
+	for k, v := range fields {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+	return nil
+}
+
+// TeeToFile writes the logs to the given file in addition to the current output
+func TeeToFile(filename string) (io.Closer, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	logrus.SetOutput(io.MultiWriter(logrus.StandardLogger().Out, f))
+	return f, nil
+}
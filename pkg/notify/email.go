@@ -0,0 +1,76 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// EmailConfig SMTP settings of the summary emails
+type EmailConfig struct {
+	// Server SMTP server in host:port format
+	Server   string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"ms": func(us float64) string { return fmt.Sprintf("%.2f", us/1e3) },
+}).Parse(`<html><body>
+<p>ingress-perf run <code>{{.UUID}}</code> {{if .Passed}}passed{{else}}<b>failed</b>{{end}}</p>
+{{if .Error}}<p>Error: <code>{{.Error}}</code></p>{{end}}
+{{if .Tests}}<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Test</th><th>Termination</th><th>Samples</th><th>RPS</th><th>Avg lat (ms)</th><th>P99 lat (ms)</th><th>Timeouts</th><th>HTTP errors</th><th>Passed</th></tr>
+{{range .Tests}}<tr><td>{{.Test}}</td><td>{{.Config.Termination}}</td><td>{{.Samples}}</td><td>{{printf "%.0f" .AvgRps}}</td><td>{{ms .AvgLatency}}</td><td>{{ms .P99Latency}}</td><td>{{.Timeouts}}</td><td>{{.HTTPErrors}}</td><td>{{if .Passed}}yes{{else}}<b>no</b>{{end}}</td></tr>
+{{end}}</table>{{end}}
+</body></html>
+`))
+
+// SendEmail sends the run summary as an HTML table to the configured recipients. Authentication is only
+// attempted when a username is set, the SMTP client upgrades the connection with STARTTLS when supported
+func SendEmail(cfg EmailConfig, summary tools.Summary) error {
+	var body bytes.Buffer
+	if err := emailTemplate.Execute(&body, summary); err != nil {
+		return err
+	}
+	status := "passed"
+	if !summary.Passed {
+		status = "failed"
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: ingress-perf run %s %s\r\n", summary.UUID, status)
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n")
+	msg.Write(body.Bytes())
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.Server)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return smtp.SendMail(cfg.Server, auth, cfg.From, cfg.To, msg.Bytes())
+}
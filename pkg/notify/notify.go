@@ -0,0 +1,89 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// Notification formats accepted by Send
+const (
+	FormatWebhook = "webhook"
+	FormatSlack   = "slack"
+)
+
+// client posts the notifications, a slow endpoint must not hang the end of the run
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Table returns the summary as a text table, one row per test
+func Table(summary tools.Summary) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tTERMINATION\tSAMPLES\tRPS\tAVG LAT (ms)\tP99 LAT (ms)\tTIMEOUTS\tHTTP ERRORS\tPASSED")
+	for _, t := range summary.Tests {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%.0f\t%.2f\t%.2f\t%d\t%d\t%v\n",
+			t.Test, t.Config.Termination, t.Samples, t.AvgRps, t.AvgLatency/1e3, t.P99Latency/1e3, t.Timeouts, t.HTTPErrors, t.Passed)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Send posts the run summary to the given URL. The webhook format posts the summary as JSON,
+// while the slack format posts a message with the summary table, suitable for Slack incoming webhooks
+func Send(url, format string, summary tools.Summary) error {
+	var payload interface{}
+	switch format {
+	case FormatWebhook:
+		payload = summary
+	case FormatSlack:
+		status := ":white_check_mark: passed"
+		if !summary.Passed {
+			status = ":x: failed"
+		}
+		text := fmt.Sprintf("*ingress-perf* run `%s` %s", summary.UUID, status)
+		if summary.Error != "" {
+			text += fmt.Sprintf(": %s", summary.Error)
+		}
+		if len(summary.Tests) > 0 {
+			text += fmt.Sprintf("\n```\n%s```", Table(summary))
+		}
+		payload = map[string]string{"text": text}
+	default:
+		return fmt.Errorf("unsupported notification format %q, allowed formats are webhook and slack", format)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification failed with status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
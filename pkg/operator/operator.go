@@ -0,0 +1,269 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	ilog "github.com/cloud-bulldozer/ingress-perf/pkg/log"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner"
+	uid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	PhasePending   = "Pending"
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+)
+
+var runGVR = schema.GroupVersionResource{
+	Group:    "ingress-perf.cloud-bulldozer.io",
+	Version:  "v1alpha1",
+	Resource: "ingressperfruns",
+}
+
+// Options of the runs executed by the operator, the indexer settings can be overridden in each IngressPerfRun
+type Options struct {
+	ESServer         string
+	ESIndex          string
+	OutputDir        string
+	PodMetrics       bool
+	MetricsAddr      string
+	ResyncInterval   time.Duration
+	ProgressInterval time.Duration
+}
+
+// Operator executes the runs declared as IngressPerfRun custom resources, one at a time
+// and in creation order, reporting their progress and summary in the status subresource
+type Operator struct {
+	client dynamic.Interface
+	opts   Options
+}
+
+func New(opts Options) (*Operator, error) {
+	client, err := runner.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Operator{client: client, opts: opts}, nil
+}
+
+// Run reconciles the IngressPerfRun resources until the context is cancelled
+func (o *Operator) Run(ctx context.Context) error {
+	log.Infof("Watching %s every %v", runGVR.Resource, o.opts.ResyncInterval)
+	if err := o.failInterrupted(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(o.opts.ResyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := o.reconcile(ctx); err != nil {
+			log.Errorf("Reconcile error: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// failInterrupted marks as failed the runs left in Running phase by a previous operator instance
+func (o *Operator) failInterrupted() error {
+	runs, err := o.client.Resource(runGVR).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, run := range runs.Items {
+		if phase(run) == PhaseRunning {
+			log.Warnf("Run %s/%s was interrupted", run.GetNamespace(), run.GetName())
+			o.updateStatus(&run, map[string]interface{}{
+				"phase":          PhaseFailed,
+				"message":        "run interrupted, the operator was restarted",
+				"completionTime": time.Now().UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return nil
+}
+
+// reconcile executes the oldest pending run
+func (o *Operator) reconcile(ctx context.Context) error {
+	runs, err := o.client.Resource(runGVR).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	sort.Slice(runs.Items, func(i, j int) bool {
+		ti, tj := runs.Items[i].GetCreationTimestamp(), runs.Items[j].GetCreationTimestamp()
+		return ti.Before(&tj)
+	})
+	for _, run := range runs.Items {
+		if p := phase(run); p == "" || p == PhasePending {
+			run := run
+			o.execute(ctx, &run)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (o *Operator) execute(ctx context.Context, run *unstructured.Unstructured) {
+	uuid, _, _ := unstructured.NestedString(run.Object, "spec", "uuid")
+	if uuid == "" {
+		uuid = uid.NewV4().String()
+	}
+	ilog.SetField("uuid", uuid)
+	defer ilog.SetField("uuid", nil)
+	log.Infof("Executing run %s/%s with uuid %s", run.GetNamespace(), run.GetName(), uuid)
+	if err := loadConfig(run); err != nil {
+		o.updateStatus(run, map[string]interface{}{
+			"phase":   PhaseFailed,
+			"uuid":    uuid,
+			"message": fmt.Sprintf("invalid configuration: %v", err),
+		})
+		return
+	}
+	o.updateStatus(run, map[string]interface{}{
+		"phase":     PhaseRunning,
+		"uuid":      uuid,
+		"startTime": time.Now().UTC().Format(time.RFC3339),
+	})
+	esServer, esIndex := o.opts.ESServer, o.opts.ESIndex
+	if s, _, _ := unstructured.NestedString(run.Object, "spec", "esServer"); s != "" {
+		esServer = s
+	}
+	if s, _, _ := unstructured.NestedString(run.Object, "spec", "esIndex"); s != "" {
+		esIndex = s
+	}
+	cleanup, found, _ := unstructured.NestedBool(run.Object, "spec", "cleanup")
+	opts := []runner.OptsFunctions{
+		runner.WithIndexer(esServer, esIndex, o.opts.OutputDir, o.opts.PodMetrics, false),
+		runner.WithProgress(o.opts.ProgressInterval),
+	}
+	if o.opts.MetricsAddr != "" {
+		opts = append(opts, runner.WithMetrics(o.opts.MetricsAddr))
+	}
+	r := runner.New(uuid, cleanup || !found, opts...)
+	progressCtx, cancelProgress := context.WithCancel(ctx)
+	go o.reportProgress(progressCtx, run)
+	err := r.Start()
+	cancelProgress()
+	status := map[string]interface{}{
+		"phase":          PhaseSucceeded,
+		"progress":       runner.Progress(),
+		"completionTime": time.Now().UTC().Format(time.RFC3339),
+		"summary":        summary(r),
+	}
+	if err != nil {
+		status["phase"] = PhaseFailed
+		status["message"] = err.Error()
+	}
+	log.Infof("Run %s/%s finished: %s", run.GetNamespace(), run.GetName(), status["phase"])
+	o.updateStatus(run, status)
+}
+
+// reportProgress updates the run progress periodically
+func (o *Operator) reportProgress(ctx context.Context, run *unstructured.Unstructured) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.updateStatus(run, map[string]interface{}{"progress": runner.Progress()})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// updateStatus merges the given fields into the run status
+func (o *Operator) updateStatus(run *unstructured.Unstructured, fields map[string]interface{}) {
+	client := o.client.Resource(runGVR).Namespace(run.GetNamespace())
+	latest, err := client.Get(context.TODO(), run.GetName(), metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("Error getting run %s/%s: %v", run.GetNamespace(), run.GetName(), err)
+		return
+	}
+	status, _, _ := unstructured.NestedMap(latest.Object, "status")
+	if status == nil {
+		status = make(map[string]interface{})
+	}
+	for k, v := range fields {
+		status[k] = v
+	}
+	if err := unstructured.SetNestedMap(latest.Object, status, "status"); err != nil {
+		log.Errorf("Error setting run %s/%s status: %v", run.GetNamespace(), run.GetName(), err)
+		return
+	}
+	if _, err := client.UpdateStatus(context.TODO(), latest, metav1.UpdateOptions{}); err != nil {
+		log.Errorf("Error updating run %s/%s status: %v", run.GetNamespace(), run.GetName(), err)
+	}
+}
+
+func phase(run unstructured.Unstructured) string {
+	p, _, _ := unstructured.NestedString(run.Object, "status", "phase")
+	return p
+}
+
+// loadConfig loads the scenarios declared in spec.config
+func loadConfig(run *unstructured.Unstructured) error {
+	scenarios, found, err := unstructured.NestedSlice(run.Object, "spec", "config")
+	if err != nil {
+		return err
+	}
+	if !found || len(scenarios) == 0 {
+		return fmt.Errorf("spec.config is empty")
+	}
+	data, err := json.Marshal(scenarios)
+	if err != nil {
+		return err
+	}
+	return config.LoadReader(bytes.NewReader(data))
+}
+
+// summary returns the run summary without the configuration of each test, already present in the spec
+func summary(r *runner.Runner) map[string]interface{} {
+	s := r.Summary()
+	tests := make([]interface{}, 0, len(s.Tests))
+	for _, t := range s.Tests {
+		tests = append(tests, map[string]interface{}{
+			"test":       int64(t.Test),
+			"passed":     t.Passed,
+			"samples":    int64(t.Samples),
+			"avgRps":     fmt.Sprintf("%.0f", t.AvgRps),
+			"avgLatency": fmt.Sprintf("%.0fus", t.AvgLatency),
+			"p95Latency": fmt.Sprintf("%.0fus", t.P95Latency),
+			"p99Latency": fmt.Sprintf("%.0fus", t.P99Latency),
+			"timeouts":   t.Timeouts,
+			"httpErrors": t.HTTPErrors,
+		})
+	}
+	return map[string]interface{}{
+		"passed": s.Passed,
+		"tests":  tests,
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CIFormatVersion is bumped whenever a field of the CI summary is renamed, removed or changes its meaning
+const CIFormatVersion = 1
+
+// CISummary is a compact summary of a run meant for PR comments and CI job summaries
+type CISummary struct {
+	FormatVersion int          `json:"formatVersion"`
+	UUID          string       `json:"uuid"`
+	Baseline      string       `json:"baseline,omitempty"`
+	Scenarios     []CIScenario `json:"scenarios"`
+}
+
+// CIScenario holds the aggregated results of a scenario and, when the baseline has the same scenario, the
+// relative difference with it as a percentage
+type CIScenario struct {
+	Name         string      `json:"name"`
+	Rps          float64     `json:"rps"`
+	AvgLatencyMs float64     `json:"avgLatencyMs"`
+	P99LatencyMs float64     `json:"p99LatencyMs"`
+	HTTPErrors   int64       `json:"httpErrors"`
+	Timeouts     int64       `json:"timeouts"`
+	Delta        *CIDeltaPct `json:"deltaPct,omitempty"`
+	// Significant tells which differences with the baseline have non-overlapping confidence intervals, it's
+	// only set when both scenarios have at least 2 samples
+	Significant *CISignificant `json:"significant,omitempty"`
+}
+
+// CISignificant holds whether the confidence intervals of each measurement don't overlap with the baseline ones
+type CISignificant struct {
+	Rps        bool `json:"rps"`
+	AvgLatency bool `json:"avgLatency"`
+	P99Latency bool `json:"p99Latency"`
+}
+
+// CIDeltaPct relative difference with the baseline, in percentage
+type CIDeltaPct struct {
+	Rps        float64 `json:"rps"`
+	AvgLatency float64 `json:"avgLatency"`
+	P99Latency float64 `json:"p99Latency"`
+}
+
+// round2 keeps the summary stable across runs with negligible differences
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func deltaPct(current, baseline float64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return round2((current - baseline) / baseline * 100)
+}
+
+// NewCISummary summarizes the scenarios of the report, comparing them with the scenarios of the same name
+// of the baseline report, which is optional
+func NewCISummary(uuid string, current *Report, baselineUUID string, baseline *Report) CISummary {
+	summary := CISummary{FormatVersion: CIFormatVersion, UUID: uuid, Baseline: baselineUUID, Scenarios: []CIScenario{}}
+	baselineScenarios := make(map[string]Scenario)
+	if baseline != nil {
+		for _, s := range baseline.Scenarios() {
+			baselineScenarios[s.Name] = s
+		}
+	}
+	for _, s := range current.Scenarios() {
+		ci := CIScenario{
+			Name:         s.Name,
+			Rps:          round2(s.AvgRps),
+			AvgLatencyMs: round2(s.AvgLatency / 1e3),
+			P99LatencyMs: round2(s.P99Latency / 1e3),
+			HTTPErrors:   s.HTTPErrors,
+			Timeouts:     s.Timeouts,
+		}
+		if b, ok := baselineScenarios[s.Name]; ok {
+			ci.Delta = &CIDeltaPct{
+				Rps:        deltaPct(s.AvgRps, b.AvgRps),
+				AvgLatency: deltaPct(s.AvgLatency, b.AvgLatency),
+				P99Latency: deltaPct(s.P99Latency, b.P99Latency),
+			}
+			if s.Confidence != nil && b.Confidence != nil {
+				ci.Significant = &CISignificant{
+					Rps:        !s.Confidence.Rps.Overlaps(b.Confidence.Rps),
+					AvgLatency: !s.Confidence.AvgLatency.Overlaps(b.Confidence.AvgLatency),
+					P99Latency: !s.Confidence.P99Latency.Overlaps(b.Confidence.P99Latency),
+				}
+			}
+		}
+		summary.Scenarios = append(summary.Scenarios, ci)
+	}
+	return summary
+}
+
+// Markdown returns the summary as a Markdown fragment with a table of the scenarios
+func (s CISummary) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### ingress-perf `%s`", s.UUID)
+	if s.Baseline != "" {
+		fmt.Fprintf(&b, " vs baseline `%s`", s.Baseline)
+	}
+	b.WriteString("\n\n| Scenario | RPS | Avg latency (ms) | P99 latency (ms) | HTTP errors | Timeouts |\n|---|---:|---:|---:|---:|---:|\n")
+	for _, sc := range s.Scenarios {
+		rps, avg, p99 := "", "", ""
+		if sc.Delta != nil {
+			var significant CISignificant
+			if sc.Significant != nil {
+				significant = *sc.Significant
+			}
+			rps, avg, p99 = fmtDelta(sc.Delta.Rps, significant.Rps), fmtDelta(sc.Delta.AvgLatency, significant.AvgLatency), fmtDelta(sc.Delta.P99Latency, significant.P99Latency)
+		}
+		fmt.Fprintf(&b, "| `%s` | %.0f%s | %.2f%s | %.2f%s | %d | %d |\n", sc.Name, sc.Rps, rps, sc.AvgLatencyMs, avg, sc.P99LatencyMs, p99, sc.HTTPErrors, sc.Timeouts)
+	}
+	return b.String()
+}
+
+// fmtDelta formats a difference with the baseline, in bold when the confidence intervals don't overlap
+func fmtDelta(pct float64, significant bool) string {
+	if significant {
+		return fmt.Sprintf(" (**%+.1f%%**)", pct)
+	}
+	return fmt.Sprintf(" (%+.1f%%)", pct)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// results returns a sample per given RPS of the scenario with the given path
+func results(path string, avgLatency float64, rps ...float64) []tools.Result {
+	var res []tools.Result
+	for _, r := range rps {
+		res = append(res, tools.Result{
+			Config:      config.Config{Tool: "hloader", Termination: "http", Path: path, Concurrency: 1, Connections: 10, Procs: 1},
+			TotalAvgRps: r,
+			AvgLatency:  avgLatency,
+			P99Latency:  avgLatency * 2,
+		})
+	}
+	return res
+}
+
+func TestNewCISummary(t *testing.T) {
+	current := &Report{Results: append(results("/1024.html", 2000, 1100, 1100), results("/new.html", 1000, 500)...)}
+	baseline := &Report{Results: results("/1024.html", 1000, 1000, 1000)}
+	summary := NewCISummary("current", current, "baseline", baseline)
+	if summary.FormatVersion != CIFormatVersion || summary.UUID != "current" || summary.Baseline != "baseline" {
+		t.Errorf("unexpected summary header: %+v", summary)
+	}
+	if len(summary.Scenarios) != 2 {
+		t.Fatalf("got %d scenarios, want 2", len(summary.Scenarios))
+	}
+	compared := summary.Scenarios[0]
+	if compared.Rps != 1100 || compared.AvgLatencyMs != 2 || compared.P99LatencyMs != 4 {
+		t.Errorf("unexpected results: %+v", compared)
+	}
+	want := CIDeltaPct{Rps: 10, AvgLatency: 100, P99Latency: 100}
+	if compared.Delta == nil || *compared.Delta != want {
+		t.Errorf("Delta = %+v, want %+v", compared.Delta, want)
+	}
+	// Constant samples have single value intervals, so any difference is significant
+	if compared.Significant == nil || !compared.Significant.Rps || !compared.Significant.AvgLatency || !compared.Significant.P99Latency {
+		t.Errorf("Significant = %+v, want all the differences significant", compared.Significant)
+	}
+	// Scenarios missing from the baseline, or with a single sample, aren't compared
+	if added := summary.Scenarios[1]; added.Delta != nil || added.Significant != nil {
+		t.Errorf("unexpected comparison of a scenario missing from the baseline: %+v", added)
+	}
+	markdown := summary.Markdown()
+	for _, s := range []string{"vs baseline `baseline`", "1100 (**+10.0%**)", "| 500 | 1.00 | 2.00 | 0 | 0 |"} {
+		if !strings.Contains(markdown, s) {
+			t.Errorf("markdown doesn't contain %q:\n%s", s, markdown)
+		}
+	}
+}
+
+func TestNewCISummaryWithoutBaseline(t *testing.T) {
+	summary := NewCISummary("current", &Report{Results: results("/1024.html", 1000, 1000)}, "", nil)
+	if len(summary.Scenarios) != 1 || summary.Scenarios[0].Delta != nil {
+		t.Errorf("unexpected scenarios: %+v", summary.Scenarios)
+	}
+	if strings.Contains(summary.Markdown(), "baseline") {
+		t.Errorf("unexpected baseline in the markdown:\n%s", summary.Markdown())
+	}
+}
+
+func TestDeltaPct(t *testing.T) {
+	tests := []struct {
+		current, baseline, want float64
+	}{
+		{110, 100, 10},
+		{90, 100, -10},
+		{100, 100, 0},
+		{1, 3, -66.67},
+		{100, 0, 0},
+	}
+	for _, tc := range tests {
+		if got := deltaPct(tc.current, tc.baseline); got != tc.want {
+			t.Errorf("deltaPct(%v, %v) = %v, want %v", tc.current, tc.baseline, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// openMetric is a measurement of the test summary exported as a gauge
+type openMetric struct {
+	name  string
+	help  string
+	value func(t tools.TestSummary) float64
+}
+
+var openMetrics = []openMetric{
+	{"ingress_perf_rps", "Aggregated requests per second of the test", func(t tools.TestSummary) float64 { return t.AvgRps }},
+	{"ingress_perf_avg_latency_seconds", "Aggregated average latency of the test", func(t tools.TestSummary) float64 { return t.AvgLatency / 1e6 }},
+	{"ingress_perf_p95_latency_seconds", "Aggregated P95 latency of the test", func(t tools.TestSummary) float64 { return t.P95Latency / 1e6 }},
+	{"ingress_perf_p99_latency_seconds", "Aggregated P99 latency of the test", func(t tools.TestSummary) float64 { return t.P99Latency / 1e6 }},
+	{"ingress_perf_http_errors", "HTTP errors of the test samples", func(t tools.TestSummary) float64 { return float64(t.HTTPErrors) }},
+	{"ingress_perf_timeouts", "Timeouts of the test samples", func(t tools.TestSummary) float64 { return float64(t.Timeouts) }},
+	{"ingress_perf_rps_per_router_core", "Aggregated requests per second per router CPU core", func(t tools.TestSummary) float64 { return t.RpsPerRouterCore }},
+	{"ingress_perf_router_cpu_seconds_per_1k_requests", "Aggregated router CPU time per 1k requests", func(t tools.TestSummary) float64 { return t.RouterCPUPer1kReq / 1e3 }},
+	{"ingress_perf_samples", "Completed samples of the test", func(t tools.TestSummary) float64 { return float64(t.Samples) }},
+	{"ingress_perf_passed", "Whether the test passed", func(t tools.TestSummary) float64 {
+		if t.Passed {
+			return 1
+		}
+		return 0
+	}},
+}
+
+// OpenMetrics returns the aggregated results of the run in the OpenMetrics text format, one gauge per measurement
+// with a series per test labeled with the main scenario settings. Warmup tests are left out
+func OpenMetrics(summary tools.Summary) string {
+	var b strings.Builder
+	for _, m := range openMetrics {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n# HELP %s %s\n", m.name, m.name, m.help)
+		for _, t := range summary.Tests {
+			if t.Config.Warmup {
+				continue
+			}
+			fmt.Fprintf(&b, "%s{uuid=%q,test=\"%d\",tool=%q,termination=%q,path=%q,concurrency=\"%d\",procs=\"%d\",connections=\"%d\",keepalive=\"%v\",aggregation=%q} %g\n",
+				m.name, summary.UUID, t.Test, t.Config.Tool, t.Config.Termination, t.Config.Path, t.Config.Concurrency,
+				t.Config.Procs, t.Config.Connections, t.Config.Keepalive, t.Aggregation, m.value(t))
+		}
+	}
+	b.WriteString("# EOF\n")
+	return b.String()
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+func TestOpenMetrics(t *testing.T) {
+	cfg := config.Config{Tool: "hloader", Termination: "edge", Path: "/1024.html", Concurrency: 2, Procs: 1, Connections: 10, Keepalive: true}
+	warmup := cfg
+	warmup.Warmup = true
+	summary := tools.Summary{
+		UUID: "run",
+		Tests: []tools.TestSummary{
+			{Test: 1, Config: warmup, AvgRps: 1},
+			{Test: 2, Config: cfg, Aggregation: "median", Passed: true, Samples: 3, AvgRps: 1500.5, P99Latency: 25000},
+		},
+	}
+	metrics := OpenMetrics(summary)
+	labels := `{uuid="run",test="2",tool="hloader",termination="edge",path="/1024.html",concurrency="2",procs="1",connections="10",keepalive="true",aggregation="median"}`
+	for _, s := range []string{
+		"# TYPE ingress_perf_rps gauge\n# HELP ingress_perf_rps Aggregated requests per second of the test\n",
+		"ingress_perf_rps" + labels + " 1500.5\n",
+		"ingress_perf_p99_latency_seconds" + labels + " 0.025\n",
+		"ingress_perf_samples" + labels + " 3\n",
+		"ingress_perf_passed" + labels + " 1\n",
+	} {
+		if !strings.Contains(metrics, s) {
+			t.Errorf("metrics don't contain %q:\n%s", s, metrics)
+		}
+	}
+	if strings.Contains(metrics, `test="1"`) {
+		t.Errorf("metrics contain the warmup test:\n%s", metrics)
+	}
+	if !strings.HasSuffix(metrics, "# EOF\n") {
+		t.Errorf("metrics don't end with the EOF marker:\n%s", metrics)
+	}
+	if got, want := strings.Count(metrics, "# TYPE "), len(openMetrics); got != want {
+		t.Errorf("got %d metric families, want %d", got, want)
+	}
+}
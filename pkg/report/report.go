@@ -0,0 +1,257 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// Width of the longest bar in the RPS chart
+const chartWidth = 50
+
+// Report holds the documents of a benchmark run
+type Report struct {
+	Results      []tools.Result
+	Propagations []tools.PropagationResult
+}
+
+// Scenario aggregates the samples of a benchmark scenario
+type Scenario struct {
+	Name        string
+	Aggregation string
+	Samples     int
+	AvgRps      float64
+	AvgLatency  float64
+	P95Latency  float64
+	P99Latency  float64
+	HTTPErrors  int64
+	Timeouts    int64
+	Confidence  *tools.Confidence
+}
+
+// FromDirectory loads the documents of the given uuid from a local results directory, compressed results are
+// read when there aren't plain ones
+func FromDirectory(dir, uuid string) (*Report, error) {
+	data, err := os.ReadFile(path.Join(dir, fmt.Sprintf("%s.json", uuid)))
+	if os.IsNotExist(err) {
+		data, err = readGzip(path.Join(dir, fmt.Sprintf("%s.json.gz", uuid)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var documents []json.RawMessage
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return nil, err
+	}
+	return parse(documents)
+}
+
+// FromElasticsearch queries the documents of the given uuid from an Elasticsearch/OpenSearch index
+func FromElasticsearch(esServer, esIndex, uuid string) (*Report, error) {
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	query, err := json.Marshal(map[string]interface{}{
+		"size":  10000,
+		"query": map[string]interface{}{"match_phrase": map[string]string{"uuid": uuid}},
+		"sort":  []map[string]string{{"timestamp": "asc"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Post(fmt.Sprintf("%s/%s/_search", strings.TrimRight(esServer, "/"), esIndex), "application/json", bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s: %s", resp.StatusCode, esServer, body)
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	documents := make([]json.RawMessage, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		documents = append(documents, hit.Source)
+	}
+	return parse(documents)
+}
+
+func readGzip(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func parse(documents []json.RawMessage) (*Report, error) {
+	report := &Report{}
+	if len(documents) == 0 {
+		return report, fmt.Errorf("no documents found")
+	}
+	for _, doc := range documents {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(doc, &fields); err != nil {
+			return report, err
+		}
+		// kube-burner documents duplicate the results in a different shape
+		if _, ok := fields["metricName"]; ok {
+			continue
+		}
+		// IngressController comparisons and timeout sweeps are derived from the results
+		if _, ok := fields["comparison"]; ok {
+			continue
+		}
+		if _, ok := fields["sweep"]; ok {
+			continue
+		}
+		doc, err := tools.Migrate(doc)
+		if err != nil {
+			return report, err
+		}
+		if _, ok := fields["latencies_ms"]; ok {
+			var p tools.PropagationResult
+			if err := json.Unmarshal(doc, &p); err != nil {
+				return report, err
+			}
+			report.Propagations = append(report.Propagations, p)
+			continue
+		}
+		var r tools.Result
+		if err := json.Unmarshal(doc, &r); err != nil {
+			return report, err
+		}
+		report.Results = append(report.Results, r)
+	}
+	return report, nil
+}
+
+// Scenarios aggregates consecutive samples sharing the same configuration, using the aggregation method of the scenario
+func (r *Report) Scenarios() []Scenario {
+	var scenarios []Scenario
+	var samples [][]tools.Result
+	var last string
+	for _, res := range r.Results {
+		name := fmt.Sprintf("%s/%s c=%d conn=%d procs=%d %s",
+			res.Config.Tool, res.Config.Termination, res.Config.Concurrency, res.Config.Connections, res.Config.Procs, res.Config.Path)
+		if name != last || len(scenarios) == 0 {
+			scenarios = append(scenarios, Scenario{Name: name, Aggregation: tools.AggregationMethod(res.Config)})
+			samples = append(samples, nil)
+			last = name
+		}
+		samples[len(samples)-1] = append(samples[len(samples)-1], res)
+	}
+	for i := range scenarios {
+		s := &scenarios[i]
+		var rps, avgLatency, p95Latency, p99Latency []float64
+		for _, res := range samples[i] {
+			rps = append(rps, res.TotalAvgRps)
+			avgLatency = append(avgLatency, res.AvgLatency)
+			p95Latency = append(p95Latency, res.P95Latency)
+			p99Latency = append(p99Latency, res.P99Latency)
+			s.HTTPErrors += res.HTTPErrors
+			s.Timeouts += res.Timeouts
+		}
+		s.Samples = len(samples[i])
+		s.AvgRps = tools.Aggregate(rps, s.Aggregation, true)
+		s.AvgLatency = tools.Aggregate(avgLatency, s.Aggregation, false)
+		s.P95Latency = tools.Aggregate(p95Latency, s.Aggregation, false)
+		s.P99Latency = tools.Aggregate(p99Latency, s.Aggregation, false)
+		s.Confidence = tools.Bootstrap(samples[i], s.Aggregation)
+	}
+	return scenarios
+}
+
+// Print writes the report tables, and optionally an RPS chart, to the given writer
+func (r *Report) Print(out io.Writer, chart bool) error {
+	scenarios := r.Scenarios()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if len(scenarios) > 0 {
+		fmt.Fprintln(w, "SCENARIO\tAGGREGATION\tSAMPLES\tRPS\tRPS 95% CI\tAVG LAT (ms)\tP95 LAT (ms)\tP99 LAT (ms)\tHTTP ERRORS\tTIMEOUTS")
+		for _, s := range scenarios {
+			rpsCI := "-"
+			if s.Confidence != nil {
+				rpsCI = fmt.Sprintf("%.0f-%.0f", s.Confidence.Rps.Low, s.Confidence.Rps.High)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.0f\t%s\t%.2f\t%.2f\t%.2f\t%d\t%d\n",
+				s.Name, s.Aggregation, s.Samples, s.AvgRps, rpsCI, s.AvgLatency/1e3, s.P95Latency/1e3, s.P99Latency/1e3, s.HTTPErrors, s.Timeouts)
+		}
+	}
+	if len(r.Propagations) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "PROPAGATION\tMEASUREMENTS\tAVG (ms)\tP50 (ms)\tP95 (ms)\tP99 (ms)\tMAX (ms)\tFAILURES")
+		for _, p := range r.Propagations {
+			fmt.Fprintf(w, "%s\t%d\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%d\n",
+				p.Config.Termination, len(p.Latencies), p.AvgLatency, p.P50Latency, p.P95Latency, p.P99Latency, p.MaxLatency, p.Failures)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if chart && len(scenarios) > 0 {
+		printChart(out, scenarios)
+	}
+	return nil
+}
+
+// printChart draws a horizontal bar chart with the RPS of each scenario
+func printChart(out io.Writer, scenarios []Scenario) {
+	var maxRps float64
+	var maxName int
+	for _, s := range scenarios {
+		if s.AvgRps > maxRps {
+			maxRps = s.AvgRps
+		}
+		if len(s.Name) > maxName {
+			maxName = len(s.Name)
+		}
+	}
+	fmt.Fprintln(out, "\nRPS")
+	for _, s := range scenarios {
+		var bar int
+		if maxRps > 0 {
+			bar = int(s.AvgRps / maxRps * chartWidth)
+		}
+		fmt.Fprintf(out, "%-*s │%s %.0f\n", maxName, s.Name, strings.Repeat("█", bar), s.AvgRps)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// SummaryTable returns a table with the aggregated results of the tests of the run side by side, warmup tests
+// are left out
+func SummaryTable(summary tools.Summary) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tTOOL\tTERMINATION\tCONCURRENCY\tCONNECTIONS\tSAMPLES\tRPS\tP99 LAT (ms)\tROUTER CPU (cores)\tHTTP ERRORS\tTIMEOUTS\tRESULT")
+	for _, t := range summary.Tests {
+		if t.Config.Warmup {
+			continue
+		}
+		result := "PASS"
+		if !t.Passed {
+			result = "FAIL"
+		}
+		if t.Unstable {
+			result += " (unstable)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%.0f\t%.2f\t%.2f\t%d\t%d\t%s\n",
+			t.Test, t.Config.Tool, t.Config.Termination, t.Config.Concurrency, t.Config.Connections, t.Samples,
+			t.AvgRps, t.P99Latency/1e3, t.RouterCPU, t.HTTPErrors, t.Timeouts, result)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import "encoding/json"
+
+// touchstoneBuckets identify comparable scenarios across runs, config strings are mapped as keywords
+var touchstoneBuckets = []string{
+	"config.tool", "config.termination", "config.path", "config.concurrency", "config.procs", "config.connections",
+	"config.keepalive", "config.serverReplicas", "config.tuningPatch",
+}
+
+// touchstoneAggregations compared metrics of each scenario, samples are aggregated
+var touchstoneAggregations = map[string][]interface{}{
+	"total_avg_rps": {"avg", "max"},
+	"avg_lat_us":    {"avg"},
+	"p95_lat_us":    {"avg"},
+	"p99_lat_us":    {"avg", "max"},
+	"http_errors":   {"sum"},
+	"timeouts":      {"sum"},
+	"infra_metrics.avg_cpu_usage_router_pods":          {"avg"},
+	"infra_metrics.avg_memory_usage_router_pods_bytes": {"avg"},
+}
+
+// TouchstoneConfig returns a touchstone comparison configuration for the results indexed in the given index.
+// It's consumed with i.e: touchstone_compare --database elasticsearch --config <file> -url <es-server> -u <uuid1> <uuid2>
+func TouchstoneConfig(index string) ([]byte, error) {
+	config := map[string]interface{}{
+		"elasticsearch": map[string]interface{}{
+			index: []map[string]interface{}{
+				{
+					"filter":       map[string]interface{}{},
+					"buckets":      touchstoneBuckets,
+					"aggregations": touchstoneAggregations,
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	admissionTimeout  = 2 * time.Minute
+	admissionInterval = time.Second
+)
+
+// waitForRouteAdmission waits for the benchmark and SNI routes of the scenario to be admitted by the targeted
+// IngressController, failing as soon as it rejects any of them, so the load doesn't start against routes the
+// router never serves. Existing routes are accepted when admitted by any router
+func waitForRouteAdmission(cfg config.Config) error {
+	// The Envoy based ingresses don't admit routes
+	if envoyIngress(cfg) {
+		return nil
+	}
+	route, err := benchmarkRoute(cfg)
+	if err != nil {
+		return err
+	}
+	router := defaultIngressController
+	if shardedController(cfg) {
+		router = cfg.IngressController
+	}
+	if _, ok := existingRoutes[cfg.Termination]; ok {
+		router = ""
+	}
+	names := []string{route.Name}
+	for i := 0; i < cfg.SNIHosts; i++ {
+		names = append(names, fmt.Sprintf("%s-sni-%s-%d", serverName, cfg.Termination, i))
+	}
+	start := time.Now()
+	for _, name := range names {
+		if err := waitForAdmission(route.Namespace, name, router); err != nil {
+			return err
+		}
+	}
+	log.Infof("%d routes admitted in %v", len(names), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// waitForAdmission waits for the Admitted condition of the given route from the given router, any router when empty
+func waitForAdmission(ns, name, router string) error {
+	var rejection error
+	err := wait.PollUntilContextTimeout(context.TODO(), admissionInterval, admissionTimeout, true, func(ctx context.Context) (bool, error) {
+		route, err := orClientSet.RouteV1().Routes(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, ingress := range route.Status.Ingress {
+			if router != "" && ingress.RouterName != router {
+				continue
+			}
+			condition := admittedCondition(ingress)
+			if condition == nil {
+				continue
+			}
+			if condition.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+			if condition.Status == corev1.ConditionFalse {
+				rejection = fmt.Errorf("route %s/%s rejected by router %s: %s: %s", ns, name, ingress.RouterName, condition.Reason, condition.Message)
+				return false, rejection
+			}
+		}
+		log.Debugf("Waiting for route %s/%s to be admitted", ns, name)
+		return false, nil
+	})
+	if rejection != nil {
+		return rejection
+	}
+	if err != nil {
+		if router == "" {
+			return fmt.Errorf("route %s/%s not admitted after %v: %w", ns, name, admissionTimeout, err)
+		}
+		return fmt.Errorf("route %s/%s not admitted by IngressController %s after %v: %w", ns, name, router, admissionTimeout, err)
+	}
+	return nil
+}
+
+func admittedCondition(ingress routev1.RouteIngress) *routev1.RouteIngressCondition {
+	for i := range ingress.Conditions {
+		if ingress.Conditions[i].Type == routev1.RouteAdmitted {
+			return &ingress.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Name of the cookie used by the router to implement session affinity
+const stickyCookie = "ingress-perf"
+
+// fetchCookies requests count sessions to the given target from the given pod and
+// returns the value of the affinity cookie set by the router in each one of them
+func fetchCookies(cfg config.Config, t target, count int, pod corev1.Pod) ([]string, error) {
+	var cookies []string
+	flags := curlFlags(cfg)
+	for _, h := range t.headers {
+		flags += fmt.Sprintf(" -H '%s'", h)
+	}
+	script := fmt.Sprintf(`for i in $(seq %d); do curl %s -o /dev/null -c - %s; done`, count, flags, t.url)
+	stdout, stderr, err := podExec(context.TODO(), pod, clientName, []string{"bash", "-c", script})
+	if err != nil {
+		return cookies, fmt.Errorf("%v: %s", err, stderr)
+	}
+	// Netscape cookie jar format: domain, flag, path, secure, expiration, name and value separated by tabs
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 7 && fields[5] == stickyCookie {
+			cookies = append(cookies, fields[6])
+		}
+	}
+	if len(cookies) != count {
+		return cookies, fmt.Errorf("expected %d affinity cookies, got %d", count, len(cookies))
+	}
+	return cookies, nil
+}
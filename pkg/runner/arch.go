@@ -0,0 +1,95 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// archPlaceholder is replaced by the selected architecture in the client and server images, i.e: ingress-perf:latest-ARCH
+const archPlaceholder = "ARCH"
+
+// requestedArch is the architecture of the nodes running the benchmark pods, empty to use the most common one
+var requestedArch string
+
+// WithArch runs the client and server pods in worker nodes of the given architecture, i.e: arm64
+func WithArch(arch string) OptsFunctions {
+	return func(r *Runner) {
+		requestedArch = arch
+	}
+}
+
+// selectArch picks the architecture of the benchmark pods among the ones of the worker nodes, pins the pods to
+// it and replaces the architecture placeholder of the images. Multi-arch images work in any architecture
+func selectArch() (string, error) {
+	nodes, err := clientSet.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "node-role.kubernetes.io/worker,!node-role.kubernetes.io/infra",
+	})
+	if err != nil {
+		return "", err
+	}
+	archs := make(map[string]int)
+	for _, node := range nodes.Items {
+		archs[node.Status.NodeInfo.Architecture]++
+	}
+	if len(archs) == 0 {
+		return "", fmt.Errorf("no worker nodes found")
+	}
+	arch := requestedArch
+	if arch == "" {
+		// The most common architecture, ties are broken alphabetically so the choice is stable
+		var names []string
+		for a := range archs {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		for _, a := range names {
+			if archs[a] > archs[arch] {
+				arch = a
+			}
+		}
+	} else if archs[arch] == 0 {
+		return "", fmt.Errorf("no %s worker nodes found, available architectures: %v", arch, archs)
+	}
+	if len(archs) > 1 {
+		log.Infof("Multi-architecture cluster %v, running the benchmark pods in %s nodes", archs, arch)
+	}
+	terms := workerAffinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for i := range terms {
+		// Runs in serve mode share the affinity, so the requirement of a previous run is replaced
+		var exprs []corev1.NodeSelectorRequirement
+		for _, e := range terms[i].MatchExpressions {
+			if e.Key != corev1.LabelArchStable {
+				exprs = append(exprs, e)
+			}
+		}
+		terms[i].MatchExpressions = append(exprs, corev1.NodeSelectorRequirement{
+			Key:      corev1.LabelArchStable,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{arch},
+		})
+	}
+	for _, d := range []*corev1.PodSpec{&client.Spec.Template.Spec, &server.Spec.Template.Spec} {
+		d.Containers[0].Image = strings.ReplaceAll(d.Containers[0].Image, archPlaceholder, arch)
+	}
+	return arch, nil
+}
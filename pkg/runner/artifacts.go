@@ -0,0 +1,145 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	logFile      = "ingress-perf.log"
+	configFile   = "config.yml"
+	commandsFile = "commands.log"
+	resultFile   = "result.json"
+)
+
+// artifactsDir directory where the run artifacts are stored, artifacts are disabled when empty
+var artifactsDir string
+
+// currentTest and currentSample indexes of the test and sample being executed, starting from 1
+var currentTest, currentSample int
+
+var commandsLock = &sync.Mutex{}
+
+// idLabels returns the resource labels plus the identifiers of the test and sample being executed, if any
+func idLabels() map[string]string {
+	labels := make(map[string]string, len(resourceLabels)+2)
+	for k, v := range resourceLabels {
+		labels[k] = v
+	}
+	if uuid := resourceLabels[uuidLabel]; uuid != "" && currentTest > 0 {
+		labels[testLabel] = tools.TestID(uuid, currentTest)
+		if currentSample > 0 {
+			labels[sampleLabel] = tools.SampleID(uuid, currentTest, currentSample)
+		}
+	}
+	return labels
+}
+
+// writeConfig stores the effective configuration, after expansion and defaults, in the artifacts directory
+func writeConfig(cfgs []config.Config) error {
+	if artifactsDir == "" {
+		return nil
+	}
+	data, err := yaml.Marshal(cfgs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(artifactsDir, configFile), data, 0644)
+}
+
+// sampleArtifactsDir returns the directory holding the raw artifacts of a sample of the current test
+func sampleArtifactsDir(sample int) string {
+	return path.Join(artifactsDir, fmt.Sprintf("test-%d", currentTest), fmt.Sprintf("sample-%d", sample))
+}
+
+// recordCommand appends the command executed in a client pod, and the target URL it resolved to, to the commands
+// file of the artifacts directory and to the one of the current test
+func recordCommand(sample int, pod, url string, cmd []string) {
+	if artifactsDir == "" {
+		return
+	}
+	commandsLock.Lock()
+	defer commandsLock.Unlock()
+	line := fmt.Sprintf("%s test=%d sample=%d pod=%s url=%s: %s\n", time.Now().UTC().Format(time.RFC3339), currentTest, sample, pod, url, strings.Join(cmd, " "))
+	testDir := path.Join(artifactsDir, fmt.Sprintf("test-%d", currentTest))
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		log.Errorf("Couldn't record command: %v", err)
+		return
+	}
+	for _, file := range []string{path.Join(artifactsDir, commandsFile), path.Join(testDir, commandsFile)} {
+		if err := appendFile(file, line); err != nil {
+			log.Errorf("Couldn't record command: %v", err)
+		}
+	}
+}
+
+// recordOutput appends the raw output of a tool execution to the file of the client pod in the sample artifacts
+// directory, executions of the same pod, i.e. with resultInterval, are appended one after another
+func recordOutput(sample int, pod string, cmd []string, stdout, stderr string) {
+	if artifactsDir == "" {
+		return
+	}
+	dir := sampleArtifactsDir(sample)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Couldn't record output: %v", err)
+		return
+	}
+	output := fmt.Sprintf("$ %s\n--- stdout\n%s\n--- stderr\n%s\n", strings.Join(cmd, " "), stdout, stderr)
+	if err := appendFile(path.Join(dir, pod+".log"), output); err != nil {
+		log.Errorf("Couldn't record output: %v", err)
+	}
+}
+
+// recordResult stores the result document of a sample next to the raw outputs it was computed from
+func recordResult(result tools.Result) {
+	if artifactsDir == "" {
+		return
+	}
+	dir := sampleArtifactsDir(result.Sample)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Couldn't record result: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Errorf("Couldn't record result: %v", err)
+		return
+	}
+	if err := os.WriteFile(path.Join(dir, resultFile), data, 0644); err != nil {
+		log.Errorf("Couldn't record result: %v", err)
+	}
+}
+
+func appendFile(file, data string) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(data)
+	return err
+}
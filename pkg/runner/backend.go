@@ -0,0 +1,99 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var (
+	// backendRef <namespace>/<name> of the existing service backing the routes, empty when the bundled server is deployed
+	backendRef string
+	// httpTargetPort service port targeted by the routes not terminating TLS in the backend
+	httpTargetPort = intstr.FromString("http")
+)
+
+// WithBackend points the benchmark routes to an existing service, in <namespace>/<name> format, instead of
+// deploying the bundled server. The routes are created in the namespace of the service
+func WithBackend(ref string) OptsFunctions {
+	return func(r *Runner) {
+		if ref == "" {
+			return
+		}
+		if ns, name, ok := strings.Cut(ref, "/"); !ok || ns == "" || name == "" {
+			log.Fatalf("Invalid backend %q, expected format is <namespace>/<name>", ref)
+		}
+		backendRef = ref
+	}
+}
+
+// setupBackend retargets the benchmark routes to the backend service
+func (r *Runner) setupBackend() error {
+	if r.serviceMesh {
+		return fmt.Errorf("an existing backend can't be used in service mesh mode")
+	}
+	for i, cfg := range config.Cfg {
+		if cfg.Tenants > 0 || len(cfg.BackendWeights) > 0 || envoyIngress(cfg) {
+			return fmt.Errorf("scenario %d: tenants, backendWeights and the contour and istio ingresses require the bundled server, they can't be used with an existing backend", i+1)
+		}
+	}
+	ns, name, _ := strings.Cut(backendRef, "/")
+	svc, err := clientSet.CoreV1().Services(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("backend service %s not found", backendRef)
+	} else if err != nil {
+		return err
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return fmt.Errorf("backend service %s has no ports", backendRef)
+	}
+	log.Infof("Using backend service %s", backendRef)
+	routesNamespace = ns
+	service.Name = name
+	httpTargetPort = backendPort(svc, "http")
+	for i := range routes {
+		routes[i].Spec.To.Name = name
+		routes[i].Spec.Port.TargetPort = backendPort(svc, routes[i].Spec.Port.TargetPort.StrVal)
+		if routes[i].Spec.TLS != nil {
+			// The bundled CA doesn't sign the backend certificate, fall back to the service serving CA
+			routes[i].Spec.TLS.DestinationCACertificate = ""
+		}
+	}
+	return nil
+}
+
+// backendPort returns the port of the service with the given name, or its first port when there isn't such port
+func backendPort(svc *corev1.Service, name string) intstr.IntOrString {
+	for _, p := range svc.Spec.Ports {
+		if p.Name == name {
+			return intstr.FromString(name)
+		}
+	}
+	p := svc.Spec.Ports[0]
+	log.Debugf("Backend service %s has no %s port, using port %d", svc.Name, name, p.Port)
+	if p.Name != "" {
+		return intstr.FromString(p.Name)
+	}
+	return intstr.FromInt(int(p.Port))
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// barrierTool delays the start of the wrapped tool until the given instant, using the clock of the client pod
+type barrierTool struct {
+	tools.Tool
+	startAt time.Time
+}
+
+// withBarrier wraps the tool so that it starts at the given instant, a zero instant disables the barrier
+func withBarrier(tool tools.Tool, startAt time.Time) tools.Tool {
+	if startAt.IsZero() {
+		return tool
+	}
+	if late := time.Since(startAt); late > 0 {
+		log.Warnf("Client command dispatched %v after the start barrier, consider increasing startBarrier", late.Truncate(time.Millisecond))
+	}
+	return &barrierTool{Tool: tool, startAt: startAt}
+}
+
+// Cmd sleeps until the start instant, with millisecond precision, and replaces the shell with the tool
+func (b *barrierTool) Cmd() []string {
+	script := fmt.Sprintf(`d=$(( (%d - $(date +%%s%%N)) / 1000000 )); [ $d -gt 0 ] && sleep $(printf '%%d.%%03d' $((d / 1000)) $((d %% 1000))); exec "$@"`,
+		b.startAt.UnixNano())
+	return append([]string{"bash", "-c", script, "--"}, b.Tool.Cmd()...)
+}
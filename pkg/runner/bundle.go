@@ -0,0 +1,172 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// bundleMetadata file of the bundle holding the cluster metadata and the run summary
+const bundleMetadata = "metadata.json"
+
+// WithCompression gzips the result documents stored in the results directory, <uuid>.json.gz
+func WithCompression(enable bool) OptsFunctions {
+	return func(r *Runner) {
+		r.compress = enable
+	}
+}
+
+// compressResults replaces the local results file with its gzipped version
+func compressResults(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(file + ".gz")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	log.Infof("Results compressed in %s.gz", file)
+	return os.Remove(file)
+}
+
+// Bundle archives the whole run in <outputDir>/<uuid>.tar.gz: the result documents, the artifacts and diagnostics
+// in <outputDir>/<uuid>, and the cluster metadata and run summary. With a signing key, the archive also holds the
+// checksum manifest of its files and the signature of the manifest. Returns the path of the archive
+func (r *Runner) Bundle(outputDir string) (string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+	bundle := path.Join(outputDir, r.uuid+".tar.gz")
+	f, err := os.Create(bundle)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	bw := &bundleWriter{tw: tar.NewWriter(zw)}
+	metadata, err := json.MarshalIndent(struct {
+		Metadata tools.ClusterMetadata `json:"metadata"`
+		Summary  tools.Summary         `json:"summary"`
+	}{r.metadata, r.summary}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := bw.write(path.Join(r.uuid, bundleMetadata), metadata); err != nil {
+		return "", err
+	}
+	for _, results := range []string{r.uuid + ".json", r.uuid + ".json.gz"} {
+		if _, err := os.Stat(path.Join(outputDir, results)); err == nil {
+			if err := bw.add(path.Join(outputDir, results), path.Join(r.uuid, results)); err != nil {
+				return "", err
+			}
+		}
+	}
+	artifacts := path.Join(outputDir, r.uuid)
+	if _, err := os.Stat(artifacts); err == nil {
+		err = filepath.WalkDir(artifacts, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(outputDir, file)
+			if err != nil {
+				return err
+			}
+			return bw.add(file, rel)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if r.signingKey != nil {
+		if err := bw.sign(r.uuid, r.signingKey); err != nil {
+			return "", err
+		}
+	}
+	if err := bw.tw.Close(); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return bundle, nil
+}
+
+// bundleWriter writes the files of a bundle, keeping the checksum manifest of the files written
+type bundleWriter struct {
+	tw       *tar.Writer
+	manifest bytes.Buffer
+}
+
+// write adds a file with the given content to the archive
+func (bw *bundleWriter) write(name string, data []byte) error {
+	if err := bw.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := bw.tw.Write(data); err != nil {
+		return err
+	}
+	fmt.Fprintf(&bw.manifest, "%x  %s\n", sha256.Sum256(data), name)
+	return nil
+}
+
+// add writes the given file in the archive with the given name
+func (bw *bundleWriter) add(file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if err := bw.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(bw.tw, h), f); err != nil {
+		return fmt.Errorf("error archiving %s: %w", file, err)
+	}
+	fmt.Fprintf(&bw.manifest, "%x  %s\n", h.Sum(nil), header.Name)
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// certificateValidity of the generated route certificates
+const certificateValidity = 30 * 24 * time.Hour
+
+// issuer signs the route certificates, the certificates are self-signed when nil
+type issuer struct {
+	cert    *x509.Certificate
+	certPEM string
+	key     crypto.Signer
+}
+
+// reconcileCertificates serves the benchmark, SNI and IngressController routes of the scenario with certificates
+// generated for their hosts, and sets the TLS configuration of the routes template back in the rest of routes
+func reconcileCertificates(cfg config.Config) error {
+	if len(existingRoutes) > 0 {
+		return nil
+	}
+	var ca *issuer
+	var key crypto.Signer
+	var err error
+	if cfg.Certificate != nil {
+		if cfg.Certificate.CACert != "" {
+			if ca, err = loadIssuer(cfg.Certificate.CACert, cfg.Certificate.CAKey); err != nil {
+				return err
+			}
+		}
+		// A single key is shared by the certificates of the scenario
+		if key, err = generateKey(cfg.Certificate); err != nil {
+			return err
+		}
+	}
+	for _, template := range routes {
+		if template.Spec.TLS == nil || template.Spec.TLS.Termination == routev1.TLSTerminationPassthrough {
+			continue
+		}
+		names := []string{template.Name}
+		termination := template.Name == fmt.Sprintf("%s-%s", serverName, cfg.Termination)
+		scenario := cfg.Certificate != nil && termination
+		if termination {
+			for i := 0; i < cfg.SNIHosts; i++ {
+				names = append(names, fmt.Sprintf("%s-sni-%s-%d", serverName, cfg.Termination, i))
+			}
+			if shardedController(cfg) {
+				names = append(names, controllerRouteName(cfg))
+			}
+		}
+		for _, name := range names {
+			route, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			tls := template.Spec.TLS.DeepCopy()
+			if scenario {
+				if err := routeCertificate(tls, route.Spec.Host, key, ca); err != nil {
+					return err
+				}
+				if cfg.Certificate.DestinationCA != "" {
+					destinationCA, err := os.ReadFile(cfg.Certificate.DestinationCA)
+					if err != nil {
+						return err
+					}
+					tls.DestinationCACertificate = string(destinationCA)
+				}
+			} else if route.Spec.TLS != nil && route.Spec.TLS.Certificate == "" && route.Spec.TLS.DestinationCACertificate == tls.DestinationCACertificate {
+				continue
+			}
+			log.Debugf("Updating route %s certificate", route.Name)
+			route.Spec.TLS = tls
+			if _, err := orClientSet.RouteV1().Routes(routesNamespace).Update(context.TODO(), route, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Certificate != nil {
+		log.Infof("Benchmark routes served with generated %s certificates, issued by %s", certificateKey(cfg), issuerName(ca))
+	}
+	return nil
+}
+
+// certificateKey returns the key type and size of the generated certificates of the scenario, i.e. rsa-2048,
+// empty when the scenario uses the default certificate
+func certificateKey(cfg config.Config) string {
+	if cfg.Certificate == nil {
+		return ""
+	}
+	keyType, size := keyParameters(cfg.Certificate)
+	return fmt.Sprintf("%s-%d", keyType, size)
+}
+
+// keyParameters returns the key type and size of the certificate, with the defaults applied
+func keyParameters(cert *config.Certificate) (string, int) {
+	keyType, size := cert.KeyType, cert.KeySize
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if size == 0 {
+		size = 2048
+		if keyType == "ecdsa" {
+			size = 256
+		}
+	}
+	return keyType, size
+}
+
+// generateKey generates the key of the certificates of the scenario
+func generateKey(cert *config.Certificate) (crypto.Signer, error) {
+	keyType, size := keyParameters(cert)
+	if keyType == "rsa" {
+		return rsa.GenerateKey(rand.Reader, size)
+	}
+	curve := elliptic.P256()
+	if size == 384 {
+		curve = elliptic.P384()
+	}
+	return ecdsa.GenerateKey(curve, rand.Reader)
+}
+
+func issuerName(ca *issuer) string {
+	if ca == nil {
+		return "themselves"
+	}
+	return ca.cert.Subject.CommonName
+}
+
+// routeCertificate sets a certificate for the given host, signed with the given key or by the CA, in the TLS config
+func routeCertificate(tls *routev1.TLSConfig, host string, key crypto.Signer, ca *issuer) error {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host, Organization: []string{"ingress-perf"}},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	// Key encipherment only applies to RSA key exchanges
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	parent, signer := template, key
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+		tls.CACertificate = ca.certPEM
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return fmt.Errorf("error generating the certificate of %s: %w", host, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	tls.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	tls.Key = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	return nil
+}
+
+// loadIssuer reads the PEM encoded CA certificate and key, the key can be in PKCS8, PKCS1 or SEC 1 format
+func loadIssuer(certFile, keyFile string) (*issuer, error) {
+	block, err := readPEM(certFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA certificate %s: %w", certFile, err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %s isn't a CA", certFile)
+	}
+	if block, err = readPEM(keyFile); err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA key %s: %w", keyFile, err)
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported CA key type %T", key)
+	}
+	return &issuer{
+		cert:    cert,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		key:     key.(crypto.Signer),
+	}, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Cleanup removes the resources created by ingress-perf matching the given uuid or label selector,
+// when none of them is provided, all resources created by ingress-perf are removed
+func Cleanup(uuid, labelSelector string, revert bool, timeout time.Duration) error {
+	if err := initClients(); err != nil {
+		return err
+	}
+	switch {
+	case uuid != "" && labelSelector != "":
+		return fmt.Errorf("uuid and label selector are mutually exclusive")
+	case uuid != "":
+		labelSelector = fmt.Sprintf("%s=%s", uuidLabel, uuid)
+	case labelSelector == "":
+		labelSelector = fmt.Sprintf("%s=%s", managedByLabel, resourceLabels[managedByLabel])
+	}
+	if revert {
+		if err := revertTuning(); err != nil {
+			return err
+		}
+	}
+	return cleanupResources(labelSelector, timeout)
+}
+
+// cleanupResources deletes the routes, namespaces and cluster role bindings matching the label selector
+func cleanupResources(labelSelector string, timeout time.Duration) error {
+	log.Infof("Cleaning up resources matching %s", labelSelector)
+	listOpts := metav1.ListOptions{LabelSelector: labelSelector}
+	// Routes can live out of the benchmark namespaces, i.e: service mesh mode
+	routeList, err := orClientSet.RouteV1().Routes(corev1.NamespaceAll).List(context.TODO(), listOpts)
+	if err != nil {
+		return err
+	}
+	routeNamespaces := make(map[string]bool)
+	for _, r := range routeList.Items {
+		routeNamespaces[r.Namespace] = true
+	}
+	for ns := range routeNamespaces {
+		log.Infof("Deleting routes from namespace %s", ns)
+		if err := orClientSet.RouteV1().Routes(ns).DeleteCollection(context.TODO(), metav1.DeleteOptions{}, listOpts); err != nil {
+			return err
+		}
+	}
+	for _, c := range clusters() {
+		if err := cleanupCluster(c, listOpts, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanupCluster deletes the namespaces and cluster role bindings of a cluster
+func cleanupCluster(c *kubernetes.Clientset, listOpts metav1.ListOptions, timeout time.Duration) error {
+	nsList, err := c.CoreV1().Namespaces().List(context.TODO(), listOpts)
+	if err != nil {
+		return err
+	}
+	for _, ns := range nsList.Items {
+		log.Infof("Deleting namespace %s", ns.Name)
+		err := c.CoreV1().Namespaces().Delete(context.TODO(), ns.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	err = wait.PollUntilContextTimeout(context.TODO(), time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		nsList, err := c.CoreV1().Namespaces().List(ctx, listOpts)
+		if err != nil {
+			return false, err
+		}
+		return len(nsList.Items) == 0, nil
+	})
+	if err != nil {
+		return err
+	}
+	err = c.RbacV1().ClusterRoleBindings().DeleteCollection(context.TODO(), metav1.DeleteOptions{}, listOpts)
+	// Cluster scoped RBAC may be prohibited, in which case ingress-perf didn't create any ClusterRoleBinding
+	if errors.IsForbidden(err) {
+		log.Debugf("Not allowed to delete ClusterRoleBindings: %v", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// clockSkewThreshold above which the clock skew of the client pods is reported
+var clockSkewThreshold = 100 * time.Millisecond
+
+// WithClockSkewThreshold sets the clock skew between the client pods and the runner above which a warning is logged
+func WithClockSkewThreshold(threshold time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		clockSkewThreshold = threshold
+	}
+}
+
+// clockSkew returns the largest clock offset of the client pods relative to the runner. The offset of each pod is
+// estimated as the difference between its clock and the midpoint of the exec round trip, so the measurement error
+// is bounded by half the round trip
+func clockSkew(pods []corev1.Pod) (time.Duration, error) {
+	var maxSkew time.Duration
+	var skewLock sync.Mutex
+	g := errgroup.Group{}
+	g.SetLimit(metricWorkers)
+	for _, pod := range pods {
+		pod := pod
+		g.Go(func() error {
+			start := time.Now()
+			stdout, stderr, err := podExec(context.TODO(), pod, clientName, []string{"date", "+%s%N"})
+			end := time.Now()
+			if err != nil {
+				return fmt.Errorf("couldn't read the clock of pod %s: %v %s", pod.Name, err, stderr)
+			}
+			podNanos, err := strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+			if err != nil {
+				return fmt.Errorf("unexpected date output from pod %s: %s", pod.Name, stdout)
+			}
+			midpoint := start.Add(end.Sub(start) / 2)
+			skew := time.Unix(0, podNanos).Sub(midpoint)
+			log.Debugf("Clock skew of pod %s (node %s): %v ±%v", pod.Name, pod.Spec.NodeName, skew, end.Sub(start)/2)
+			if math.Abs(float64(skew)) > float64(clockSkewThreshold) {
+				log.Warnf("Clock of node %s is %v off the runner clock, time series and metric windows may be misaligned", pod.Spec.NodeName, skew.Truncate(time.Millisecond))
+			}
+			skewLock.Lock()
+			defer skewLock.Unlock()
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew > maxSkew {
+				maxSkew = skew
+			}
+			return nil
+		})
+	}
+	return maxSkew, g.Wait()
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/version"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	controllerRouteLabel     = "app=ingress-perf-controller"
+	defaultIngressController = "default"
+)
+
+// shardedController returns whether the scenario targets an IngressController other than the default one
+func shardedController(cfg config.Config) bool {
+	return cfg.IngressController != "" && cfg.IngressController != defaultIngressController
+}
+
+func controllerRouteName(cfg config.Config) string {
+	return fmt.Sprintf("%s-%s-%s", serverName, cfg.Termination, cfg.IngressController)
+}
+
+// reconcileControllerRoute makes sure the benchmark route of the scenario exists when it targets a sharded
+// IngressController. The route is a copy of the benchmark route with a host in the IngressController domain
+// and the labels of its route selector, the routes namespace also gets the labels of its namespace selector
+func reconcileControllerRoute(cfg config.Config) error {
+	if !shardedController(cfg) {
+		return reconcileRoutes(controllerRouteLabel, nil)
+	}
+	ic, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Get(context.TODO(), cfg.IngressController, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error fetching IngressController %s: %w", cfg.IngressController, err)
+	}
+	domain, _, _ := unstructured.NestedString(ic.Object, "status", "domain")
+	if domain == "" {
+		return fmt.Errorf("IngressController %s has no domain", cfg.IngressController)
+	}
+	routeLabels, err := selectorLabels(ic.Object, "routeSelector")
+	if err != nil {
+		return err
+	}
+	nsLabels, err := selectorLabels(ic.Object, "namespaceSelector")
+	if err != nil {
+		return err
+	}
+	if len(nsLabels) > 0 {
+		patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": nsLabels}})
+		if err != nil {
+			return err
+		}
+		if _, err := clientSet.CoreV1().Namespaces().Patch(context.TODO(), routesNamespace, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+	}
+	var desired []*routev1.Route
+	for _, route := range routes {
+		if route.Name != fmt.Sprintf("%s-%s", serverName, cfg.Termination) {
+			continue
+		}
+		r := route.DeepCopy()
+		r.Name = controllerRouteName(cfg)
+		r.Spec.Host = fmt.Sprintf("%s-%s.%s", r.Name, routesNamespace, domain)
+		r.Spec.Port.TargetPort = routeTargetPort(cfg, route)
+		r.Annotations = routeAnnotations(cfg)
+		for k, v := range route.Annotations {
+			r.Annotations[k] = v
+		}
+		r.Labels = map[string]string{"app": "ingress-perf-controller"}
+		for k, v := range resourceLabels {
+			r.Labels[k] = v
+		}
+		for k, v := range routeLabels {
+			r.Labels[k] = v
+		}
+		desired = append(desired, r)
+	}
+	if len(desired) == 0 {
+		return fmt.Errorf("no %s benchmark route", cfg.Termination)
+	}
+	// The route is recreated for each scenario since its host and labels depend on the IngressController
+	if err := reconcileRoutes(controllerRouteLabel, nil); err != nil {
+		return err
+	}
+	log.Infof("Benchmark route served by IngressController %s with host %s", cfg.IngressController, desired[0].Spec.Host)
+	return reconcileRoutes(controllerRouteLabel, desired)
+}
+
+// selectorLabels returns the match labels of the given IngressController selector, match expressions aren't supported
+func selectorLabels(ic map[string]interface{}, selector string) (map[string]string, error) {
+	if _, found, _ := unstructured.NestedSlice(ic, "spec", selector, "matchExpressions"); found {
+		return nil, fmt.Errorf("IngressController %s uses match expressions, only match labels are supported", selector)
+	}
+	labels, _, err := unstructured.NestedStringMap(ic, "spec", selector, "matchLabels")
+	return labels, err
+}
+
+// queryReplacer templates the routes and client namespaces and the router pods of the scenario IngressController
+// in a query
+func queryReplacer(cfg config.Config) *strings.Replacer {
+	replacements := []string{"ROUTES_NAMESPACE", routesNamespace, "CLIENT_NAMESPACE", benchmarkNs.Name}
+	if shardedController(cfg) {
+		replacements = append(replacements, "router-default", "router-"+cfg.IngressController)
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// routerQueries returns the Prometheus queries of the router of the scenario IngressController,
+// and of the backends in the routes namespace
+func routerQueries(cfg config.Config) map[string]string {
+	replacer := queryReplacer(cfg)
+	queries := make(map[string]string, len(config.PrometheusQueries))
+	for name, query := range config.PrometheusQueries {
+		// The target cluster Prometheus doesn't scrape the clients running in another cluster or out of it
+		if remoteClients() && strings.Contains(query, "CLIENT_NAMESPACE") {
+			continue
+		}
+		queries[name] = replacer.Replace(query)
+	}
+	return queries
+}
+
+// compareControllers compares the summaries of the tests of a comparison group against the first one,
+// the baseline, logging a comparison table. Returns the comparison document
+func compareControllers(uuid string, group int, tests []tools.TestSummary) tools.ControllerComparison {
+	comparison := tools.ControllerComparison{
+		SchemaVersion:   tools.SchemaVersion,
+		UUID:            uuid,
+		Timestamp:       time.Now().UTC(),
+		ComparisonGroup: group,
+		Version:         fmt.Sprintf("%v@%v", version.Version, version.GitCommit),
+	}
+	var baseline *tools.TestSummary
+	for i, t := range tests {
+		if t.Config.ComparisonGroup != group {
+			continue
+		}
+		if baseline == nil {
+			baseline = &tests[i]
+			comparison.Baseline = t.Config.IngressController
+		}
+		comparison.Comparison = append(comparison.Comparison, tools.ControllerResult{
+			IngressController: t.Config.IngressController,
+			TestID:            tools.TestID(uuid, t.Test),
+			Passed:            t.Passed,
+			AvgRps:            t.AvgRps,
+			AvgLatency:        t.AvgLatency,
+			P99Latency:        t.P99Latency,
+			RpsDelta:          deltaPct(t.AvgRps, baseline.AvgRps),
+			AvgLatencyDelta:   deltaPct(t.AvgLatency, baseline.AvgLatency),
+			P99LatencyDelta:   deltaPct(t.P99Latency, baseline.P99Latency),
+		})
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INGRESSCONTROLLER\tRPS\tΔ RPS\tAVG LAT (ms)\tΔ AVG LAT\tP99 LAT (ms)\tΔ P99 LAT")
+	for _, c := range comparison.Comparison {
+		fmt.Fprintf(w, "%s\t%.0f\t%+.1f%%\t%.2f\t%+.1f%%\t%.2f\t%+.1f%%\n", c.IngressController, c.AvgRps, c.RpsDelta,
+			c.AvgLatency/1e3, c.AvgLatencyDelta, c.P99Latency/1e3, c.P99LatencyDelta)
+	}
+	w.Flush()
+	log.Infof("IngressController comparison %d against %s:\n%s", group, comparison.Baseline, sb.String())
+	return comparison
+}
+
+// deltaPct returns the relative difference with the baseline value in percentage
+func deltaPct(value, baseline float64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return (value - baseline) / baseline * 100
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+func TestRouterQueries(t *testing.T) {
+	defer func(ns string) { routesNamespace = ns }(routesNamespace)
+	routesNamespace = "backend-ns"
+	queries := routerQueries(config.Config{IngressController: "sharded"})
+	if len(queries) != len(config.PrometheusQueries) {
+		t.Fatalf("got %d queries, want %d", len(queries), len(config.PrometheusQueries))
+	}
+	for name, query := range queries {
+		if strings.Contains(query, "ROUTES_NAMESPACE") || strings.Contains(query, "CLIENT_NAMESPACE") || strings.Contains(query, "router-default") {
+			t.Errorf("query %s isn't templated: %s", name, query)
+		}
+	}
+	if !strings.Contains(queries["backend_requests_cv"], "exported_namespace='backend-ns'") {
+		t.Errorf("backend_requests_cv doesn't target the routes namespace: %s", queries["backend_requests_cv"])
+	}
+	if !strings.Contains(queries["cpu_seconds_router_pods"], "pod=~'router-sharded.+'") {
+		t.Errorf("cpu_seconds_router_pods doesn't target the sharded router: %s", queries["cpu_seconds_router_pods"])
+	}
+	if !strings.Contains(queries["cpu_throttled_ratio_client_pods"], "namespace='"+benchmarkNs.Name+"'") {
+		t.Errorf("cpu_throttled_ratio_client_pods doesn't target the client namespace: %s", queries["cpu_throttled_ratio_client_pods"])
+	}
+}
+
+func TestRouterQueriesRemoteClients(t *testing.T) {
+	defer func(hosts []string) { externalClients = hosts }(externalClients)
+	externalClients = []string{"loadgen-1"}
+	queries := routerQueries(config.Config{})
+	for _, name := range []string{"max_cpu_utilization_client_nodes", "cpu_throttled_ratio_client_pods", "energy_joules_client_nodes"} {
+		if _, ok := queries[name]; ok {
+			t.Errorf("query %s of the client pods isn't skipped with external clients", name)
+		}
+	}
+	if _, ok := queries["avg_cpu_usage_router_pods"]; !ok {
+		t.Error("router queries must be kept with external clients")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/prometheus"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Number of log lines displayed at the bottom of the dashboard
+	dashboardLogLines = 8
+	// Number of client results of the current test displayed as they're received
+	dashboardClientLines = 10
+	// ANSI sequences to move the cursor home and clear the screen
+	clearScreen = "\033[H\033[2J"
+)
+
+// Live queries, templated like the sample queries. The router only exposes aggregated counters, so throughput and
+// latency are the ones seen by HAProxy
+var dashboardQueries = []struct {
+	name  string
+	query string
+	unit  string
+}{
+	{"Router RPS", "sum(rate(haproxy_server_http_responses_total{exported_namespace='ROUTES_NAMESPACE'}[1m]))", "req/s"},
+	{"Router avg latency", "avg(haproxy_server_http_average_response_latency_milliseconds{exported_namespace='ROUTES_NAMESPACE'})", "ms"},
+	{"Router pods CPU", "sum(irate(container_cpu_usage_seconds_total{name!='', namespace='openshift-ingress', pod=~'router-default.+'}[2m]))", "cores"},
+}
+
+// clientLine is a result of a client process, of a whole sample or of one of its result intervals
+type clientLine struct {
+	received time.Time
+	sample   int
+	interval int
+	pod      string
+	result   tools.PodResult
+}
+
+// dashboard renders a live view of the run in the terminal: progress, router metrics, client results as they're
+// received, completed samples and latest logs
+type dashboard struct {
+	lock     sync.Mutex
+	out      io.Writer
+	prom     *prometheus.Prometheus
+	replacer *strings.Replacer
+	live     map[string]float64
+	clients  []clientLine
+	samples  []tools.Result
+	logs     []string
+}
+
+// liveDashboard is nil when the dashboard is disabled
+var liveDashboard *dashboard
+
+func newDashboard(p *prometheus.Prometheus) *dashboard {
+	d := &dashboard{
+		out:      os.Stdout,
+		prom:     p,
+		replacer: strings.NewReplacer(),
+		live:     make(map[string]float64),
+	}
+	log.AddHook(d)
+	return d
+}
+
+// Levels implements logrus.Hook
+func (d *dashboard) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel, log.InfoLevel}
+}
+
+// Fire implements logrus.Hook, it keeps the latest log lines
+func (d *dashboard) Fire(entry *log.Entry) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.logs = append(d.logs, fmt.Sprintf("%s %-5s %s", entry.Time.Format("15:04:05"), entry.Level, entry.Message))
+	if len(d.logs) > dashboardLogLines {
+		d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+	}
+	return nil
+}
+
+// startTest clears the results of the previous test and targets the router of the given scenario
+func (d *dashboard) startTest(cfg config.Config) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.replacer = queryReplacer(cfg)
+	d.clients = nil
+	d.samples = nil
+	d.live = make(map[string]float64)
+}
+
+// addClientResult adds the result of a client process as soon as it's received, interval is 0 for the result of
+// the whole sample
+func (d *dashboard) addClientResult(sample, interval int, pod string, result tools.PodResult) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.clients = append(d.clients, clientLine{received: time.Now(), sample: sample, interval: interval, pod: pod, result: result})
+	if len(d.clients) > dashboardClientLines {
+		d.clients = d.clients[len(d.clients)-dashboardClientLines:]
+	}
+}
+
+// addSample adds a completed sample of the current test
+func (d *dashboard) addSample(result tools.Result) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.samples = append(d.samples, result)
+}
+
+// run refreshes the dashboard with the given interval until the context is cancelled
+func (d *dashboard) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.query()
+		d.render()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// Keep the final state on screen
+			d.render()
+			return
+		}
+	}
+}
+
+func (d *dashboard) query() {
+	d.lock.Lock()
+	replacer := d.replacer
+	d.lock.Unlock()
+	for _, q := range dashboardQueries {
+		value, err := d.prom.Query(replacer.Replace(q.query), time.Now().UTC())
+		if err != nil {
+			log.Debugf("Dashboard query error: %v", err)
+			continue
+		}
+		if data, ok := value.(model.Vector); ok && len(data) > 0 {
+			d.lock.Lock()
+			d.live[q.name] = float64(data[0].Value)
+			d.lock.Unlock()
+		}
+	}
+}
+
+func (d *dashboard) render() {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	fmt.Fprintf(&b, "ingress-perf %s\n\n", runProgress.String())
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, q := range dashboardQueries {
+		fmt.Fprintf(&b, "%-20s %10.1f %s\n", q.name, d.live[q.name], q.unit)
+	}
+	fmt.Fprintf(&b, "\n%-8s %-8s %-9s %-40s %12s %14s %14s %10s %12s\n", "TIME", "SAMPLE", "INTERVAL", "CLIENT POD", "RPS", "AVG LAT (ms)", "P99 LAT (ms)", "TIMEOUTS", "HTTP ERRORS")
+	for _, c := range d.clients {
+		interval := "-"
+		if c.interval > 0 {
+			interval = fmt.Sprint(c.interval)
+		}
+		fmt.Fprintf(&b, "%-8s %-8d %-9s %-40s %12.0f %14.2f %14.2f %10d %12d\n", c.received.Format("15:04:05"), c.sample, interval, c.pod,
+			c.result.AvgRps, c.result.AvgLatency/1e3, c.result.P99Latency/1e3, c.result.Timeouts, c.result.HTTPErrors)
+	}
+	fmt.Fprintf(&b, "\n%-8s %12s %14s %14s %14s %10s %12s\n", "SAMPLE", "RPS", "AVG LAT (ms)", "P95 LAT (ms)", "P99 LAT (ms)", "TIMEOUTS", "HTTP ERRORS")
+	for _, s := range d.samples {
+		fmt.Fprintf(&b, "%-8d %12.0f %14.2f %14.2f %14.2f %10d %12d\n",
+			s.Sample, s.TotalAvgRps, s.AvgLatency/1e3, s.P95Latency/1e3, s.P99Latency/1e3, s.Timeouts, s.HTTPErrors)
+	}
+	b.WriteString("\n")
+	for _, l := range d.logs {
+		b.WriteString(l + "\n")
+	}
+	fmt.Fprint(d.out, b.String())
+}
@@ -0,0 +1,242 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// routerSelector selects the pods of the default router
+const routerSelector = "ingresscontroller.operator.openshift.io/deployment-ingresscontroller=default"
+
+// Log lines collected from each router and ingress operator container
+const diagnosticsLogLines = 10000
+
+var (
+	// diagnosticsDir directory where the diagnostics bundles of the failed tests are written, disabled when empty
+	diagnosticsDir string
+	// diagnosed bundles already collected in the run, a test is diagnosed at most once
+	diagnosed = make(map[string]bool)
+)
+
+// WithDiagnostics collects a diagnostics bundle in the given directory when a test fails or has failed samples:
+// router and ingress operator logs, events of the benchmark namespaces and the pods that aren't running
+func WithDiagnostics(dir string) OptsFunctions {
+	return func(r *Runner) {
+		diagnosticsDir = dir
+		diagnosed = make(map[string]bool)
+	}
+}
+
+// collectDiagnostics writes the diagnostics bundle of the current test, or the run when no test is executing.
+// Collection is best effort, errors are logged and the remaining items are still collected
+func collectDiagnostics(reason string) {
+	if diagnosticsDir == "" || clientSet == nil {
+		return
+	}
+	name := "run"
+	if currentTest > 0 {
+		name = fmt.Sprintf("test-%d", currentTest)
+	}
+	if diagnosed[name] {
+		return
+	}
+	diagnosed[name] = true
+	dir := path.Join(diagnosticsDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Couldn't create diagnostics directory: %v", err)
+		return
+	}
+	log.Infof("Collecting diagnostics bundle in %s: %s", dir, reason)
+	if err := os.WriteFile(path.Join(dir, "reason.txt"), []byte(reason+"\n"), 0644); err != nil {
+		log.Errorf("Couldn't write diagnostics reason: %v", err)
+	}
+	collectLogs(dir, routerNs, routerSelector, "router", nil)
+	collectLogs(dir, ingressOperatorNs, "", "ingress-operator", nil)
+	namespaces, err := diagnosticsNamespaces()
+	if err != nil {
+		log.Errorf("Couldn't list the benchmark namespaces: %v", err)
+		return
+	}
+	for _, ns := range namespaces {
+		collectEvents(dir, ns, time.Time{})
+		collectPendingPods(dir, ns)
+	}
+}
+
+// diagnosticsNamespaces returns the namespaces created by the run plus the routes namespace
+func diagnosticsNamespaces() ([]string, error) {
+	namespaces := []string{benchmarkNs.Name}
+	seen := map[string]bool{benchmarkNs.Name: true, routesNamespace: true}
+	if routesNamespace != benchmarkNs.Name {
+		namespaces = append(namespaces, routesNamespace)
+	}
+	nsList, err := clientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", uuidLabel, resourceLabels[uuidLabel]),
+	})
+	if err != nil {
+		return namespaces, err
+	}
+	for _, ns := range nsList.Items {
+		if !seen[ns.Name] {
+			seen[ns.Name] = true
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces, nil
+}
+
+// collectLogs writes the last lines of the given container, or all the containers when empty, of the pods matching
+// the selector. When since is set only the lines logged afterwards are collected. Returns the files written
+func collectLogs(dir, ns, selector, container string, since *metav1.Time) []string {
+	var files []string
+	podList, err := clientSet.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		log.Errorf("Couldn't list pods in namespace %s: %v", ns, err)
+		return files
+	}
+	tailLines := int64(diagnosticsLogLines)
+	for _, pod := range podList.Items {
+		containers := []string{container}
+		if container == "" {
+			containers = nil
+			for _, c := range pod.Spec.Containers {
+				containers = append(containers, c.Name)
+			}
+		}
+		for _, c := range containers {
+			logs, err := clientSet.CoreV1().Pods(ns).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: c,
+				TailLines: &tailLines,
+				SinceTime: since,
+			}).DoRaw(context.TODO())
+			if err != nil {
+				log.Errorf("Couldn't fetch logs of pod %s/%s: %v", ns, pod.Name, err)
+				continue
+			}
+			file := path.Join(dir, fmt.Sprintf("%s-%s-%s.log", ns, pod.Name, c))
+			if err := os.WriteFile(file, logs, 0644); err != nil {
+				log.Errorf("Couldn't write logs of pod %s/%s: %v", ns, pod.Name, err)
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// collectEvents writes the events of the namespace in a table, skipping those last seen before since when it's set.
+// Returns the file written, if any
+func collectEvents(dir, ns string, since time.Time) string {
+	eventList, err := clientSet.CoreV1().Events(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("Couldn't list events in namespace %s: %v", ns, err)
+		return ""
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST SEEN\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	for _, e := range eventList.Items {
+		lastSeen := e.LastTimestamp.Time
+		if lastSeen.IsZero() {
+			lastSeen = e.EventTime.Time
+		}
+		if lastSeen.Before(since) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%d\t%s\n", lastSeen.UTC().Format("2006-01-02T15:04:05Z"), e.Type, e.Reason,
+			strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name, e.Count, e.Message)
+	}
+	w.Flush()
+	file := path.Join(dir, fmt.Sprintf("%s-events.txt", ns))
+	if err := os.WriteFile(file, []byte(sb.String()), 0644); err != nil {
+		log.Errorf("Couldn't write events of namespace %s: %v", ns, err)
+		return ""
+	}
+	return file
+}
+
+// collectPendingPods writes the manifest, including the status, of the pods that aren't running and ready
+func collectPendingPods(dir, ns string) {
+	podList, err := clientSet.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("Couldn't list pods in namespace %s: %v", ns, err)
+		return
+	}
+	var pending []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && !podReady(pod) {
+			pod.ManagedFields = nil
+			pending = append(pending, pod)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	data, err := yaml.Marshal(pending)
+	if err != nil {
+		log.Errorf("Couldn't encode pods of namespace %s: %v", ns, err)
+		return
+	}
+	if err := os.WriteFile(path.Join(dir, fmt.Sprintf("%s-pending-pods.yaml", ns)), data, 0644); err != nil {
+		log.Errorf("Couldn't write pods of namespace %s: %v", ns, err)
+	}
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// captureErrorSpike captures the router logs, all their containers so the access logs sidecar is included,
+// and the events of the router and benchmark namespaces of a sample window. Returns the files captured
+func captureErrorSpike(sampleID string, start, end time.Time) []string {
+	if diagnosticsDir == "" {
+		return nil
+	}
+	dir := path.Join(diagnosticsDir, sampleID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Couldn't create capture directory: %v", err)
+		return nil
+	}
+	log.Infof("Capturing router logs and events from %s to %s in %s", start.Format(time.RFC3339), end.Format(time.RFC3339), dir)
+	since := metav1.NewTime(start)
+	files := collectLogs(dir, routerNs, routerSelector, "", &since)
+	namespaces, err := diagnosticsNamespaces()
+	if err != nil {
+		log.Errorf("Couldn't list the benchmark namespaces: %v", err)
+	}
+	for _, ns := range append([]string{routerNs}, namespaces...) {
+		if file := collectEvents(dir, ns, start); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// routerAddress returns the address and port the clients connect to in order to reach the router directly, bypassing
+// DNS. With auto, the address is discovered from the endpoint publishing strategy of the default ingress controller
+func routerAddress(cfg config.Config) (string, string, error) {
+	if cfg.RouterAddress != config.RouterAddressAuto {
+		return cfg.RouterAddress, port(cfg), nil
+	}
+	strategy, _, err := getEndpointPublishingStrategy()
+	if err != nil {
+		return "", "", err
+	}
+	switch strategy {
+	case "LoadBalancerService":
+		svc, err := clientSet.CoreV1().Services(routerNs).Get(context.TODO(), routerDeployment(cfg), metav1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return ingress.IP, port(cfg), nil
+			}
+			if ingress.Hostname != "" {
+				return ingress.Hostname, port(cfg), nil
+			}
+		}
+		return "", "", fmt.Errorf("service %s/%s has no load balancer address", routerNs, routerDeployment(cfg))
+	case "NodePortService":
+		svc, err := clientSet.CoreV1().Services(routerNs).Get(context.TODO(), "router-nodeport-default", metav1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		pod, err := routerPod()
+		if err != nil {
+			return "", "", err
+		}
+		portName := "http"
+		if cfg.Termination != "http" {
+			portName = "https"
+		}
+		for _, p := range svc.Spec.Ports {
+			if p.Name == portName {
+				return pod.Status.HostIP, strconv.Itoa(int(p.NodePort)), nil
+			}
+		}
+		return "", "", fmt.Errorf("service %s/%s has no %s port", routerNs, svc.Name, portName)
+	default:
+		// Router pods listen on the standard ports, in the host network with the HostNetwork strategy
+		pod, err := routerPod()
+		if err != nil {
+			return "", "", err
+		}
+		return pod.Status.PodIP, port(cfg), nil
+	}
+}
+
+// routerPod returns a running pod of the default router
+func routerPod() (corev1.Pod, error) {
+	podList, err := clientSet.CoreV1().Pods(routerNs).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "ingresscontroller.operator.openshift.io/deployment-ingresscontroller=default",
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+	if len(podList.Items) == 0 {
+		return corev1.Pod{}, fmt.Errorf("no running router pods found")
+	}
+	return podList.Items[0], nil
+}
+
+// directTargets points the targets to the router address, keeping the route host in the Host header
+func directTargets(cfg config.Config, targets []target) error {
+	address, p, err := routerAddress(cfg)
+	if err != nil {
+		return fmt.Errorf("couldn't get the router address: %w", err)
+	}
+	log.Infof("Targeting the router directly at %s:%s", address, p)
+	for i := range targets {
+		targets[i].address = address
+		targets[i].url = endpoint(cfg, net.JoinHostPort(address, p))
+		targets[i].headers = append(targets[i].headers, fmt.Sprintf("Host: %s", targets[i].host))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	routerNs = "openshift-ingress"
+	// Max time to wait for the router deployment to recover after a node drain
+	drainRecoveryTimeout = 5 * time.Minute
+)
+
+// routerDeployment returns the name of the router deployment of the IngressController targeted by the scenario
+func routerDeployment(cfg config.Config) string {
+	if shardedController(cfg) {
+		return "router-" + cfg.IngressController
+	}
+	return "router-" + defaultIngressController
+}
+
+// drainRouterNode waits for the drainRouterNode delay, then cordons and drains the node running one of the router
+// pods of the scenario, and finally measures the time the router deployment takes to recover all its replicas.
+// The benchmark pods are kept, so the sample measures the router disruption only
+func drainRouterNode(ctx context.Context, cfg config.Config) (*tools.DrainResult, error) {
+	select {
+	case <-time.After(cfg.DrainRouterNode):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	podList, err := clientSet.CoreV1().Pods(routerNs).List(ctx, metav1.ListOptions{
+		LabelSelector: routerPodSelector(cfg),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(podList.Items) == 0 {
+		return nil, fmt.Errorf("no running router pods found")
+	}
+	drain := &tools.DrainResult{
+		Node:      podList.Items[0].Spec.NodeName,
+		Timestamp: time.Now().UTC(),
+	}
+	log.Infof("Draining router node %s", drain.Node)
+	if err := cordon(drain.Node, true); err != nil {
+		return drain, err
+	}
+	nodePods, err := clientSet.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", drain.Node),
+	})
+	if err != nil {
+		return drain, err
+	}
+	for _, pod := range nodePods.Items {
+		if !evictable(pod) || pod.Namespace == benchmarkNs.Name || pod.Namespace == routesNamespace {
+			continue
+		}
+		err := clientSet.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Warnf("Couldn't evict pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		drain.EvictedPods++
+	}
+	// The router operator takes some time to notice the evicted replicas
+	time.Sleep(time.Second)
+	if err := waitForDeploymentContext(ctx, clientSet, routerNs, routerDeployment(cfg), drainRecoveryTimeout); err != nil {
+		// The recovery time isn't indexed when the sample ends first
+		if ctx.Err() != nil {
+			log.Warnf("Sample ended before the router deployment recovered from the drain of node %s", drain.Node)
+			return drain, nil
+		}
+		return drain, err
+	}
+	drain.RecoveryTime = time.Since(drain.Timestamp).Seconds()
+	log.Infof("Router deployment recovered in %.1fs after draining node %s", drain.RecoveryTime, drain.Node)
+	return drain, nil
+}
+
+// evictable skips mirror pods, DaemonSet pods and already finished pods, same as kubectl drain does
+func evictable(pod corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+func cordon(node string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%v}}`, unschedulable)
+	_, err := clientSet.CoreV1().Nodes().Patch(context.TODO(), node, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// uncordonNode makes the drained node schedulable again
+func uncordonNode(node string) error {
+	log.Infof("Uncordoning node %s", node)
+	return cordon(node, false)
+}
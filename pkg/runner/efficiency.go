@@ -0,0 +1,58 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	routerCPUSeconds = "cpu_seconds_router_pods"
+	routerEnergy     = "energy_joules_router_nodes"
+	clientEnergy     = "energy_joules_client_nodes"
+)
+
+// routerEfficiency derives the capacity planning units of the sample from the client requests and the CPU
+// seconds consumed by the router pods: requests per second per router core, which is the same as requests per
+// core-second, and router CPU milliseconds per 1k requests
+func routerEfficiency(result *tools.Result) {
+	cpuSeconds := result.InfraMetrics[routerCPUSeconds]
+	if cpuSeconds <= 0 || result.Requests == 0 {
+		return
+	}
+	result.RpsPerRouterCore = float64(result.Requests) / cpuSeconds
+	result.RouterCPUPer1kReq = cpuSeconds * 1e6 / float64(result.Requests)
+	log.Infof("Router efficiency: %.0f rps per core, %.2f CPU ms per 1k requests", result.RpsPerRouterCore, result.RouterCPUPer1kReq)
+}
+
+// energyEfficiency derives the joules per million requests of the router nodes and of the router and client
+// nodes together, from the energy reported by Kepler. Nothing is computed when Kepler isn't deployed, and the
+// client nodes are left out when the clients don't run in the target cluster
+func energyEfficiency(result *tools.Result) {
+	routerJoules, ok := result.InfraMetrics[routerEnergy]
+	if !ok || result.Requests == 0 {
+		return
+	}
+	mRequests := float64(result.Requests) / 1e6
+	result.RouterJoulesPerMReq = routerJoules / mRequests
+	clientJoules, ok := result.InfraMetrics[clientEnergy]
+	if !ok {
+		log.Infof("Energy: %.0f J per million requests in router nodes", result.RouterJoulesPerMReq)
+		return
+	}
+	result.JoulesPerMReq = (routerJoules + clientJoules) / mRequests
+	log.Infof("Energy: %.0f J per million requests, %.0f J in router nodes", result.JoulesPerMReq, result.RouterJoulesPerMReq)
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// Event types
+const (
+	EventTest     = "test"
+	EventSample   = "sample"
+	EventPhase    = "phase"
+	EventProgress = "progress"
+	EventResult   = "result"
+	EventFinished = "finished"
+)
+
+// eventHandler receives the run events, nil when events are disabled
+var eventHandler func(tools.Event)
+
+// emit sends an event of the current test and sample to the event handler
+func emit(eventType string, sample int, data interface{}) {
+	if eventHandler == nil {
+		return
+	}
+	eventHandler(tools.Event{
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Test:      currentTest,
+		Sample:    sample,
+		Data:      data,
+	})
+}
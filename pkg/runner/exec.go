@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 	"time"
@@ -25,181 +26,677 @@ import (
 	"github.com/cloud-bulldozer/go-commons/prometheus"
 	"github.com/cloud-bulldozer/go-commons/version"
 	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	ilog "github.com/cloud-bulldozer/ingress-perf/pkg/log"
 	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
 	"github.com/prometheus/common/model"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/utils/ptr"
 )
 
 var lock = &sync.Mutex{}
 
+// maxToolErrors bounds the number of distinct tool errors indexed per sample
+const maxToolErrors = 20
+
+// metricWorkers bounds the number of concurrent Prometheus queries of a sample
+const metricWorkers = 10
+
+// stopTimeout bounds the time taken to kill the tool processes of an interrupted sample
+const stopTimeout = 30 * time.Second
+
 func runBenchmark(cfg config.Config, clusterMetadata tools.ClusterMetadata, p *prometheus.Prometheus, podMetrics bool) ([]tools.Result, error) {
-	var aggAvgRps, aggAvgLatency, aggP95Latency float64
-	var timeouts, httpErrors int64
 	var benchmarkResult []tools.Result
 	var clientPods []corev1.Pod
-	var ep string
-	r, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), fmt.Sprintf("%s-%s", serverName, cfg.Termination), metav1.GetOptions{})
+	r, err := benchmarkRoute(cfg)
 	if err != nil {
 		return benchmarkResult, err
 	}
-	allClientPods, err := clientSet.CoreV1().Pods(benchmarkNs.Name).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("app=%s", clientName),
-	})
+	if clientPods, err = benchmarkClientPods(cfg); err != nil {
+		return benchmarkResult, err
+	}
+	clusterMetadata.ClientImageDigests = imageDigests(clientPods)
+	if clusterMetadata.ServerImageDigests, err = serverImageDigests(); err != nil {
+		log.Warnf("Couldn't fetch the server image digests: %v", err)
+	}
+	clientNodes := make(map[string]bool)
+	for _, pod := range clientPods {
+		clientNodes[pod.Spec.NodeName] = true
+	}
+	// Instance types are fetched once per node rather than once per pod and sample
+	var instanceTypes map[string]string
+	var clientZones []string
+	// External clients don't run in cluster nodes
+	if len(externalClients) == 0 {
+		if instanceTypes, err = nodeInstanceTypes(clientNodes); err != nil {
+			return benchmarkResult, err
+		}
+		// Zone topology of the benchmark, so cross-zone latency penalties can be quantified
+		if clientZones, err = nodeZones(clientCluster, clientNodes); err != nil {
+			return benchmarkResult, err
+		}
+	}
+	rZones, err := routerZones(cfg)
+	if err != nil {
+		log.Warnf("Couldn't fetch the router zones: %v", err)
+	}
+	// With noExec each measurement includes the startup of a pod, so the skew can't be estimated
+	var skew time.Duration
+	if !noExec {
+		if skew, err = clockSkew(clientPods); err != nil {
+			log.Warnf("Clock skew detection failed: %v", err)
+		}
+	}
+	targets, err := buildTargets(cfg, r.Spec.Host, clientPods[0], len(clientPods)*cfg.Procs)
 	if err != nil {
 		return benchmarkResult, err
 	}
-	// Filter out pods in terminating state from the list
-	for _, p := range allClientPods.Items {
-		if p.DeletionTimestamp == nil {
-			clientPods = append(clientPods, p)
+	var handshakeLatency float64
+	// The handshake cost depends on the certificate key as well
+	if cfg.Termination != "http" && (cfg.TLSVersion != "" || cfg.CipherSuites != "" || cfg.Certificate != nil) {
+		if handshakeLatency, err = measureHandshake(cfg, targets[0].url, clientPods[0]); err != nil {
+			return benchmarkResult, err
 		}
-		if len(clientPods) == int(cfg.Concurrency) {
-			break
+	}
+	var ingressVer string
+	if envoyIngress(cfg) {
+		if ingressVer, err = ingressVersion(cfg); err != nil {
+			log.Warnf("Couldn't fetch the %s ingress version: %v", cfg.Ingress, err)
 		}
 	}
+	var assignments []config.RequestTarget
+	if len(cfg.RequestMix) > 0 {
+		assignments = mixAssignments(cfg.RequestMix, len(clientPods)*cfg.Procs)
+	}
+	if cfg.FreshClients && (len(externalClients) > 0 || noExec) {
+		log.Warn("freshClients is ignored with external clients and noExec")
+	}
 	ts := time.Now().UTC()
+	var watchdogRetries int
 	for i := 1; i <= cfg.Samples; i++ {
+		if cfg.FreshClients && len(externalClients) == 0 && !noExec {
+			setPhase("clients")
+			if clientPods, err = recreateClientPods(cfg, clientPods); err != nil {
+				return benchmarkResult, err
+			}
+			if err := addInstanceTypes(instanceTypes, clientPods); err != nil {
+				return benchmarkResult, err
+			}
+		}
 		sampleTs := time.Now().UTC()
 		result := tools.Result{
-			UUID:            cfg.UUID,
-			Sample:          i,
-			Config:          cfg,
-			Timestamp:       ts,
-			ClusterMetadata: clusterMetadata,
-			InfraMetrics:    make(map[string]float64),
-		}
-		result.Config.Tuning = currentTuning // It's useful to index the current tuning patch in the all benchmark's documents
+			SchemaVersion:       tools.SchemaVersion,
+			UUID:                cfg.UUID,
+			Test:                currentTest,
+			TestID:              tools.TestID(cfg.UUID, currentTest),
+			Sample:              i,
+			SampleID:            tools.SampleID(cfg.UUID, currentTest, i),
+			Config:              cfg,
+			Timestamp:           ts,
+			ClusterMetadata:     clusterMetadata,
+			InfraMetrics:        make(map[string]float64),
+			AvgHandshakeLatency: handshakeLatency,
+			CertificateKey:      certificateKey(cfg),
+			IngressVersion:      ingressVer,
+			ClockSkew:           float64(skew.Microseconds()) / 1e3,
+			RouterZones:         rZones,
+			ClientZones:         clientZones,
+		}
+		// The node count is only meaningful when the clients are spread
+		if cfg.ClientSpread {
+			result.ClientNodes = len(clientNodes)
+		}
+		result.Config.Tuning = currentTuning[tunedController(cfg)] // It's useful to index the current tuning patch in the all benchmark's documents
+		currentSample = i
+		ilog.SetField("sample", i)
+		runTracer.startSample(i)
+		setPhase("benchmark")
+		heartbeat()
 		log.Infof("Running sample %d/%d: %v", i, cfg.Samples, cfg.Duration)
-		errGroup := errgroup.Group{}
+		runProgress.startSample(i, cfg)
+		liveMetrics.startSample(i)
+		emit(EventSample, i, nil)
+		emit(EventProgress, i, runProgress.String())
+		drainCtx, cancelDrain := context.WithCancel(context.TODO())
+		drainErrGroup := errgroup.Group{}
+		if cfg.DrainRouterNode != 0 {
+			drainErrGroup.Go(func() error {
+				var err error
+				result.Drain, err = drainRouterNode(drainCtx, cfg)
+				return err
+			})
+		}
+		var routerStatsDone chan []tools.RouterStat
+		routerStatsCtx, cancelRouterStats := context.WithCancel(context.TODO())
+		if cfg.RouterStatsInterval != 0 {
+			routerStatsDone = make(chan []tools.RouterStat, 1)
+			go func() {
+				routerStatsDone <- sampleRouterStats(routerStatsCtx, cfg)
+			}()
+		}
+		// Client processes start at the same instant, the measurement window starts with them
+		var startAt time.Time
+		if cfg.StartBarrier != 0 {
+			startAt = time.Now().Add(cfg.StartBarrier)
+			sampleTs = startAt.UTC()
+		}
+		// The sample context is canceled as soon as a client process fails, i.e. when it exceeds maxErrorRatio,
+		// or when the watchdog deadline expires
+		watchdogCtx, cancelWatchdog := sampleContext(cfg)
+		wd := newWatchdog()
+		errGroup, sampleCtx := errgroup.WithContext(watchdogCtx)
+		var procIdx int
 		for _, pod := range clientPods {
 			for i := 0; i < cfg.Procs; i++ {
+				// Distribute client processes across the targets in a round-robin fashion
+				t := targets[procIdx%len(targets)]
+				url, label := t.url, ""
+				c := cfg
+				c.Headers = t.headers
+				c.Seed = runSeed + int64(procIdx)
+				if assignments != nil {
+					rt := assignments[procIdx]
+					url, label = mixURL(t.url, cfg.Path, rt), rt.Label()
+					c.Method, c.BodySize = rt.Method, rt.BodySize
+				}
+				procIdx++
 				func(p corev1.Pod) {
 					errGroup.Go(func() error {
-						if cfg.Termination == "http" {
-							ep = fmt.Sprintf("http://%v%v", r.Spec.Host, cfg.Path)
-						} else {
-							ep = fmt.Sprintf("https://%v%v", r.Spec.Host, cfg.Path)
+						wd.start(p.Name)
+						defer func() {
+							// Executions interrupted by the watchdog are the hung ones
+							if watchdogCtx.Err() == nil {
+								wd.done(p.Name)
+							}
+						}()
+						if c.ResultInterval != 0 {
+							return execIntervals(sampleCtx, c, url, p, t.tenant, label, instanceTypes[p.Spec.NodeName], startAt, &result)
 						}
-						tool, err := tools.New(cfg, ep)
+						tool, err := tools.New(c, url)
 						if err != nil {
 							return err
 						}
+						tool = withBarrier(tool, startAt)
 						log.Debugf("Running %v in client pods", tool.Cmd())
-						return exec(context.TODO(), tool, p, &result)
+						recordCommand(result.Sample, p.Name, url, tool.Cmd())
+						return exec(sampleCtx, tool, p, t.tenant, label, instanceTypes[p.Spec.NodeName], &result)
 					})
 				}(pod)
 			}
 		}
-		if err = errGroup.Wait(); err != nil {
+		err = errGroup.Wait()
+		expired := watchdogCtx.Err() == context.DeadlineExceeded
+		cancelWatchdog()
+		benchmarkEnd := time.Now().UTC()
+		runProgress.endSample()
+		annotator.annotateSample(currentTest, i, cfg, sampleTs, time.Now())
+		cancelDrain()
+		cancelRouterStats()
+		if routerStatsDone != nil {
+			result.RouterStats = <-routerStatsDone
+		}
+		if drainErr := drainErrGroup.Wait(); drainErr != nil && drainErr != context.Canceled {
+			log.Errorf("Router node drain failed: %v", drainErr)
+		}
+		if result.Drain != nil {
+			if err := uncordonNode(result.Drain.Node); err != nil {
+				return benchmarkResult, err
+			}
+		}
+		if hung := wd.hung(); expired && len(hung) > 0 {
+			log.Errorf("Sample %d exceeded its expected duration by %v, hung client pods: %s", i, cfg.WatchdogMargin, strings.Join(hung, ", "))
+			// Hung processes are killed before retrying, pods that can't be restarted would keep running them
+			stopClientProcesses(filterPods(clientPods, hung))
+			if err := restartClientPods(hung); err != nil {
+				return benchmarkResult, err
+			}
+			if clientPods, err = benchmarkClientPods(cfg); err != nil {
+				return benchmarkResult, err
+			}
+			if err := addInstanceTypes(instanceTypes, clientPods); err != nil {
+				return benchmarkResult, err
+			}
+			if watchdogRetries < cfg.WatchdogRetries {
+				watchdogRetries++
+				log.Warnf("Retrying sample %d, attempt %d/%d", i, watchdogRetries, cfg.WatchdogRetries)
+				i--
+				continue
+			}
+			err = fmt.Errorf("sample interrupted by the watchdog")
+		}
+		watchdogRetries = 0
+		if err != nil {
 			log.Errorf("Errors found during execution, skipping sample: %s", err)
+			stopClientProcesses(clientPods)
+			liveMetrics.failSample()
 			continue
 		}
+		setPhase("metrics")
+		// Metric queries overlap with the aggregation of the client results
+		metricsDone := make(chan map[string]float64)
+		go func() {
+			metricsDone <- queryMetrics(p, sampleQueries(cfg), sampleTs, benchmarkEnd)
+		}()
 		normalizeResults(&result)
+		if errs := result.HTTPErrors + result.Timeouts; cfg.ErrorCaptureThreshold > 0 && errs > cfg.ErrorCaptureThreshold {
+			log.Warnf("Sample errors (%d) exceeded errorCaptureThreshold (%d)", errs, cfg.ErrorCaptureThreshold)
+			result.ErrorCaptures = captureErrorSpike(result.SampleID, sampleTs, benchmarkEnd)
+		}
+		rateLimitResult(cfg, &result)
 		if !podMetrics {
 			result.Pods = nil
 		}
-		aggAvgRps += result.TotalAvgRps
-		aggAvgLatency += result.AvgLatency
-		aggP95Latency += result.P95Latency
-		timeouts += result.Timeouts
-		httpErrors += result.HTTPErrors
-		elapsed := fmt.Sprintf("%ds", int(time.Since(sampleTs).Seconds()))
-		for field, query := range config.PrometheusQueries {
-			promQuery := strings.ReplaceAll(query, "ELAPSED", elapsed)
+		for field, value := range <-metricsDone {
+			result.InfraMetrics[field] = value
+		}
+		if cfg.Tool == "idle" {
+			idleConnections(&result)
+		}
+		trafficSplit(cfg, &result)
+		routerEfficiency(&result)
+		energyEfficiency(&result)
+		if clientSaturated(result.InfraMetrics) {
+			result.ClientSaturated = true
+			log.Warnf("Client pods were saturated during the sample, results may reflect the client capacity rather than the router one: node CPU=%.0f%% throttled periods=%.0f%%",
+				result.InfraMetrics["max_cpu_utilization_client_nodes"]*100, result.InfraMetrics["cpu_throttled_ratio_client_pods"]*100)
+		}
+		log.Infof("%s: Rps=%.0f avgLatency=%.0fms P95Latency=%.0fms", cfg.Termination, result.TotalAvgRps, result.AvgLatency/1e3, result.P95Latency/1e3)
+		recordResult(result)
+		benchmarkResult = append(benchmarkResult, result)
+		liveDashboard.addSample(result)
+		liveMetrics.addSample(result)
+		emit(EventResult, i, result)
+		if cfg.Delay != 0 {
+			log.Info("Sleeping for ", cfg.Delay)
+			time.Sleep(cfg.Delay)
+		}
+	}
+	currentSample = 0
+	ilog.SetField("sample", nil)
+	runTracer.endSample()
+	summary := tools.TestSummary{Config: cfg}
+	summarizeTest(&summary, benchmarkResult)
+	log.Infof("Scenario summary %s (%s of %d samples): Rps=%.0f avgLatency=%.0fms P95Latency=%.0fms timeouts=%d http_errors=%d",
+		cfg.Termination,
+		summary.Aggregation,
+		summary.Samples,
+		summary.AvgRps,
+		summary.AvgLatency/1e3,
+		summary.P95Latency/1e3,
+		summary.Timeouts,
+		summary.HTTPErrors,
+	)
+	return benchmarkResult, nil
+}
+
+// benchmarkClientPods returns the client pods of the test, up to its concurrency, or the external clients
+func benchmarkClientPods(cfg config.Config) ([]corev1.Pod, error) {
+	if len(externalClients) > 0 {
+		return externalClientPods(cfg), nil
+	}
+	var clientPods []corev1.Pod
+	allClientPods, err := clientCluster.CoreV1().Pods(benchmarkNs.Name).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", clientName),
+	})
+	if err != nil {
+		return clientPods, err
+	}
+	// Filter out pods in terminating state from the list
+	for _, p := range allClientPods.Items {
+		if p.DeletionTimestamp == nil {
+			clientPods = append(clientPods, p)
+		}
+		if len(clientPods) == int(cfg.Concurrency) {
+			break
+		}
+	}
+	if len(clientPods) == 0 {
+		return clientPods, fmt.Errorf("no client pods available")
+	}
+	return clientPods, nil
+}
+
+// recreateClientPods deletes the given client pods and waits for the client deployment to replace them with
+// ready ones, so the sample starts without residual sockets, TLS session caches or tool state
+func recreateClientPods(cfg config.Config, pods []corev1.Pod) ([]corev1.Pod, error) {
+	old := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		old[pod.Name] = true
+		err := clientCluster.CoreV1().Pods(benchmarkNs.Name).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To[int64](0)})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("error deleting client pod %s: %w", pod.Name, err)
+		}
+	}
+	log.Infof("Recreating %d client pods", len(pods))
+	var clientPods []corev1.Pod
+	err := wait.PollUntilContextTimeout(context.TODO(), time.Second, deploymentTimeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		if clientPods, err = benchmarkClientPods(cfg); err != nil || len(clientPods) < len(pods) {
+			return false, nil
+		}
+		for _, pod := range clientPods {
+			if old[pod.Name] || !podReady(pod) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("timeout waiting for the replacement client pods: %w", err)
+	}
+	return clientPods, nil
+}
+
+// Thresholds above which the client pods are considered the bottleneck of the sample
+const (
+	clientCPUThreshold       = 0.9
+	clientThrottledThreshold = 0.1
+)
+
+// clientSaturated returns true when the busiest client node ran out of CPU or the client pods were throttled
+func clientSaturated(metrics map[string]float64) bool {
+	return metrics["max_cpu_utilization_client_nodes"] > clientCPUThreshold || metrics["cpu_throttled_ratio_client_pods"] > clientThrottledThreshold
+}
+
+// queryMetrics runs the infrastructure metric queries concurrently. Queries are evaluated at the end of the benchmark,
+// with a window spanning the sample, so the time spent collecting the results doesn't distort them
+func queryMetrics(p *prometheus.Prometheus, queries map[string]string, start, end time.Time) map[string]float64 {
+	var metricsLock sync.Mutex
+	metrics := make(map[string]float64)
+	elapsed := fmt.Sprintf("%ds", int(end.Sub(start).Seconds()))
+	g := errgroup.Group{}
+	g.SetLimit(metricWorkers)
+	for field, query := range queries {
+		field := field
+		promQuery := strings.ReplaceAll(query, "ELAPSED", elapsed)
+		g.Go(func() error {
 			log.Debugf("Running query: %s", promQuery)
-			value, err := p.Query(promQuery, time.Time{}.UTC())
+			var value model.Value
+			err := withRetries("Prometheus query", func() error {
+				var err error
+				value, err = p.Query(promQuery, end)
+				return err
+			})
 			if err != nil {
 				log.Errorf("Query error: %v", err)
-				continue
+				return nil
 			}
 			data, ok := value.(model.Vector)
 			if !ok {
 				log.Errorf("Unsupported result format: %s", value.Type().String())
-				continue
+				return nil
 			}
+			metricsLock.Lock()
+			defer metricsLock.Unlock()
 			for _, vector := range data {
-				result.InfraMetrics[field] = float64(vector.Value)
+				// NaN and Inf, i.e. divisions by zero, can't be encoded as JSON
+				if v := float64(vector.Value); !math.IsNaN(v) && !math.IsInf(v, 0) {
+					metrics[field] = v
+				}
 			}
+			return nil
+		})
+	}
+	g.Wait()
+	return metrics
+}
+
+// addInstanceTypes adds the instance types of the nodes of the given client pods missing in the map, replacement
+// pods can be scheduled in other nodes
+func addInstanceTypes(instanceTypes map[string]string, pods []corev1.Pod) error {
+	if len(externalClients) > 0 {
+		return nil
+	}
+	for _, pod := range pods {
+		if _, ok := instanceTypes[pod.Spec.NodeName]; !ok {
+			nodeTypes, err := nodeInstanceTypes(map[string]bool{pod.Spec.NodeName: true})
+			if err != nil {
+				return err
+			}
+			instanceTypes[pod.Spec.NodeName] = nodeTypes[pod.Spec.NodeName]
 		}
-		log.Infof("%s: Rps=%.0f avgLatency=%.0fms P95Latency=%.0fms", cfg.Termination, result.TotalAvgRps, result.AvgLatency/1e3, result.P95Latency/1e3)
-		benchmarkResult = append(benchmarkResult, result)
-		if cfg.Delay != 0 {
-			log.Info("Sleeping for ", cfg.Delay)
-			time.Sleep(cfg.Delay)
+	}
+	return nil
+}
+
+// nodeInstanceTypes returns the instance type of the given nodes
+func nodeInstanceTypes(nodes map[string]bool) (map[string]string, error) {
+	instanceTypes := make(map[string]string, len(nodes))
+	for name := range nodes {
+		node, err := clientCluster.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return instanceTypes, fmt.Errorf("couldn't fetch node %s: %w", name, err)
 		}
+		instanceTypes[name] = node.Labels["node.kubernetes.io/instance-type"]
 	}
-	validSamples := float64(len(benchmarkResult))
-	log.Infof("Scenario summary %s: Rps=%.0f avgLatency=%.0fms P95Latency=%.0fms timeouts=%d http_errors=%d",
-		cfg.Termination,
-		aggAvgRps/validSamples,
-		aggAvgLatency/validSamples/1e3,
-		aggP95Latency/validSamples/1e3,
-		timeouts,
-		httpErrors,
-	)
-	return benchmarkResult, nil
+	return instanceTypes, nil
+}
+
+func exec(ctx context.Context, tool tools.Tool, pod corev1.Pod, tenant, target, instanceType string, result *tools.Result) error {
+	podResult, err := runTool(ctx, tool, pod)
+	if err != nil {
+		return err
+	}
+	liveDashboard.addClientResult(result.Sample, 0, pod.Name, podResult)
+	addPodResult(podResult, pod, tenant, target, instanceType, result)
+	return nil
 }
 
-func exec(ctx context.Context, tool tools.Tool, pod corev1.Pod, result *tools.Result) error {
+// execIntervals runs the tool in consecutive executions of resultInterval, collecting the results of each execution
+// as soon as it completes. When the pod fails after completing some intervals, the merged result of those intervals
+// is kept and flagged as partial instead of discarding the whole sample. The start barrier only applies to the first interval.
+// An error is returned as soon as the error ratio of the completed intervals exceeds maxErrorRatio
+func execIntervals(ctx context.Context, cfg config.Config, url string, pod corev1.Pod, tenant, target, instanceType string, startAt time.Time, result *tools.Result) error {
+	var intervals []tools.PodResult
+	var durations []time.Duration
+	var err error
+	for remaining := cfg.Duration; remaining > 0; remaining -= cfg.ResultInterval {
+		c := cfg
+		if remaining < cfg.ResultInterval {
+			c.Duration = remaining
+		} else {
+			c.Duration = cfg.ResultInterval
+		}
+		var tool tools.Tool
+		if tool, err = tools.New(c, url); err != nil {
+			return err
+		}
+		if remaining == cfg.Duration {
+			tool = withBarrier(tool, startAt)
+		}
+		log.Debugf("Running %v in client pods", tool.Cmd())
+		recordCommand(result.Sample, pod.Name, url, tool.Cmd())
+		var podResult tools.PodResult
+		if podResult, err = runTool(ctx, tool, pod); err != nil {
+			break
+		}
+		intervals = append(intervals, podResult)
+		durations = append(durations, c.Duration)
+		liveDashboard.addClientResult(result.Sample, len(intervals), pod.Name, podResult)
+		if ratio := tools.ErrorRatio(intervals...); cfg.MaxErrorRatio != 0 && ratio > cfg.MaxErrorRatio {
+			return fmt.Errorf("pod %s error ratio %.3f exceeded maxErrorRatio %.3f, aborting sample", pod.Name, ratio, cfg.MaxErrorRatio)
+		}
+	}
+	if len(intervals) == 0 {
+		return err
+	}
+	podResult := mergeIntervals(intervals, durations)
+	if err != nil {
+		log.Warnf("Pod %s failed after completing %d intervals, keeping their results", pod.Name, len(intervals))
+		podResult.Partial = true
+	}
+	addPodResult(podResult, pod, tenant, target, instanceType, result)
+	return nil
+}
+
+// mergeIntervals aggregates the results of consecutive executions of a pod, given the duration of each one.
+// Rates are weighted by the duration of each interval, so the shorter last one doesn't count as much as the
+// others, and latencies by its number of requests, so percentiles are an approximation
+func mergeIntervals(intervals []tools.PodResult, durations []time.Duration) tools.PodResult {
+	merged := tools.PodResult{StatusCodes: make(map[int]int64)}
+	var requests float64
+	var total time.Duration
+	for i, r := range intervals {
+		requests += float64(r.Requests)
+		total += durations[i]
+	}
+	var throughput float64
+	for i, r := range intervals {
+		weight := 1 / float64(len(intervals))
+		if requests > 0 {
+			weight = float64(r.Requests) / requests
+		}
+		timeWeight := 1 / float64(len(intervals))
+		if total > 0 {
+			timeWeight = float64(durations[i]) / float64(total)
+		}
+		merged.AvgRps += r.AvgRps * timeWeight
+		merged.StdevRps += r.StdevRps * timeWeight
+		merged.AvgLatency += r.AvgLatency * weight
+		merged.StdevLatency += r.StdevLatency * weight
+		merged.P90Latency += r.P90Latency * weight
+		merged.P95Latency += r.P95Latency * weight
+		merged.P99Latency += r.P99Latency * weight
+		if r.MaxLatency > merged.MaxLatency {
+			merged.MaxLatency = r.MaxLatency
+		}
+		merged.HTTPErrors += r.HTTPErrors
+		merged.ReadErrors += r.ReadErrors
+		merged.WriteErrors += r.WriteErrors
+		merged.Requests += r.Requests
+		merged.Bytes += r.Bytes
+		merged.Timeouts += r.Timeouts
+		throughput += float64(r.AvgThgoughputBps) * timeWeight
+		for code, count := range r.StatusCodes {
+			merged.StatusCodes[code] += count
+		}
+	}
+	merged.AvgThgoughputBps = int64(math.Round(throughput))
+	return merged
+}
+
+// runTool executes the tool in the given pod and parses its output
+func runTool(ctx context.Context, tool tools.Tool, pod corev1.Pod) (tools.PodResult, error) {
+	stdout, stderr, err := podExec(ctx, pod, clientName, tool.Cmd())
+	recordOutput(currentSample, pod.Name, tool.Cmd(), stdout, stderr)
+	toolErrors := tools.ScanErrors(stdout, stderr)
+	if err != nil {
+		log.Errorf("Exec failed in pod %s: %v, stderr: %v", pod.Name, err.Error(), stderr)
+		if len(toolErrors) > 0 {
+			return tools.PodResult{}, fmt.Errorf("%w: %s", err, strings.Join(toolErrors, "; "))
+		}
+		return tools.PodResult{}, err
+	}
+	podResult, err := tool.ParseResult(stdout, stderr)
+	if err != nil {
+		log.Errorf("Result parsing failed: %v", err.Error())
+		log.Errorf("Stdout: %v", stdout)
+		log.Errorf("Stderr: %v", stderr)
+		return podResult, err
+	}
+	podResult.Errors = toolErrors
+	if err := tools.Malfunction(podResult); err != nil {
+		log.Errorf("Pod %s: %v", pod.Name, err)
+		return podResult, err
+	}
+	for _, e := range toolErrors {
+		log.Warnf("Pod %s: %s", pod.Name, e)
+	}
+	return podResult, nil
+}
+
+func addPodResult(podResult tools.PodResult, pod corev1.Pod, tenant, target, instanceType string, result *tools.Result) {
+	podResult.Name = pod.Name
+	podResult.Tenant = tenant
+	podResult.Target = target
+	podResult.Node = pod.Spec.NodeName
+	podResult.InstanceType = instanceType
+	lock.Lock()
+	result.Pods = append(result.Pods, podResult)
+	lock.Unlock()
+	log.Debugf("%s: avgRps: %.0f avgLatency: %.0f ms", podResult.Name, podResult.AvgRps, podResult.AvgLatency/1000)
+}
+
+// podExec runs the given command in a pod container and returns its stdout and stderr
+func podExec(ctx context.Context, pod corev1.Pod, container string, cmd []string) (string, string, error) {
+	if externalClient(pod) {
+		return externalExec(ctx, pod.Name, cmd)
+	}
+	if noExec {
+		return podRun(ctx, pod, container, cmd)
+	}
+	return clusterExec(ctx, clientCluster, clientClusterConfig, pod, container, cmd)
+}
+
+// stopClientProcesses kills the tool processes still running in the given client pods, canceling the exec
+// session of an interrupted sample doesn't stop them and they would load the router during the next one.
+// The processes of the noExec pods are stopped along with their pod and the local external clients along with
+// their command, while the remote ones can't be told apart from other processes of the host
+func stopClientProcesses(pods []corev1.Pod) {
+	if noExec {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), stopTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		if externalClient(pod) {
+			continue
+		}
+		wg.Add(1)
+		go func(pod corev1.Pod) {
+			defer wg.Done()
+			// Signals every process of the container but its main one, sleep, and the shell sending them
+			_, stderr, err := clusterExec(ctx, clientCluster, clientClusterConfig, pod, clientName, []string{"bash", "-c", "kill -KILL -1 2>/dev/null; true"})
+			if err != nil {
+				log.Warnf("Error stopping the client processes of pod %s: %v %s", pod.Name, err, stderr)
+			}
+		}(pod)
+	}
+	wg.Wait()
+	log.Infof("Client processes of the interrupted sample stopped")
+}
+
+// clusterExec runs the given command in a pod container of the given cluster and returns its stdout and stderr
+func clusterExec(ctx context.Context, c kubernetes.Interface, restCfg *rest.Config, pod corev1.Pod, container string, cmd []string) (string, string, error) {
 	var stdout, stderr bytes.Buffer
-	req := clientSet.CoreV1().RESTClient().Post().
+	req := c.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(pod.Name).
-		Namespace(benchmarkNs.Name).
+		Namespace(pod.Namespace).
 		SubResource("exec")
 	req.VersionedParams(&corev1.PodExecOptions{
-		Container: clientName,
+		Container: container,
 		Stdin:     false,
 		Stdout:    true,
 		Stderr:    true,
-		Command:   tool.Cmd(),
+		Command:   cmd,
 		TTY:       false,
 	}, scheme.ParameterCodec)
-	exec, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	exec, err := remotecommand.NewSPDYExecutor(restCfg, "POST", req.URL())
 	if err != nil {
 		log.Error(err.Error())
-		return err
+		return "", "", err
 	}
 	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdout: &stdout,
 		Stderr: &stderr,
 	})
-	if err != nil {
-		log.Errorf("Exec failed in pod %s: %v, stderr: %v", pod.Name, err.Error(), stderr.String())
-		return err
-	}
-	podResult, err := tool.ParseResult(stdout.String(), stderr.String())
-	if err != nil {
-		log.Errorf("Result parsing failed: %v", err.Error())
-		log.Errorf("Stdout: %v", stdout.String())
-		log.Errorf("Stderr: %v", stderr.String())
-		return err
-	}
-	podResult.Name = pod.Name
-	podResult.Node = pod.Spec.NodeName
-	node, err := clientSet.CoreV1().Nodes().Get(context.TODO(), podResult.Node, metav1.GetOptions{})
-	if err != nil {
-		log.Errorf("Couldn't fetch node: %v", err.Error())
-		return err
-	}
-	if d, ok := node.Labels["node.kubernetes.io/instance-type"]; ok {
-		podResult.InstanceType = d
-	}
-	lock.Lock()
-	result.Pods = append(result.Pods, podResult)
-	lock.Unlock()
-	log.Debugf("%s: avgRps: %.0f avgLatency: %.0f ms", podResult.Name, podResult.AvgRps, podResult.AvgLatency/1000)
-	return nil
+	return stdout.String(), stderr.String(), err
 }
 
 func normalizeResults(result *tools.Result) {
 	result.StatusCodes = make(map[int]int64)
+	toolErrors := make(map[string]bool)
 	for _, pod := range result.Pods {
 		result.TotalAvgRps += pod.AvgRps
 		result.StdevRps += pod.StdevRps
@@ -209,7 +706,23 @@ func normalizeResults(result *tools.Result) {
 		result.ReadErrors += pod.ReadErrors
 		result.WriteErrors += pod.WriteErrors
 		result.Requests += pod.Requests
+		result.Bytes += pod.Bytes
 		result.Timeouts += pod.Timeouts
+		result.TotalThroughputBps += float64(pod.AvgThgoughputBps)
+		result.OpenConnections += pod.OpenConnections
+		result.DroppedConnections += pod.DroppedConnections
+		if pod.Partial {
+			result.PartialPods++
+		}
+		for _, e := range pod.Errors {
+			if len(result.ToolErrors) < maxToolErrors && !toolErrors[e] {
+				toolErrors[e] = true
+				result.ToolErrors = append(result.ToolErrors, e)
+			}
+		}
+		for code, count := range pod.StatusCodes {
+			result.StatusCodes[code] += count
+		}
 		if pod.MaxLatency > result.MaxLatency {
 			result.MaxLatency = pod.MaxLatency
 		}
@@ -224,5 +737,10 @@ func normalizeResults(result *tools.Result) {
 	result.P90Latency = result.P90Latency / pods
 	result.P95Latency = result.P95Latency / pods
 	result.P99Latency = result.P99Latency / pods
+	if result.Requests > 0 {
+		result.AvgBytesPerRequest = float64(result.Bytes) / float64(result.Requests)
+	}
 	result.Version = fmt.Sprintf("%v@%v", version.Version, version.GitCommit)
+	rollupTenants(result)
+	rollupTargets(result)
 }
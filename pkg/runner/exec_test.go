@@ -0,0 +1,61 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+func TestMergeIntervals(t *testing.T) {
+	intervals := []tools.PodResult{
+		{AvgRps: 100, AvgLatency: 10, P99Latency: 20, MaxLatency: 50, Requests: 1000, HTTPErrors: 1, AvgThgoughputBps: 1000, StatusCodes: map[int]int64{200: 999, 503: 1}},
+		{AvgRps: 40, AvgLatency: 40, P99Latency: 80, MaxLatency: 30, Requests: 200, HTTPErrors: 2, AvgThgoughputBps: 400, StatusCodes: map[int]int64{200: 198, 503: 2}},
+	}
+	// The second interval is the cold last one, shorter than the first
+	merged := mergeIntervals(intervals, []time.Duration{10 * time.Second, 5 * time.Second})
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	// Rates are weighted by the interval duration
+	approx("AvgRps", merged.AvgRps, 100*2.0/3+40*1.0/3)
+	if merged.AvgThgoughputBps != 800 {
+		t.Errorf("AvgThgoughputBps = %d, want 800", merged.AvgThgoughputBps)
+	}
+	// Latencies are weighted by the requests
+	approx("AvgLatency", merged.AvgLatency, 10*1000.0/1200+40*200.0/1200)
+	approx("P99Latency", merged.P99Latency, 20*1000.0/1200+80*200.0/1200)
+	approx("MaxLatency", merged.MaxLatency, 50)
+	if merged.Requests != 1200 || merged.HTTPErrors != 3 {
+		t.Errorf("Requests = %d and HTTPErrors = %d, want 1200 and 3", merged.Requests, merged.HTTPErrors)
+	}
+	if merged.StatusCodes[200] != 1197 || merged.StatusCodes[503] != 3 {
+		t.Errorf("StatusCodes = %v, want 200: 1197 and 503: 3", merged.StatusCodes)
+	}
+}
+
+func TestMergeIntervalsWithoutRequests(t *testing.T) {
+	intervals := []tools.PodResult{{AvgRps: 10, AvgLatency: 10}, {AvgRps: 30, AvgLatency: 30}}
+	merged := mergeIntervals(intervals, []time.Duration{0, 0})
+	if merged.AvgRps != 20 || merged.AvgLatency != 20 {
+		t.Errorf("AvgRps = %v and AvgLatency = %v, want the plain mean 20", merged.AvgRps, merged.AvgLatency)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// routeRefs <namespace>/<name> of the existing routes targeted by the benchmark, empty when ingress-perf creates its own routes
+	routeRefs []string
+	// existingRoutes existing routes indexed by termination
+	existingRoutes map[string]*routev1.Route
+)
+
+// WithRoutes targets the given existing routes, in <namespace>/<name> format, instead of deploying the server and
+// creating the benchmark routes. Each scenario uses the route matching its termination
+func WithRoutes(refs []string) OptsFunctions {
+	return func(r *Runner) {
+		for _, ref := range refs {
+			if ns, name, ok := strings.Cut(ref, "/"); !ok || ns == "" || name == "" {
+				log.Fatalf("Invalid route %q, expected format is <namespace>/<name>", ref)
+			}
+		}
+		routeRefs = refs
+	}
+}
+
+// bundledServer returns whether the benchmark routes are backed by the server deployed by ingress-perf
+func bundledServer() bool {
+	return backendRef == "" && len(routeRefs) == 0
+}
+
+// routeTermination returns the termination of a route, using the same values as the scenario termination
+func routeTermination(route *routev1.Route) string {
+	if route.Spec.TLS == nil {
+		return "http"
+	}
+	return string(route.Spec.TLS.Termination)
+}
+
+// setupRoutes fetches the existing routes and verifies that the scenarios can run against them
+func (r *Runner) setupRoutes() error {
+	if r.serviceMesh || backendRef != "" {
+		return fmt.Errorf("existing routes can't be combined with service mesh mode or an existing backend")
+	}
+	existingRoutes = make(map[string]*routev1.Route)
+	for _, ref := range routeRefs {
+		ns, name, _ := strings.Cut(ref, "/")
+		route, err := orClientSet.RouteV1().Routes(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error fetching route %s: %w", ref, err)
+		}
+		termination := routeTermination(route)
+		if prev, ok := existingRoutes[termination]; ok {
+			return fmt.Errorf("routes %s/%s and %s have the same termination %s", prev.Namespace, prev.Name, ref, termination)
+		}
+		log.Infof("Using %s route %s with host %s", termination, ref, route.Spec.Host)
+		existingRoutes[termination] = route
+	}
+	for i, cfg := range config.Cfg {
+		if _, ok := existingRoutes[cfg.Termination]; !ok {
+			return fmt.Errorf("scenario %d: no %s route among the given routes", i+1, cfg.Termination)
+		}
+		// These settings create or modify routes
+		if cfg.Tenants > 0 || cfg.SNIHosts > 0 || cfg.BackgroundRoutes > 0 || cfg.RoutePropagation > 0 ||
+			cfg.StickySessions || len(cfg.RouteAnnotations) > 0 || cfg.IngressController != "" || cfg.RouteTimeout != 0 || cfg.Balance != "" || cfg.RateLimit != nil || len(cfg.BackendWeights) > 0 ||
+			cfg.Certificate != nil || cfg.IPWhitelist > 0 || envoyIngress(cfg) {
+			return fmt.Errorf("scenario %d: tenants, sniHosts, backgroundRoutes, routePropagation, stickySessions, routeAnnotations, balance, rateLimit, backendWeights, certificate, ipWhitelist, ingress, ingressControllers and timeoutSweep aren't supported with existing routes", i+1)
+		}
+	}
+	return nil
+}
+
+// benchmarkRoute returns the route targeted by the scenario
+func benchmarkRoute(cfg config.Config) (*routev1.Route, error) {
+	if route, ok := existingRoutes[cfg.Termination]; ok {
+		return route, nil
+	}
+	if shardedController(cfg) {
+		return orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), controllerRouteName(cfg), metav1.GetOptions{})
+	}
+	return orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), fmt.Sprintf("%s-%s", serverName, cfg.Termination), metav1.GetOptions{})
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	osexec "os/exec"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// localClient runs the client commands in the machine running ingress-perf
+	localClient = "local"
+	// externalClientLabel marks the pseudo pods standing for the external clients
+	externalClientLabel = "ingress-perf/external-client"
+)
+
+// externalClients hosts generating the load from outside the cluster, empty when the load is generated by the client pods
+var externalClients []string
+
+// WithExternalClients generates the load from outside the cluster, either locally, with the "local" host, or in
+// remote hosts over SSH, in [user@]host[:port] format. Each host takes the role of a client pod
+func WithExternalClients(hosts []string) OptsFunctions {
+	return func(r *Runner) {
+		for _, host := range hosts {
+			// Hosts starting with - would be parsed as ssh options
+			if host == "" || strings.ContainsAny(host, " /") || strings.HasPrefix(host, "-") {
+				log.Fatalf("Invalid external client %q, expected local or [user@]host[:port]", host)
+			}
+		}
+		externalClients = hosts
+	}
+}
+
+// remoteClients returns whether the load is generated out of the target cluster, by the client pods of another
+// cluster or by external hosts
+func remoteClients() bool {
+	return multiCluster() || len(externalClients) > 0
+}
+
+// externalClientPods returns one pseudo pod per external client, up to the scenario concurrency
+func externalClientPods(cfg config.Config) []corev1.Pod {
+	var pods []corev1.Pod
+	for _, host := range externalClients {
+		if len(pods) == int(cfg.Concurrency) {
+			break
+		}
+		pods = append(pods, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: host, Labels: map[string]string{externalClientLabel: "true"}},
+			Spec:       corev1.PodSpec{NodeName: host},
+		})
+	}
+	return pods
+}
+
+func externalClient(pod corev1.Pod) bool {
+	return pod.Labels[externalClientLabel] == "true"
+}
+
+// externalExec runs the given command in the external client, locally or over SSH, and returns its stdout and stderr
+func externalExec(ctx context.Context, host string, cmd []string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	var c *osexec.Cmd
+	if host == localClient {
+		c = osexec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	} else {
+		args := []string{"-o", "BatchMode=yes"}
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			host = h
+			args = append(args, "-p", port)
+		}
+		// The remote shell joins the arguments, so each one is quoted
+		quoted := make([]string, len(cmd))
+		for i, arg := range cmd {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		args = append(args, "--", host, strings.Join(quoted, " "))
+		c = osexec.CommandContext(ctx, "ssh", args...)
+	}
+	c.Stdout, c.Stderr = &stdout, &stderr
+	err := c.Run()
+	if err != nil {
+		err = fmt.Errorf("command failed in external client %s: %w", host, err)
+	}
+	return stdout.String(), stderr.String(), err
+}
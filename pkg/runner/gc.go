@@ -0,0 +1,130 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GC removes the resources left behind by any previous run, found through the managed-by label and grouped by
+// the uuid of their run. Only runs without activity, the creation of any of its resources or the heartbeat of its
+// namespaces, for olderThan are removed, so in-progress runs can be preserved. With dryRun, the runs are only listed
+func GC(olderThan time.Duration, dryRun bool, timeout time.Duration) error {
+	if err := initClients(); err != nil {
+		return err
+	}
+	runs, err := leftoverRuns()
+	if err != nil {
+		return err
+	}
+	uuids := make([]string, 0, len(runs))
+	for uuid := range runs {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	var removed int
+	for _, uuid := range uuids {
+		age := time.Since(runs[uuid]).Round(time.Second)
+		name := uuid
+		if name == "" {
+			name = "<no uuid>"
+		}
+		if age < olderThan {
+			log.Infof("Keeping run %s, active %v ago", name, age)
+			continue
+		}
+		removed++
+		if dryRun {
+			log.Infof("Run %s, active %v ago, would be removed", name, age)
+			continue
+		}
+		log.Infof("Removing run %s, active %v ago", name, age)
+		// Resources created by old versions may lack the uuid label
+		selector := fmt.Sprintf("%s=%s,!%s", managedByLabel, resourceLabels[managedByLabel], uuidLabel)
+		if uuid != "" {
+			selector = fmt.Sprintf("%s=%s", uuidLabel, uuid)
+		}
+		if err := cleanupResources(selector, timeout); err != nil {
+			return err
+		}
+	}
+	log.Infof("%d of %d runs found were garbage collected", removed, len(runs))
+	return nil
+}
+
+// leftoverRuns returns the time of the last activity of each run with resources in the clusters, indexed by uuid:
+// the creation of its newest resource or the latest heartbeat of its namespaces, whichever is later
+func leftoverRuns() (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+	add := func(meta metav1.ObjectMeta) {
+		uuid := meta.Labels[uuidLabel]
+		active := meta.CreationTimestamp.Time
+		if hb, err := time.Parse(time.RFC3339, meta.Annotations[heartbeatAnnotation]); err == nil && hb.After(active) {
+			active = hb
+		}
+		if last, ok := runs[uuid]; !ok || active.After(last) {
+			runs[uuid] = active
+		}
+	}
+	listOpts := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", managedByLabel, resourceLabels[managedByLabel])}
+	routeList, err := orClientSet.RouteV1().Routes(metav1.NamespaceAll).List(context.TODO(), listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routeList.Items {
+		add(r.ObjectMeta)
+	}
+	for _, c := range clusters() {
+		nsList, err := c.CoreV1().Namespaces().List(context.TODO(), listOpts)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nsList.Items {
+			add(ns.ObjectMeta)
+		}
+		crbList, err := c.RbacV1().ClusterRoleBindings().List(context.TODO(), listOpts)
+		// Cluster scoped RBAC may be prohibited, in which case ingress-perf didn't create any ClusterRoleBinding
+		if errors.IsForbidden(err) {
+			log.Debugf("Not allowed to list ClusterRoleBindings: %v", err)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, crb := range crbList.Items {
+			add(crb.ObjectMeta)
+		}
+	}
+	return runs, nil
+}
+
+// heartbeat records the current time in the benchmark namespaces, so gc doesn't take long runs, whose resources
+// were created long ago, for leftovers
+func heartbeat() {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, heartbeatAnnotation, time.Now().UTC().Format(time.RFC3339))
+	for _, c := range clusters() {
+		_, err := c.CoreV1().Namespaces().Patch(context.TODO(), benchmarkNs.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			log.Debugf("Couldn't update the heartbeat of namespace %s: %v", benchmarkNs.Name, err)
+		}
+	}
+}
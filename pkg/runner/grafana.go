@@ -0,0 +1,113 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// grafanaAnnotator posts region annotations to Grafana marking the start and end of each test and sample
+type grafanaAnnotator struct {
+	url    string
+	token  string
+	uuid   string
+	client *http.Client
+}
+
+// annotator is nil when Grafana annotations are disabled
+var annotator *grafanaAnnotator
+
+// grafanaAnnotation region annotation, as accepted by the Grafana annotations API
+type grafanaAnnotation struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd"`
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+}
+
+// newGrafanaAnnotator returns an annotator posting to the given Grafana instance, tagging annotations with the run uuid
+func newGrafanaAnnotator(url, token, uuid string) *grafanaAnnotator {
+	return &grafanaAnnotator{
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		uuid:   uuid,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// annotateTest marks a test, annotation errors are logged but they don't fail the run
+func (g *grafanaAnnotator) annotateTest(test int, cfg config.Config, start, end time.Time) {
+	if g == nil {
+		return
+	}
+	text := fmt.Sprintf("ingress-perf %s test %d: %s %s", g.uuid, test, cfg.Tool, cfg.Termination)
+	g.post(start, end, text, fmt.Sprintf("test-%d", test), tools.TestID(g.uuid, test), cfg.Termination)
+}
+
+// annotateSample marks a sample of a test
+func (g *grafanaAnnotator) annotateSample(test, sample int, cfg config.Config, start, end time.Time) {
+	if g == nil {
+		return
+	}
+	text := fmt.Sprintf("ingress-perf %s test %d sample %d: %s %s", g.uuid, test, sample, cfg.Tool, cfg.Termination)
+	g.post(start, end, text, fmt.Sprintf("test-%d", test), fmt.Sprintf("sample-%d", sample), tools.TestID(g.uuid, test),
+		tools.SampleID(g.uuid, test, sample), cfg.Termination)
+}
+
+// post creates a region annotation, errors are logged since annotations must not fail the run
+func (g *grafanaAnnotator) post(start, end time.Time, text string, tags ...string) {
+	annotation := grafanaAnnotation{
+		Time:    start.UnixMilli(),
+		TimeEnd: end.UnixMilli(),
+		Tags:    append([]string{"ingress-perf", g.uuid}, tags...),
+		Text:    text,
+	}
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		log.Errorf("Grafana annotation error: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, g.url+"/api/annotations", bytes.NewReader(body))
+	if err != nil {
+		log.Errorf("Grafana annotation error: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		log.Errorf("Grafana annotation error: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Errorf("Grafana annotation failed with status %d: %s", resp.StatusCode, msg)
+		return
+	}
+	log.Debugf("Grafana annotation posted: %s", text)
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// Router memory at the start of the sample and its peak while the idle connections are held
+const (
+	routerMemoryBaseline = "router_memory_baseline_bytes"
+	routerMemoryPeak     = "max_memory_router_bytes"
+	routerMemoryQuery    = "sum(container_memory_working_set_bytes{name!='', namespace='openshift-ingress', pod=~'router-default.+'})"
+)
+
+// sampleQueries returns the Prometheus queries of a sample, the idle tool also measures the router memory growth
+// and weighted routes the traffic served by each backend
+func sampleQueries(cfg config.Config) map[string]string {
+	queries := routerQueries(cfg)
+	if cfg.Tool != "idle" && len(cfg.BackendWeights) == 0 && !envoyIngress(cfg) {
+		return queries
+	}
+	extended := make(map[string]string, len(queries)+len(cfg.BackendWeights)+2)
+	for name, query := range queries {
+		extended[name] = query
+	}
+	if envoyIngress(cfg) {
+		for name, query := range ingressQueries(cfg) {
+			extended[name] = query
+		}
+	}
+	for name, query := range backendQueries(cfg) {
+		extended[name] = query
+	}
+	if cfg.Tool != "idle" {
+		return extended
+	}
+	memory := routerMemoryQuery
+	if shardedController(cfg) {
+		memory = strings.ReplaceAll(memory, "router-default", "router-"+cfg.IngressController)
+	}
+	extended[routerMemoryBaseline] = memory + " offset ELAPSED"
+	extended[routerMemoryPeak] = "max_over_time(" + memory + "[ELAPSED:])"
+	return extended
+}
+
+// idleConnections computes the router memory per idle connection and warns about the connections closed by the
+// router while they were idle, i.e. when the duration exceeds the IngressController client timeout
+func idleConnections(result *tools.Result) {
+	if result.OpenConnections > 0 {
+		if growth := result.InfraMetrics[routerMemoryPeak] - result.InfraMetrics[routerMemoryBaseline]; growth > 0 {
+			result.RouterMemoryPerConn = growth / float64(result.OpenConnections)
+		}
+	}
+	log.Infof("Idle connections: open=%d dropped=%d router memory per connection=%.0f bytes",
+		result.OpenConnections, result.DroppedConnections, result.RouterMemoryPerConn)
+	if result.DroppedConnections > 0 {
+		log.Warnf("%d idle connections were closed by the router, verify the IngressController clientTimeout against the duration", result.DroppedConnections)
+	}
+}
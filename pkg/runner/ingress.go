@@ -0,0 +1,278 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"istio.io/api/networking/v1beta1"
+	v1networking "istio.io/client-go/pkg/apis/networking/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var httpProxyGVR = schema.GroupVersionResource{
+	Group:    "projectcontour.io",
+	Version:  "v1",
+	Resource: "httpproxies",
+}
+
+const (
+	// ingressResourceName of the HTTPProxy, Gateway and VirtualService serving the benchmark traffic
+	ingressResourceName = "ingress-perf"
+	contourEnvoy        = "envoy"
+	istioIngressGateway = "istio-ingressgateway"
+)
+
+// contourNamespace and istioNamespace are the namespaces of the Contour Envoy and of the Istio ingress gateway
+var contourNamespace, istioNamespace = "projectcontour", "istio-system"
+
+// WithContour sets the namespace of the Contour Envoy service targeted by the contour ingress
+func WithContour(namespace string) OptsFunctions {
+	return func(r *Runner) {
+		contourNamespace = namespace
+	}
+}
+
+// envoyIngress returns whether the scenario traffic is served by an Envoy based ingress instead of the router
+func envoyIngress(cfg config.Config) bool {
+	return cfg.Ingress == config.IngressContour || cfg.Ingress == config.IngressIstio
+}
+
+// ingressHost is the virtual host of the benchmark traffic in the Envoy based ingresses, it isn't resolvable,
+// clients connect to the ingress service and send it in the Host header
+func ingressHost(cfg config.Config) string {
+	return fmt.Sprintf("%s.%s.%s", serverName, benchmarkNs.Name, cfg.Ingress)
+}
+
+// reconcileIngress makes sure the resources of the Envoy based ingress of the scenario serve the bundled server
+func reconcileIngress(cfg config.Config) error {
+	if !envoyIngress(cfg) {
+		return nil
+	}
+	port, err := ingressBackendPort(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Ingress == config.IngressContour {
+		return reconcileHTTPProxy(ingressHost(cfg), port)
+	}
+	return reconcileIstioGateway(ingressHost(cfg), port)
+}
+
+// ingressBackendPort returns the number of the service port served through the ingress, the scenario targetPort
+// or the port targeted by the http routes
+func ingressBackendPort(cfg config.Config) (int32, error) {
+	port := httpTargetPort
+	if cfg.TargetPort != "" {
+		port = intstr.Parse(cfg.TargetPort)
+	}
+	for _, p := range service.Spec.Ports {
+		if (port.Type == intstr.String && p.Name == port.StrVal) || (port.Type == intstr.Int && p.Port == port.IntVal) {
+			return p.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("service %s has no port %s", service.Name, port.String())
+}
+
+func reconcileHTTPProxy(host string, port int32) error {
+	desired := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "projectcontour.io/v1",
+		"kind":       "HTTPProxy",
+		"metadata": map[string]interface{}{
+			"name":      ingressResourceName,
+			"namespace": benchmarkNs.Name,
+		},
+		"spec": map[string]interface{}{
+			"virtualhost": map[string]interface{}{"fqdn": host},
+			"routes": []interface{}{
+				map[string]interface{}{
+					"services": []interface{}{
+						map[string]interface{}{"name": service.Name, "port": int64(port)},
+					},
+				},
+			},
+		},
+	}}
+	desired.SetLabels(resourceLabels)
+	proxies := dynamicClient.Resource(httpProxyGVR).Namespace(benchmarkNs.Name)
+	existing, err := proxies.Get(context.TODO(), ingressResourceName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Infof("Creating HTTPProxy %s/%s with host %s", benchmarkNs.Name, ingressResourceName, host)
+		_, err = proxies.Create(context.TODO(), desired, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return fmt.Errorf("error fetching HTTPProxy, is Contour installed? %w", err)
+	}
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	_, err = proxies.Update(context.TODO(), desired, metav1.UpdateOptions{})
+	return err
+}
+
+func reconcileIstioGateway(host string, port int32) error {
+	gateway := &v1networking.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: ingressResourceName, Labels: resourceLabels},
+		Spec: v1beta1.Gateway{
+			Selector: map[string]string{"istio": "ingressgateway"},
+			Servers: []*v1beta1.Server{
+				{
+					Port:  &v1beta1.Port{Number: 80, Protocol: "HTTP", Name: "http"},
+					Hosts: []string{host},
+				},
+			},
+		},
+	}
+	vs := &v1networking.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: ingressResourceName, Labels: resourceLabels},
+		Spec: v1beta1.VirtualService{
+			Hosts:    []string{host},
+			Gateways: []string{ingressResourceName},
+			Http: []*v1beta1.HTTPRoute{
+				{
+					Route: []*v1beta1.HTTPRouteDestination{
+						{Destination: &v1beta1.Destination{Host: service.Name, Port: &v1beta1.PortSelector{Number: uint32(port)}}},
+					},
+				},
+			},
+		},
+	}
+	gateways := istioClient.NetworkingV1beta1().Gateways(benchmarkNs.Name)
+	existingGateway, err := gateways.Get(context.TODO(), ingressResourceName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = gateways.Create(context.TODO(), gateway, metav1.CreateOptions{})
+	} else if err == nil {
+		gateway.ResourceVersion = existingGateway.ResourceVersion
+		_, err = gateways.Update(context.TODO(), gateway, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error reconciling Gateway, is Istio installed? %w", err)
+	}
+	virtualServices := istioClient.NetworkingV1beta1().VirtualServices(benchmarkNs.Name)
+	existingVS, err := virtualServices.Get(context.TODO(), ingressResourceName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = virtualServices.Create(context.TODO(), vs, metav1.CreateOptions{})
+	} else if err == nil {
+		vs.ResourceVersion = existingVS.ResourceVersion
+		_, err = virtualServices.Update(context.TODO(), vs, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Istio Gateway and VirtualService %s/%s serving host %s", benchmarkNs.Name, ingressResourceName, host)
+	return nil
+}
+
+// ingressService returns the namespace and name of the service of the Envoy based ingress of the scenario
+func ingressService(cfg config.Config) (string, string) {
+	if cfg.Ingress == config.IngressIstio {
+		return istioNamespace, istioIngressGateway
+	}
+	return contourNamespace, contourEnvoy
+}
+
+// ingressAddress returns the address and port the clients connect to in order to reach the Envoy based ingress:
+// the in-cluster service address, or the load balancer one when the clients run outside the target cluster
+func ingressAddress(cfg config.Config) (string, string, error) {
+	ns, name := ingressService(cfg)
+	svc, err := clientSet.CoreV1().Services(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching ingress service %s/%s: %w", ns, name, err)
+	}
+	var port *corev1.ServicePort
+	for i, p := range svc.Spec.Ports {
+		if p.Name == "http" || p.Name == "http2" || p.Port == 80 {
+			port = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if port == nil {
+		return "", "", fmt.Errorf("ingress service %s/%s has no http port", ns, name)
+	}
+	if !multiCluster() && len(externalClients) == 0 {
+		return fmt.Sprintf("%s.%s.svc", name, ns), strconv.Itoa(int(port.Port)), nil
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP, strconv.Itoa(int(port.Port)), nil
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname, strconv.Itoa(int(port.Port)), nil
+		}
+	}
+	return "", "", fmt.Errorf("ingress service %s/%s has no load balancer address, required by clients outside the cluster", ns, name)
+}
+
+// ingressTargets points the targets to the Envoy based ingress service, with the ingress host in the Host header
+func ingressTargets(cfg config.Config, targets []target) error {
+	address, p, err := ingressAddress(cfg)
+	if err != nil {
+		return err
+	}
+	log.Infof("Targeting the %s ingress at %s:%s", cfg.Ingress, address, p)
+	for i := range targets {
+		targets[i].host = ingressHost(cfg)
+		targets[i].address = address
+		targets[i].url = endpoint(cfg, net.JoinHostPort(address, p))
+		targets[i].headers = append(targets[i].headers, fmt.Sprintf("Host: %s", targets[i].host))
+	}
+	return nil
+}
+
+// ingressQueries returns the CPU and memory queries of the Envoy pods of the scenario ingress
+func ingressQueries(cfg config.Config) map[string]string {
+	ns, name := ingressService(cfg)
+	return map[string]string{
+		"avg_cpu_usage_ingress_pods":          fmt.Sprintf("avg(avg_over_time(sum(irate(container_cpu_usage_seconds_total{name!='', namespace='%s', pod=~'%s.+'}[2m])) by (pod)[ELAPSED:]))", ns, name),
+		"avg_memory_usage_ingress_pods_bytes": fmt.Sprintf("avg(avg_over_time(sum(container_memory_working_set_bytes{name!='', namespace='%s', pod=~'%s.+'}) by (pod)[ELAPSED:]))", ns, name),
+	}
+}
+
+// ingressVersion returns the version of the Envoy based ingress of the scenario, the tag of its proxy image
+func ingressVersion(cfg config.Config) (string, error) {
+	ns, name := ingressService(cfg)
+	var podSpec corev1.PodSpec
+	if deployment, err := clientSet.AppsV1().Deployments(ns).Get(context.TODO(), name, metav1.GetOptions{}); err == nil {
+		podSpec = deployment.Spec.Template.Spec
+	} else if errors.IsNotFound(err) {
+		// Contour deploys Envoy as a daemonset by default
+		var ds *appsv1.DaemonSet
+		if ds, err = clientSet.AppsV1().DaemonSets(ns).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			return "", err
+		}
+		podSpec = ds.Spec.Template.Spec
+	} else {
+		return "", err
+	}
+	for _, c := range podSpec.Containers {
+		if c.Name == contourEnvoy || c.Name == "istio-proxy" {
+			if i := strings.LastIndex(c.Image, ":"); i >= 0 && !strings.Contains(c.Image[i:], "/") {
+				return c.Image[i+1:], nil
+			}
+			return c.Image, nil
+		}
+	}
+	return "", fmt.Errorf("no proxy container found in %s/%s", ns, name)
+}
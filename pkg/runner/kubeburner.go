@@ -0,0 +1,123 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/version"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+)
+
+// Query of the kube-burner metric documents holding client side measurements, which don't come from Prometheus
+const clientQuery = "ingress-perf"
+
+// WithKubeBurnerDocs also indexes the results as kube-burner jobSummary and metric documents, so the
+// kube-burner dashboards and comparison tooling can consume them
+func WithKubeBurnerDocs(enable bool) OptsFunctions {
+	return func(r *Runner) {
+		r.kubeBurnerDocs = enable
+	}
+}
+
+// kubeBurnerJobName each test is mapped to a kube-burner job
+func kubeBurnerJobName(test int, cfg config.Config) string {
+	return fmt.Sprintf("test-%d-%s", test, cfg.Termination)
+}
+
+// kubeBurnerJobSummary returns the job summary document of a test
+func kubeBurnerJobSummary(test int, cfg config.Config, md tools.ClusterMetadata, start, end time.Time, passed bool) tools.KubeBurnerJobSummary {
+	return tools.KubeBurnerJobSummary{
+		Timestamp:    start.UTC(),
+		EndTimestamp: end.UTC(),
+		UUID:         cfg.UUID,
+		MetricName:   tools.JobSummaryMetric,
+		ElapsedTime:  end.Sub(start).Round(time.Second).Seconds(),
+		JobConfig: tools.KubeBurnerJobConfig{
+			Name:    kubeBurnerJobName(test, cfg),
+			JobType: "ingress-perf",
+			Config:  cfg,
+		},
+		Version:         fmt.Sprintf("%v@%v", version.Version, version.GitCommit),
+		Passed:          passed,
+		ClusterMetadata: md,
+	}
+}
+
+// kubeBurnerResults returns one metric document per sample and measurement: the client measurements
+// plus the infrastructure metrics, whose query is the Prometheus one
+func kubeBurnerResults(test int, cfg config.Config, results []tools.Result) []interface{} {
+	var docs []interface{}
+	queries := sampleQueries(cfg)
+	for _, r := range results {
+		labels := map[string]string{
+			"termination": cfg.Termination,
+			"tool":        cfg.Tool,
+			"testId":      r.TestID,
+			"sampleId":    r.SampleID,
+		}
+		add := func(name, query string, value float64) {
+			docs = append(docs, tools.KubeBurnerMetric{
+				Timestamp:  r.Timestamp,
+				Labels:     labels,
+				Value:      value,
+				UUID:       r.UUID,
+				Query:      query,
+				MetricName: name,
+				JobName:    kubeBurnerJobName(test, cfg),
+			})
+		}
+		add("total_avg_rps", clientQuery, r.TotalAvgRps)
+		add("avg_lat_us", clientQuery, r.AvgLatency)
+		add("p90_lat_us", clientQuery, r.P90Latency)
+		add("p95_lat_us", clientQuery, r.P95Latency)
+		add("p99_lat_us", clientQuery, r.P99Latency)
+		add("max_lat_us", clientQuery, r.MaxLatency)
+		add("requests", clientQuery, float64(r.Requests))
+		add("http_errors", clientQuery, float64(r.HTTPErrors))
+		add("timeouts", clientQuery, float64(r.Timeouts))
+		for name, value := range r.InfraMetrics {
+			add(name, queries[name], value)
+		}
+	}
+	return docs
+}
+
+// kubeBurnerPropagation returns the metric documents of a route propagation test
+func kubeBurnerPropagation(test int, cfg config.Config, result tools.PropagationResult) []interface{} {
+	var docs []interface{}
+	labels := map[string]string{"termination": cfg.Termination, "testId": result.TestID}
+	for name, value := range map[string]float64{
+		"avg_propagation_ms": result.AvgLatency,
+		"p50_propagation_ms": result.P50Latency,
+		"p95_propagation_ms": result.P95Latency,
+		"p99_propagation_ms": result.P99Latency,
+		"max_propagation_ms": result.MaxLatency,
+		"failures":           float64(result.Failures),
+	} {
+		docs = append(docs, tools.KubeBurnerMetric{
+			Timestamp:  result.Timestamp,
+			Labels:     labels,
+			Value:      value,
+			UUID:       result.UUID,
+			Query:      clientQuery,
+			MetricName: name,
+			JobName:    kubeBurnerJobName(test, cfg),
+		})
+	}
+	return docs
+}
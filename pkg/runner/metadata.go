@@ -15,19 +15,14 @@
 package runner
 
 import (
-	"bytes"
 	"context"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func getHAProxyVersion() (string, error) {
-	var stdout, stderr bytes.Buffer
 	podList, err := clientSet.CoreV1().Pods("openshift-ingress").List(context.TODO(),
 		metav1.ListOptions{
 			LabelSelector: "ingresscontroller.operator.openshift.io/deployment-ingresscontroller=default",
@@ -36,31 +31,45 @@ func getHAProxyVersion() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	routerPod := podList.Items[0]
-	req := clientSet.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(routerPod.Name).
-		Namespace(routerPod.Namespace).
-		SubResource("exec")
-	req.VersionedParams(&corev1.PodExecOptions{
-		Container: "router",
-		Stdin:     false,
-		Stdout:    true,
-		Stderr:    true,
-		Command:   []string{"bash", "-c", "rpm -qa | grep haproxy"},
-		TTY:       false,
-	}, scheme.ParameterCodec)
-	exec, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	stdout, _, err := podExec(context.TODO(), podList.Items[0], "router", []string{"bash", "-c", "rpm -qa | grep haproxy"})
 	if err != nil {
-		log.Error(err.Error())
 		return "", err
 	}
-	err = exec.StreamWithContext(context.TODO(), remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
+	return strings.TrimRight(stdout, "\n"), err
+}
+
+// getEndpointPublishingStrategy returns the endpoint publishing strategy type of the default ingress controller
+// and whether it's configured to use the PROXY protocol
+func getEndpointPublishingStrategy() (string, bool, error) {
+	ic, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Get(context.TODO(), "default", metav1.GetOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	strategy, _, err := unstructured.NestedMap(ic.Object, "status", "endpointPublishingStrategy")
+	if err != nil {
+		return "", false, err
+	}
+	strategyType, _, _ := unstructured.NestedString(strategy, "type")
+	for _, params := range []string{"hostNetwork", "nodePort", "private"} {
+		if protocol, found, _ := unstructured.NestedString(strategy, params, "protocol"); found {
+			return strategyType, protocol == "PROXY", nil
+		}
+	}
+	// AWS classic load balancers always use the PROXY protocol
+	lbType, _, _ := unstructured.NestedString(strategy, "loadBalancer", "providerParameters", "aws", "type")
+	return strategyType, lbType == "Classic", nil
+}
+
+// WorkerNodes returns the number of worker nodes that can host benchmark pods
+func WorkerNodes() (int, error) {
+	if err := initClients(); err != nil {
+		return 0, err
+	}
+	nodes, err := clientSet.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "node-role.kubernetes.io/worker,!node-role.kubernetes.io/infra",
 	})
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	return strings.TrimRight(stdout.String(), "\n"), err
+	return len(nodes.Items), nil
 }
@@ -0,0 +1,161 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	ilog "github.com/cloud-bulldozer/ingress-perf/pkg/log"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// runMetrics holds the live metrics of the run, exposed in the Prometheus text format
+type runMetrics struct {
+	lock           sync.Mutex
+	uuid           string
+	tests          int
+	test           int
+	sample         int
+	termination    string
+	phase          string
+	phaseStart     time.Time
+	phaseDurations map[string]float64
+	last           tools.Result
+	requests       int64
+	httpErrors     int64
+	timeouts       int64
+	failedSamples  int64
+}
+
+var (
+	liveMetrics = &runMetrics{phaseDurations: make(map[string]float64)}
+	metricsOnce sync.Once
+)
+
+// reset clears the metrics of a previous run
+func (m *runMetrics) reset(uuid string, tests int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.uuid, m.tests = uuid, tests
+	m.test, m.sample, m.termination, m.phase = 0, 0, "", ""
+	m.phaseDurations = make(map[string]float64)
+	m.last = tools.Result{}
+	m.requests, m.httpErrors, m.timeouts, m.failedSamples = 0, 0, 0, 0
+}
+
+// setPhase sets the phase log field, starts the phase span and accounts the time spent in the previous phase
+func setPhase(phase string) {
+	ilog.SetField("phase", phase)
+	runTracer.startPhase(phase)
+	emit(EventPhase, 0, phase)
+	liveMetrics.lock.Lock()
+	defer liveMetrics.lock.Unlock()
+	if liveMetrics.phase != "" {
+		liveMetrics.phaseDurations[liveMetrics.phase] += time.Since(liveMetrics.phaseStart).Seconds()
+	}
+	liveMetrics.phase = phase
+	liveMetrics.phaseStart = time.Now()
+}
+
+func (m *runMetrics) startTest(test int, cfg config.Config) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.test = test
+	m.sample = 0
+	m.termination = cfg.Termination
+}
+
+func (m *runMetrics) startSample(sample int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sample = sample
+}
+
+func (m *runMetrics) addSample(result tools.Result) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.last = result
+	m.requests += result.Requests
+	m.httpErrors += result.HTTPErrors
+	m.timeouts += result.Timeouts
+}
+
+func (m *runMetrics) failSample() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.failedSamples++
+}
+
+// serveMetrics exposes the live metrics at /metrics in the given address, the endpoint
+// is started only once and outlives the run, so it can be shared by consecutive runs
+func serveMetrics(addr string) {
+	metricsOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", liveMetrics)
+		log.Infof("Serving metrics at %s/metrics", addr)
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.Errorf("Metrics endpoint error: %v", err)
+			}
+		}()
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (m *runMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	labels := fmt.Sprintf(`uuid=%q`, m.uuid)
+	testLabels := fmt.Sprintf(`%s,test="%d",termination=%q`, labels, m.test, m.termination)
+	writeMetric(w, "ingress_perf_tests", "gauge", "Number of tests of the run", labels, float64(m.tests))
+	writeMetric(w, "ingress_perf_current_test", "gauge", "Test being executed, starting from 1", labels, float64(m.test))
+	writeMetric(w, "ingress_perf_current_sample", "gauge", "Sample being executed, starting from 1", labels, float64(m.sample))
+	writeMetric(w, "ingress_perf_sample_rps", "gauge", "Requests per second of the last completed sample", testLabels, m.last.TotalAvgRps)
+	writeMetric(w, "ingress_perf_sample_avg_latency_seconds", "gauge", "Average latency of the last completed sample", testLabels, m.last.AvgLatency/1e6)
+	writeMetric(w, "ingress_perf_sample_p99_latency_seconds", "gauge", "P99 latency of the last completed sample", testLabels, m.last.P99Latency/1e6)
+	writeMetric(w, "ingress_perf_requests_total", "counter", "Requests of the completed samples", labels, float64(m.requests))
+	writeMetric(w, "ingress_perf_http_errors_total", "counter", "HTTP errors of the completed samples", labels, float64(m.httpErrors))
+	writeMetric(w, "ingress_perf_timeouts_total", "counter", "Timeouts of the completed samples", labels, float64(m.timeouts))
+	writeMetric(w, "ingress_perf_failed_samples_total", "counter", "Samples skipped due to execution errors", labels, float64(m.failedSamples))
+	durations := make(map[string]float64, len(m.phaseDurations)+1)
+	for phase, d := range m.phaseDurations {
+		durations[phase] = d
+	}
+	if m.phase != "" {
+		durations[m.phase] += time.Since(m.phaseStart).Seconds()
+	}
+	phases := make([]string, 0, len(durations))
+	for phase := range durations {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	fmt.Fprintln(w, "# HELP ingress_perf_phase_duration_seconds_total Time spent in each phase of the run")
+	fmt.Fprintln(w, "# TYPE ingress_perf_phase_duration_seconds_total counter")
+	for _, phase := range phases {
+		fmt.Fprintf(w, "ingress_perf_phase_duration_seconds_total{%s,phase=%q} %g\n", labels, phase, durations[phase])
+	}
+}
+
+func writeMetric(w io.Writer, name, metricType, help, labels string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{%s} %g\n", name, help, name, metricType, name, labels, value)
+}
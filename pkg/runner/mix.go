@@ -0,0 +1,104 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// mixAssignments returns the request target of each client process. Processes are allocated to the targets
+// proportionally to their weights with the largest remainder method, and shuffled with the run seed, so the
+// allocation is reproducible
+func mixAssignments(mix []config.RequestTarget, procs int) []config.RequestTarget {
+	var totalWeight int
+	for _, t := range mix {
+		totalWeight += t.Weight
+	}
+	counts := make([]int, len(mix))
+	remainders := make([]int, len(mix))
+	allocated := 0
+	for i, t := range mix {
+		counts[i] = procs * t.Weight / totalWeight
+		remainders[i] = procs * t.Weight % totalWeight
+		allocated += counts[i]
+	}
+	order := make([]int, len(mix))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+	for i := 0; allocated < procs; i++ {
+		counts[order[i]]++
+		allocated++
+	}
+	var assignments []config.RequestTarget
+	for i, t := range mix {
+		if counts[i] == 0 {
+			log.Warnf("Request target %s gets no client processes, increase the number of processes or its weight", t.Label())
+		}
+		for j := 0; j < counts[i]; j++ {
+			assignments = append(assignments, t)
+		}
+	}
+	rand.New(rand.NewSource(runSeed)).Shuffle(len(assignments), func(i, j int) {
+		assignments[i], assignments[j] = assignments[j], assignments[i]
+	})
+	return assignments
+}
+
+// mixURL replaces the scenario path of the given URL by the path of the request target
+func mixURL(url, path string, target config.RequestTarget) string {
+	return strings.TrimSuffix(url, path) + target.Path
+}
+
+// rollupTargets aggregates the pod results of each request target
+func rollupTargets(result *tools.Result) {
+	targets := make(map[string]*tools.TargetResult)
+	for _, pod := range result.Pods {
+		if pod.Target == "" {
+			continue
+		}
+		t, ok := targets[pod.Target]
+		if !ok {
+			t = &tools.TargetResult{Target: pod.Target}
+			targets[pod.Target] = t
+		}
+		t.Clients++
+		t.TotalAvgRps += pod.AvgRps
+		t.AvgLatency += pod.AvgLatency
+		t.P95Latency += pod.P95Latency
+		t.P99Latency += pod.P99Latency
+		t.Requests += pod.Requests
+		t.Bytes += pod.Bytes
+		t.HTTPErrors += pod.HTTPErrors
+		t.Timeouts += pod.Timeouts
+	}
+	result.Targets = nil
+	for _, t := range targets {
+		t.AvgLatency /= float64(t.Clients)
+		t.P95Latency /= float64(t.Clients)
+		t.P99Latency /= float64(t.Clients)
+		result.Targets = append(result.Targets, *t)
+	}
+	sort.Slice(result.Targets, func(i, j int) bool {
+		return result.Targets[i].Target < result.Targets[j].Target
+	})
+}
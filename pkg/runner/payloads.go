@@ -0,0 +1,61 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	payloadsName = "ingress-perf-payloads"
+	// payloadsDir the text payloads are mounted in, under the document root of the bundled server
+	payloadsDir = "/usr/share/nginx/html/text"
+)
+
+// payloadSizes sizes in bytes of the compressible payloads served by the bundled server, i.e: /text/1024.html
+var payloadSizes = []int{1024, 16384, 131072}
+
+// payloads holds compressible text payloads, the default ones of the bundled server are random data
+var payloads = corev1.ConfigMap{
+	ObjectMeta: metav1.ObjectMeta{Name: payloadsName},
+	Data:       textPayloads(),
+}
+
+func textPayloads() map[string]string {
+	data := make(map[string]string)
+	for _, size := range payloadSizes {
+		var b strings.Builder
+		for i := 0; b.Len() < size; i++ {
+			fmt.Fprintf(&b, "<p>ingress-perf compressible payload, line %d</p>\n", i)
+		}
+		data[fmt.Sprintf("%d.html", size)] = b.String()[:size]
+	}
+	return data
+}
+
+// createPayloads creates the payloads ConfigMap mounted by the server pods in the given namespace
+func createPayloads(ns string) error {
+	_, err := clientSet.CoreV1().ConfigMaps(ns).Create(context.TODO(), payloads.DeepCopy(), metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
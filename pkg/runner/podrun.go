@@ -0,0 +1,130 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+)
+
+// noExec runs the client commands in short-lived pods instead of executing them in the client pods
+var noExec bool
+
+// Markers delimiting the stdout, stderr and exit code of the command in the pod logs
+const (
+	stdoutMarker = "--- ingress-perf stdout ---"
+	stderrMarker = "--- ingress-perf stderr ---"
+	exitMarker   = "--- ingress-perf exit ---"
+)
+
+// WithoutExec runs the client commands in short-lived pods, scheduled in the node of the client pod, and
+// retrieves their output through the logs API. Useful in clusters where pods/exec is blocked by admission policies
+func WithoutExec(enable bool) OptsFunctions {
+	return func(r *Runner) {
+		noExec = enable
+	}
+}
+
+// podRun runs the given command in a new pod with the spec of the given client pod and returns its stdout and stderr
+func podRun(ctx context.Context, pod corev1.Pod, container string, cmd []string) (string, string, error) {
+	if container != clientName {
+		return "", "", fmt.Errorf("running commands in %s containers requires pods/exec", container)
+	}
+	// Output is buffered in files so stdout and stderr can be told apart in the logs
+	script := fmt.Sprintf(`"$@" >/tmp/stdout 2>/tmp/stderr; rc=$?; echo '%s'; cat /tmp/stdout; echo '%s'; cat /tmp/stderr; echo '%s'; echo $rc`,
+		stdoutMarker, stderrMarker, exitMarker)
+	spec := client.Spec.Template.Spec.DeepCopy()
+	spec.NodeName = pod.Spec.NodeName
+	spec.Affinity = nil
+	spec.TopologySpreadConstraints = nil
+	spec.RestartPolicy = corev1.RestartPolicyNever
+	spec.Containers[0].Command = append([]string{"bash", "-c", script, "--"}, cmd...)
+	spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	runPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + "-",
+			Namespace:    pod.Namespace,
+			Labels:       map[string]string{"app": clientName + "-cmd"},
+			Annotations:  client.Spec.Template.Annotations,
+		},
+		Spec: *spec,
+	}
+	for k, v := range idLabels() {
+		runPod.Labels[k] = v
+	}
+	runPod, err := clientCluster.CoreV1().Pods(pod.Namespace).Create(ctx, runPod, metav1.CreateOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		err := clientCluster.CoreV1().Pods(runPod.Namespace).Delete(context.TODO(), runPod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To[int64](0)})
+		if err != nil {
+			log.Errorf("Error deleting pod %s: %v", runPod.Name, err)
+		}
+	}()
+	// The container status is checked instead of the pod phase, as sidecars may keep the pod running
+	err = wait.PollUntilContextCancel(ctx, time.Second, false, func(ctx context.Context) (bool, error) {
+		p, err := clientCluster.CoreV1().Pods(runPod.Namespace).Get(ctx, runPod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if p.Status.Phase == corev1.PodFailed {
+			return false, fmt.Errorf("pod %s failed: %s", p.Name, p.Status.Message)
+		}
+		for _, cs := range p.Status.ContainerStatuses {
+			if cs.Name == clientName && cs.State.Terminated != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	logs, err := clientCluster.CoreV1().Pods(runPod.Namespace).GetLogs(runPod.Name, &corev1.PodLogOptions{Container: clientName}).DoRaw(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching logs of pod %s: %w", runPod.Name, err)
+	}
+	return parseRunLogs(string(logs))
+}
+
+// parseRunLogs splits the logs of a command pod into stdout and stderr
+func parseRunLogs(logs string) (string, string, error) {
+	stdoutStart := strings.Index(logs, stdoutMarker+"\n")
+	stderrStart := strings.Index(logs, stderrMarker+"\n")
+	exitStart := strings.Index(logs, exitMarker+"\n")
+	if stdoutStart < 0 || stderrStart < stdoutStart || exitStart < stderrStart {
+		return "", logs, fmt.Errorf("unexpected command pod output")
+	}
+	stdout := logs[stdoutStart+len(stdoutMarker)+1 : stderrStart]
+	stderr := logs[stderrStart+len(stderrMarker)+1 : exitStart]
+	rc, err := strconv.Atoi(strings.TrimSpace(logs[exitStart+len(exitMarker)+1:]))
+	if err != nil {
+		return stdout, stderr, fmt.Errorf("unexpected command pod exit code: %w", err)
+	}
+	if rc != 0 {
+		return stdout, stderr, fmt.Errorf("command terminated with exit code %d", rc)
+	}
+	return stdout, stderr, nil
+}
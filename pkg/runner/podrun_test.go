@@ -0,0 +1,54 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRunLogs(t *testing.T) {
+	logs := func(stdout, stderr, rc string) string {
+		return stdoutMarker + "\n" + stdout + stderrMarker + "\n" + stderr + exitMarker + "\n" + rc + "\n"
+	}
+	tests := []struct {
+		name       string
+		logs       string
+		wantStdout string
+		wantStderr string
+		wantErr    string
+	}{
+		{"success", logs("{\"rps\": 1}\n", "", "0"), "{\"rps\": 1}\n", "", ""},
+		{"stderr", logs("out\n", "warning\n", "0"), "out\n", "warning\n", ""},
+		{"non-zero exit code", logs("", "failed\n", "2"), "", "failed\n", "exit code 2"},
+		{"invalid exit code", logs("out\n", "", "killed"), "out\n", "", "unexpected command pod exit code"},
+		{"missing exit marker", stdoutMarker + "\nout\n" + stderrMarker + "\n", "", stdoutMarker + "\nout\n" + stderrMarker + "\n", "unexpected command pod output"},
+		{"no markers", "exec format error\n", "", "exec format error\n", "unexpected command pod output"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, err := parseRunLogs(tc.logs)
+			if stdout != tc.wantStdout || stderr != tc.wantStderr {
+				t.Errorf("got stdout %q and stderr %q, want %q and %q", stdout, stderr, tc.wantStdout, tc.wantStderr)
+			}
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("got error %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var profilingOnce sync.Once
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+	expvar.Publish("run", expvar.Func(func() interface{} {
+		liveMetrics.lock.Lock()
+		defer liveMetrics.lock.Unlock()
+		return map[string]interface{}{
+			"uuid":   liveMetrics.uuid,
+			"test":   liveMetrics.test,
+			"sample": liveMetrics.sample,
+			"phase":  liveMetrics.phase,
+		}
+	}))
+}
+
+// WithProfiling exposes the pprof endpoints of ingress-perf itself at /debug/pprof and its expvar
+// variables, i.e. memory stats and goroutines, at /debug/vars in the given address
+func WithProfiling(addr string) OptsFunctions {
+	return func(r *Runner) {
+		r.pprofAddr = addr
+	}
+}
+
+// WithProfileDumps stores heap and goroutine profiles of ingress-perf itself in the given directory
+// with the given interval, and at the end of the run
+func WithProfileDumps(dir string, interval time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		r.profileDir = dir
+		r.profileInterval = interval
+	}
+}
+
+// serveProfiling exposes the pprof and expvar endpoints in the given address, the endpoint
+// is started only once and outlives the run, like the metrics one
+func serveProfiling(addr string) {
+	profilingOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle("/debug/vars", expvar.Handler())
+		log.Infof("Serving pprof at %s/debug/pprof and expvar at %s/debug/vars", addr, addr)
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.Errorf("Profiling endpoint error: %v", err)
+			}
+		}()
+	})
+}
+
+// dumpProfiles stores the heap and goroutine profiles every interval until the context is cancelled,
+// and a last one then, so the growth across the run can be compared with go tool pprof -base
+func dumpProfiles(ctx context.Context, dir string, interval time.Duration, done chan<- struct{}) {
+	defer close(done)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Errorf("Error creating profiles directory: %v", err)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 1; ; i++ {
+		select {
+		case <-ctx.Done():
+			writeProfiles(dir, "final")
+			return
+		case <-ticker.C:
+			writeProfiles(dir, fmt.Sprintf("%03d", i))
+		}
+	}
+}
+
+// writeProfiles writes heap-<suffix>.pb.gz and goroutine-<suffix>.txt in the given directory
+func writeProfiles(dir, suffix string) {
+	for _, profile := range []struct {
+		name, file string
+		debug      int
+	}{
+		{"heap", "heap-" + suffix + ".pb.gz", 0},
+		{"goroutine", "goroutine-" + suffix + ".txt", 1},
+	} {
+		f, err := os.Create(path.Join(dir, profile.file))
+		if err != nil {
+			log.Errorf("Error writing %s profile: %v", profile.name, err)
+			continue
+		}
+		if err := rpprof.Lookup(profile.name).WriteTo(f, profile.debug); err != nil {
+			log.Errorf("Error writing %s profile: %v", profile.name, err)
+		}
+		f.Close()
+	}
+	log.Debugf("Profiles %s stored in %s", suffix, dir)
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// progress tracks the run progress, the ETA is estimated from the configured sample durations and delays
+type progress struct {
+	lock          sync.Mutex
+	start         time.Time
+	planned       time.Duration
+	completed     time.Duration
+	sampleStart   time.Time
+	samplePlanned time.Duration
+	test          int
+	tests         int
+	sample        int
+	samples       int
+}
+
+var runProgress = &progress{}
+
+// plannedDuration returns the expected duration of a sample of the given scenario
+func plannedDuration(cfg config.Config) time.Duration {
+	return cfg.Duration + cfg.Delay
+}
+
+func newProgress(cfgs []config.Config) *progress {
+	p := &progress{start: time.Now(), tests: len(cfgs)}
+	for _, cfg := range cfgs {
+		p.planned += time.Duration(cfg.Samples) * plannedDuration(cfg)
+	}
+	return p
+}
+
+func (p *progress) startTest(test int, cfg config.Config) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.test = test
+	p.samples = cfg.Samples
+	p.sample = 0
+}
+
+func (p *progress) startSample(sample int, cfg config.Config) {
+	p.lock.Lock()
+	p.sample = sample
+	p.sampleStart = time.Now()
+	p.samplePlanned = plannedDuration(cfg)
+	p.lock.Unlock()
+	log.Info(p.String())
+}
+
+func (p *progress) endSample() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.completed += p.samplePlanned
+	p.samplePlanned = 0
+}
+
+// String returns the progress summary: current test and sample, elapsed time and ETA
+func (p *progress) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	remaining := p.planned - p.completed
+	if p.samplePlanned > 0 {
+		inSample := time.Since(p.sampleStart)
+		if inSample > p.samplePlanned {
+			inSample = p.samplePlanned
+		}
+		remaining -= inSample
+	}
+	var pct float64
+	if p.planned > 0 {
+		pct = float64(p.planned-remaining) / float64(p.planned) * 100
+	}
+	return fmt.Sprintf("Progress: test %d/%d sample %d/%d (%.0f%%), elapsed %v, ETA %v",
+		p.test, p.tests, p.sample, p.samples, pct, time.Since(p.start).Round(time.Second), remaining.Round(time.Second))
+}
+
+// report logs the progress periodically until the context is cancelled
+func (p *progress) report(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			log.Info(p.String())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Progress returns the progress summary of the current run
+func Progress() string {
+	return runProgress.String()
+}
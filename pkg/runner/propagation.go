@@ -0,0 +1,159 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/version"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Max time to wait for a route to serve requests
+const propagationTimeout = 2 * time.Minute
+
+// runRoutePropagation creates cfg.RoutePropagation routes sequentially and measures the elapsed time
+// since each route is created until it serves a 200 through the router
+func runRoutePropagation(cfg config.Config, clusterMetadata tools.ClusterMetadata) (tools.PropagationResult, error) {
+	result := tools.PropagationResult{
+		SchemaVersion:   tools.SchemaVersion,
+		UUID:            cfg.UUID,
+		Test:            currentTest,
+		TestID:          tools.TestID(cfg.UUID, currentTest),
+		Config:          cfg,
+		Timestamp:       time.Now().UTC(),
+		ClusterMetadata: clusterMetadata,
+		Version:         fmt.Sprintf("%v@%v", version.Version, version.GitCommit),
+	}
+	// The startup time of the command pods would be accounted as propagation time
+	if noExec {
+		return result, fmt.Errorf("route propagation measurements require pods/exec")
+	}
+	ref, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), fmt.Sprintf("%s-%s", serverName, cfg.Termination), metav1.GetOptions{})
+	if err != nil {
+		return result, err
+	}
+	_, domain, found := strings.Cut(ref.Spec.Host, ".")
+	if !found {
+		return result, fmt.Errorf("unable to get the ingress domain from host %s", ref.Spec.Host)
+	}
+	clientPods, err := clientCluster.CoreV1().Pods(benchmarkNs.Name).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", clientName),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(clientPods.Items) == 0 {
+		return result, fmt.Errorf("no running client pods found")
+	}
+	pod := clientPods.Items[0]
+	for i := 1; i <= cfg.RoutePropagation; i++ {
+		route := ref.DeepCopy()
+		route.ObjectMeta = metav1.ObjectMeta{
+			Name:   fmt.Sprintf("%s-propagation-%d", serverName, i),
+			Labels: map[string]string{"app": "ingress-perf-propagation"},
+		}
+		for k, v := range idLabels() {
+			route.Labels[k] = v
+		}
+		route.Spec.Host = fmt.Sprintf("%s-%s.%s", route.Name, routesNamespace, domain)
+		route.Status = routev1.RouteStatus{}
+		latency, err := measurePropagation(cfg, route, pod)
+		if err != nil {
+			log.Errorf("Route propagation %d/%d failed: %v", i, cfg.RoutePropagation, err)
+			result.Failures++
+			continue
+		}
+		log.Infof("Route propagation %d/%d: %v", i, cfg.RoutePropagation, latency)
+		result.Latencies = append(result.Latencies, float64(latency.Milliseconds()))
+		if cfg.Delay != 0 {
+			time.Sleep(cfg.Delay)
+		}
+	}
+	if len(result.Latencies) == 0 {
+		return result, fmt.Errorf("all route propagation measurements failed")
+	}
+	summarizePropagation(&result)
+	log.Infof("Route propagation summary %s: avg=%.0fms P50=%.0fms P95=%.0fms P99=%.0fms max=%.0fms failures=%d",
+		cfg.Termination, result.AvgLatency, result.P50Latency, result.P95Latency, result.P99Latency, result.MaxLatency, result.Failures)
+	return result, nil
+}
+
+func measurePropagation(cfg config.Config, route *routev1.Route, pod corev1.Pod) (time.Duration, error) {
+	defer func() {
+		err := orClientSet.RouteV1().Routes(routesNamespace).Delete(context.TODO(), route.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Errorf("Error deleting route %s: %v", route.Name, err)
+		}
+	}()
+	script := fmt.Sprintf(`until [ "$(curl %s -o /dev/null -w '%%{http_code}' %s)" = "200" ]; do sleep 0.05; done`, curlFlags(cfg), endpoint(cfg, route.Spec.Host))
+	cmd := []string{"timeout", fmt.Sprint(propagationTimeout.Seconds()), "bash", "-c", script}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	execErr := make(chan error, 1)
+	// Start polling before the route exists, so the router returns 503 until the route is propagated
+	go func() {
+		_, stderr, err := podExec(ctx, pod, clientName, cmd)
+		if err != nil {
+			err = fmt.Errorf("%v: %s", err, stderr)
+		}
+		execErr <- err
+	}()
+	start := time.Now()
+	if _, err := orClientSet.RouteV1().Routes(routesNamespace).Create(context.TODO(), route, metav1.CreateOptions{}); err != nil {
+		cancel()
+		<-execErr
+		return 0, err
+	}
+	if err := <-execErr; err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func summarizePropagation(result *tools.PropagationResult) {
+	latencies := make([]float64, len(result.Latencies))
+	copy(latencies, result.Latencies)
+	sort.Float64s(latencies)
+	for _, l := range latencies {
+		result.AvgLatency += l
+	}
+	result.AvgLatency /= float64(len(latencies))
+	result.P50Latency = percentile(latencies, 50)
+	result.P95Latency = percentile(latencies, 95)
+	result.P99Latency = percentile(latencies, 99)
+	result.MaxLatency = latencies[len(latencies)-1]
+}
+
+// percentile returns the nearest-rank percentile from a sorted slice
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
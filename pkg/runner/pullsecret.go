@@ -0,0 +1,90 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// pullSecretNs namespace of the pull secret copied into the benchmark namespaces, empty when it's only referenced
+	pullSecretNs string
+	// pullSecret is the source secret, fetched when the assets are deployed
+	pullSecret *corev1.Secret
+)
+
+// WithPullSecret sets the image pull secret of the client and server pods. With the <namespace>/<name> format
+// the secret is copied from the given namespace into the benchmark namespaces, otherwise it must exist in them
+func WithPullSecret(ref string) OptsFunctions {
+	return func(r *Runner) {
+		if ref == "" {
+			return
+		}
+		name := ref
+		if ns, n, ok := strings.Cut(ref, "/"); ok {
+			pullSecretNs, name = ns, n
+		}
+		secrets := []corev1.LocalObjectReference{{Name: name}}
+		client.Spec.Template.Spec.ImagePullSecrets = secrets
+		server.Spec.Template.Spec.ImagePullSecrets = secrets
+	}
+}
+
+// fetchPullSecret gets the source pull secret to copy, if any
+func fetchPullSecret() error {
+	if pullSecretNs == "" {
+		return nil
+	}
+	name := client.Spec.Template.Spec.ImagePullSecrets[0].Name
+	secret, err := clientSet.CoreV1().Secrets(pullSecretNs).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error fetching pull secret %s/%s: %w", pullSecretNs, name, err)
+	}
+	pullSecret = secret
+	return nil
+}
+
+// copyPullSecret copies the source pull secret into the given namespace of the given cluster
+func copyPullSecret(c kubernetes.Interface, ns string) error {
+	if pullSecret == nil {
+		return nil
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pullSecret.Name,
+			Namespace: ns,
+			Labels:    map[string]string{},
+		},
+		Type: pullSecret.Type,
+		Data: pullSecret.Data,
+	}
+	for k, v := range resourceLabels {
+		secret.Labels[k] = v
+	}
+	log.Debugf("Copying pull secret %s/%s to namespace %s", pullSecret.Namespace, pullSecret.Name, ns)
+	_, err := c.CoreV1().Secrets(ns).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// rateLimitWindow is the period, in seconds, of the router rate limits
+const rateLimitWindow = 3
+
+// rateLimitResult measures the rejected requests and, with an HTTP rate limit, how accurately it was enforced per
+// client pod, since the router tracks the requests per source IP and each client pod has its own IP
+func rateLimitResult(cfg config.Config, result *tools.Result) {
+	if cfg.RateLimit == nil {
+		return
+	}
+	rl := &tools.RateLimitResult{
+		Rejected: result.HTTPErrors + result.ReadErrors + result.WriteErrors + result.Timeouts,
+	}
+	if cfg.RateLimit.RateHTTP > 0 {
+		accepted := make(map[string]int64)
+		for _, pod := range result.Pods {
+			accepted[pod.Name] += pod.Requests - pod.HTTPErrors
+		}
+		if len(accepted) > 0 {
+			var total int64
+			for _, requests := range accepted {
+				total += requests
+			}
+			rl.LimitRps = float64(cfg.RateLimit.RateHTTP) / rateLimitWindow
+			rl.AcceptedRps = float64(total) / float64(len(accepted)) / cfg.Duration.Seconds()
+			rl.Accuracy = rl.AcceptedRps / rl.LimitRps
+		}
+	}
+	log.Infof("Rate limit: limit=%.1f rps accepted=%.1f rps per client pod, accuracy=%.2f rejected=%d",
+		rl.LimitRps, rl.AcceptedRps, rl.Accuracy, rl.Rejected)
+	result.RateLimit = rl
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WithRBAC configures the RBAC of the client pods. With a service account, the client pods use it and no RBAC is
+// created, it must already exist in the benchmark namespace and hold the required permissions. Otherwise, when
+// namespaced is set, permissions are granted through a RoleBinding instead of a ClusterRoleBinding
+func WithRBAC(serviceAccount string, namespaced bool) OptsFunctions {
+	return func(r *Runner) {
+		r.serviceAccount = serviceAccount
+		r.namespacedRBAC = namespaced
+		if serviceAccount != "" {
+			client.Spec.Template.Spec.ServiceAccountName = serviceAccount
+		}
+	}
+}
+
+// WithAssets applies the pod overrides of the assets configuration, i.e: service accounts, security contexts or
+// host aliases, to the client and server pods, and the service ports. A client service account is handled like the one given to WithRBAC, so no RBAC is created
+func WithAssets(assets config.Assets) OptsFunctions {
+	return func(r *Runner) {
+		for _, a := range []struct {
+			spec      *corev1.PodSpec
+			overrides config.PodOverrides
+		}{
+			{&client.Spec.Template.Spec, assets.Client},
+			{&server.Spec.Template.Spec, assets.Server},
+		} {
+			if a.overrides.ServiceAccount != "" {
+				a.spec.ServiceAccountName = a.overrides.ServiceAccount
+			}
+			if a.overrides.SecurityContext != nil {
+				a.spec.Containers[0].SecurityContext = a.overrides.SecurityContext
+			}
+			if a.overrides.PodSecurityContext != nil {
+				a.spec.SecurityContext = a.overrides.PodSecurityContext
+			}
+			a.spec.HostAliases = a.overrides.HostAliases
+			if a.overrides.DNSPolicy != "" {
+				a.spec.DNSPolicy = a.overrides.DNSPolicy
+			}
+			a.spec.DNSConfig = a.overrides.DNSConfig
+		}
+		if len(assets.Ports) > 0 {
+			service.Spec.Ports = assets.Ports
+		}
+		if assets.Client.ServiceAccount != "" {
+			r.serviceAccount = assets.Client.ServiceAccount
+		}
+	}
+}
+
+// deployRBAC grants the client service account the permissions required by the client pods
+func (r *Runner) deployRBAC() error {
+	// Client pods only require extra permissions in the host network
+	if !r.hostNetwork {
+		return nil
+	}
+	if r.serviceAccount != "" {
+		log.Infof("Using service account %s, make sure it's allowed to use the hostnetwork-v2 SCC", r.serviceAccount)
+		return nil
+	}
+	if r.namespacedRBAC {
+		rb := rbac.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   clientCRB.Name,
+				Labels: clientCRB.Labels,
+			},
+			Subjects: clientCRB.Subjects,
+			RoleRef:  clientCRB.RoleRef,
+		}
+		_, err := clientCluster.RbacV1().RoleBindings(benchmarkNs.Name).Create(context.TODO(), &rb, metav1.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	_, err := clientCluster.RbacV1().ClusterRoleBindings().Create(context.TODO(), &clientCRB, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	readinessTimeout  = 2 * time.Minute
+	readinessInterval = 2 * time.Second
+)
+
+// curl exit codes with a clearer explanation
+var curlErrors = map[string]string{
+	"exit code 6":  "the route host doesn't resolve, DNS records may not have propagated yet",
+	"exit code 7":  "couldn't connect to the router",
+	"exit code 28": "the request timed out",
+	"exit code 35": "TLS handshake failed",
+}
+
+// verifyTargets checks from the client pod that every target resolves and returns a 200 response with a non-empty
+// body over the configured termination, retrying until readinessTimeout, so samples don't start against routes
+// that aren't reachable yet
+func verifyTargets(cfg config.Config, targets []target, pod corev1.Pod) error {
+	verified := make(map[string]bool)
+	for _, t := range targets {
+		if verified[t.url] {
+			continue
+		}
+		if err := verifyTarget(cfg, t, pod); err != nil {
+			return err
+		}
+		verified[t.url] = true
+	}
+	log.Infof("%d targets ready", len(verified))
+	return nil
+}
+
+func verifyTarget(cfg config.Config, t target, pod corev1.Pod) error {
+	var lastErr error
+	flags := curlFlags(cfg)
+	for _, h := range t.headers {
+		flags += fmt.Sprintf(" -H '%s'", h)
+	}
+	script := fmt.Sprintf(`curl %s --max-time 5 -o /dev/null -w '%%{http_code} %%{size_download}' %s`, flags, t.url)
+	err := wait.PollUntilContextTimeout(context.TODO(), readinessInterval, readinessTimeout, true, func(ctx context.Context) (bool, error) {
+		stdout, _, err := podExec(ctx, pod, clientName, []string{"bash", "-c", script})
+		if err != nil {
+			lastErr = err
+			for code, explanation := range curlErrors {
+				if strings.Contains(err.Error(), code) {
+					lastErr = fmt.Errorf("%s: %w", explanation, err)
+				}
+			}
+			log.Debugf("Target %s not ready: %v", t.url, lastErr)
+			return false, nil
+		}
+		var status, size int
+		if _, err := fmt.Sscanf(stdout, "%d %d", &status, &size); err != nil {
+			lastErr = fmt.Errorf("unexpected curl output %q", stdout)
+			return false, nil
+		}
+		if status != 200 || size == 0 {
+			lastErr = fmt.Errorf("got status %d with a %d bytes body, expected 200 with a non-empty body", status, size)
+			log.Debugf("Target %s not ready: %v", t.url, lastErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("target %s not ready after %v: %v", t.url, readinessTimeout, lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runSeed seeds the randomized decisions of the run, so they can be replayed
+var runSeed int64
+
+// WithSeed sets the seed of the randomized decisions of the run, 0 picks a random one
+func WithSeed(seed int64) OptsFunctions {
+	return func(r *Runner) {
+		r.seed = seed
+	}
+}
+
+// initSeed sets the run seed, it's recorded in the results to replay the run
+func (r *Runner) initSeed() {
+	runSeed = r.seed
+	if runSeed == 0 {
+		runSeed = time.Now().UnixNano()
+	}
+}
+
+// configHash returns the SHA-256 of the expanded configuration of the run
+func configHash(cfgs []config.Config) (string, error) {
+	data, err := json.Marshal(cfgs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// imageDigests returns the distinct image digests the given pods are running, as reported by the kubelet
+func imageDigests(pods []corev1.Pod) []string {
+	unique := make(map[string]bool)
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.ImageID != "" {
+				unique[cs.ImageID] = true
+			}
+		}
+	}
+	digests := make([]string, 0, len(unique))
+	for digest := range unique {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	return digests
+}
+
+// serverImageDigests returns the image digests of the server pods, nil when an existing backend is used
+func serverImageDigests() ([]string, error) {
+	if !bundledServer() {
+		return nil, nil
+	}
+	pods, err := clientSet.CoreV1().Pods(benchmarkNs.Name).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", serverName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return imageDigests(pods.Items), nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// retryPolicy applies to the calls to the indexer and Prometheus
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+	timeout  time.Duration
+}
+
+var retries = retryPolicy{
+	attempts: 3,
+	backoff:  time.Second,
+	timeout:  2 * time.Minute,
+}
+
+// WithRetries configures the number of attempts of the indexer and Prometheus calls, the initial backoff between
+// attempts, doubled after each failed attempt, and the timeout of each attempt
+func WithRetries(attempts int, backoff, timeout time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		if attempts < 1 {
+			attempts = 1
+		}
+		retries = retryPolicy{attempts: attempts, backoff: backoff, timeout: timeout}
+	}
+}
+
+// withRetries calls fn until it succeeds or the attempts are exhausted. The client libraries don't accept a context,
+// so an attempt exceeding the timeout is abandoned rather than cancelled, callers must tolerate late completions
+func withRetries(name string, fn func() error) error {
+	var err error
+	backoff := retries.backoff
+	for attempt := 1; attempt <= retries.attempts; attempt++ {
+		if err = withTimeout(fn); err == nil {
+			return nil
+		}
+		if attempt == retries.attempts {
+			break
+		}
+		// Jitter avoids synchronized retries against shared infrastructure
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		log.Warnf("%s failed (attempt %d/%d), retrying in %v: %v", name, attempt, retries.attempts, wait.Truncate(time.Millisecond), err)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+	return err
+}
+
+func withTimeout(fn func() error) error {
+	if retries.timeout <= 0 {
+		return fn()
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(retries.timeout):
+		return fmt.Errorf("timed out after %v", retries.timeout)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// haproxySocket is the runtime API socket of the router HAProxy process
+const haproxySocket = "/var/lib/haproxy/run/haproxy.sock"
+
+// routerStatsCmd dumps the process information and the proxy counters through the runtime socket
+var routerStatsCmd = []string{"bash", "-c", "echo 'show info;show stat' | socat stdio " + haproxySocket}
+
+// sampleRouterStats samples the HAProxy runtime socket of the router pods of the scenario at the
+// routerStatsInterval until the context is cancelled, returning the time series of all of them
+func sampleRouterStats(ctx context.Context, cfg config.Config) []tools.RouterStat {
+	var stats []tools.RouterStat
+	var warned bool
+	ticker := time.NewTicker(cfg.RouterStatsInterval)
+	defer ticker.Stop()
+	for {
+		samples, err := routerStats(ctx, cfg)
+		if err != nil && ctx.Err() == nil && !warned {
+			log.Warnf("Couldn't sample the router runtime socket: %v", err)
+			warned = true
+		}
+		stats = append(stats, samples...)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logRouterStats(stats)
+			return stats
+		}
+	}
+}
+
+// routerStats samples the runtime socket of each running router pod of the scenario once
+func routerStats(ctx context.Context, cfg config.Config) ([]tools.RouterStat, error) {
+	pods, err := clientSet.CoreV1().Pods(routerNs).List(ctx, metav1.ListOptions{
+		LabelSelector: routerPodSelector(cfg),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stats []tools.RouterStat
+	for _, pod := range pods.Items {
+		stat, err := routerPodStats(ctx, pod, cfg.RouterStatsInterval)
+		if err != nil {
+			return stats, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// routerPodStats runs the runtime socket commands in the router container, which is in the target cluster
+func routerPodStats(ctx context.Context, pod corev1.Pod, timeout time.Duration) (tools.RouterStat, error) {
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ts := time.Now().UTC()
+	stdout, stderr, err := clusterExec(execCtx, clientSet, restConfig, pod, "router", routerStatsCmd)
+	if err != nil {
+		return tools.RouterStat{}, fmt.Errorf("pod %s: %v %s", pod.Name, err, stderr)
+	}
+	stat := parseRouterStats(stdout)
+	stat.Timestamp, stat.Pod = ts, pod.Name
+	return stat, nil
+}
+
+// parseRouterStats parses the show info key-value lines and the show stat CSV. Only the backends of the routes
+// namespace, named be_<type>:<namespace>:<route>, and the frontends are accounted
+func parseRouterStats(output string) tools.RouterStat {
+	var stat tools.RouterStat
+	var header map[string]int
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			header = make(map[string]int)
+			for i, name := range strings.Split(strings.TrimPrefix(line, "# "), ",") {
+				header[name] = i
+			}
+			continue
+		}
+		if header == nil {
+			key, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			n, _ := strconv.ParseInt(value, 10, 64)
+			switch key {
+			case "CurrConns":
+				stat.CurrConns = n
+			case "MaxConn":
+				stat.MaxConn = n
+			case "ConnRate":
+				stat.ConnRate = n
+			case "SessRate":
+				stat.SessRate = n
+			case "Idle_pct":
+				stat.IdlePct = n
+			}
+			continue
+		}
+		fields := strings.Split(line, ",")
+		field := func(name string) int64 {
+			i, ok := header[name]
+			if !ok || i >= len(fields) {
+				return 0
+			}
+			n, _ := strconv.ParseInt(fields[i], 10, 64)
+			return n
+		}
+		pxName, svName := fields[header["pxname"]], fields[header["svname"]]
+		switch {
+		case svName == "FRONTEND":
+			stat.Denied += field("dreq") + field("dcon") + field("dses")
+		case svName == "BACKEND" && strings.Contains(pxName, ":"+routesNamespace+":"):
+			stat.Sessions += field("scur")
+			stat.Queue += field("qcur")
+			stat.MaxQueue += field("qmax")
+			stat.Denied += field("dreq")
+		}
+	}
+	return stat
+}
+
+// logRouterStats logs the peak connections and queue of the sample, and the requests denied during it
+func logRouterStats(stats []tools.RouterStat) {
+	if len(stats) == 0 {
+		return
+	}
+	var maxConns, maxQueue, denied int64
+	first := make(map[string]int64)
+	last := make(map[string]int64)
+	for _, s := range stats {
+		if s.CurrConns > maxConns {
+			maxConns = s.CurrConns
+		}
+		if s.Queue > maxQueue {
+			maxQueue = s.Queue
+		}
+		if _, ok := first[s.Pod]; !ok {
+			first[s.Pod] = s.Denied
+		}
+		last[s.Pod] = s.Denied
+	}
+	for pod, d := range last {
+		denied += d - first[pod]
+	}
+	log.Infof("Router runtime stats: %d samples, max connections=%d max queue=%d denied=%d", len(stats), maxConns, maxQueue, denied)
+}
@@ -0,0 +1,301 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	backgroundRouteLabel = "app=ingress-perf-background"
+	sniRouteLabel        = "app=ingress-perf-sni"
+	// Max number of in-flight API requests when reconciling routes
+	routeWorkers = 20
+)
+
+func backgroundRoute(i int) *routev1.Route {
+	r := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-background-%d", serverName, i),
+			Labels: map[string]string{
+				"app": "ingress-perf-background",
+			},
+		},
+		Spec: routev1.RouteSpec{
+			Port: &routev1.RoutePort{TargetPort: httpTargetPort},
+			To: routev1.RouteTargetReference{
+				Name: service.Name,
+			},
+		},
+	}
+	for k, v := range resourceLabels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// reconcileBackgroundRoutes makes sure exactly count inert routes exist in the routes namespace,
+// these routes are not used by the benchmark but they increase the size of the HAProxy configuration
+func reconcileBackgroundRoutes(count int) error {
+	var desired []*routev1.Route
+	for i := 0; i < count; i++ {
+		desired = append(desired, backgroundRoute(i))
+	}
+	return reconcileRoutes(backgroundRouteLabel, desired)
+}
+
+// reconcileSNIRoutes makes sure count routes with the scenario termination and distinct hostnames exist
+func reconcileSNIRoutes(cfg config.Config) error {
+	var desired []*routev1.Route
+	for _, route := range routes {
+		if route.Name != fmt.Sprintf("%s-%s", serverName, cfg.Termination) {
+			continue
+		}
+		for i := 0; i < cfg.SNIHosts; i++ {
+			r := route.DeepCopy()
+			r.Name = fmt.Sprintf("%s-sni-%s-%d", serverName, cfg.Termination, i)
+			r.Labels = map[string]string{"app": "ingress-perf-sni"}
+			for k, v := range resourceLabels {
+				r.Labels[k] = v
+			}
+			desired = append(desired, r)
+		}
+	}
+	return reconcileRoutes(sniRouteLabel, desired)
+}
+
+// sniHosts returns the hosts of the SNI routes of the scenario
+func sniHosts(cfg config.Config) ([]string, error) {
+	var hosts []string
+	for i := 0; i < cfg.SNIHosts; i++ {
+		r, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), fmt.Sprintf("%s-sni-%s-%d", serverName, cfg.Termination, i), metav1.GetOptions{})
+		if err != nil {
+			return hosts, err
+		}
+		hosts = append(hosts, r.Spec.Host)
+	}
+	return hosts, nil
+}
+
+// reconcileRoutes creates the desired routes and removes the existing ones matching the label selector
+// that are not part of the desired list
+func reconcileRoutes(labelSelector string, desired []*routev1.Route) error {
+	existing := make(map[string]bool)
+	routeList, err := orClientSet.RouteV1().Routes(routesNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+	for _, r := range routeList.Items {
+		existing[r.Name] = true
+	}
+	if len(desired) == 0 {
+		if len(existing) == 0 {
+			return nil
+		}
+		return deleteRoutes(labelSelector)
+	}
+	errGroup := errgroup.Group{}
+	errGroup.SetLimit(routeWorkers)
+	var created int
+	for _, route := range desired {
+		route := route
+		if existing[route.Name] {
+			delete(existing, route.Name)
+			continue
+		}
+		created++
+		errGroup.Go(func() error {
+			_, err := orClientSet.RouteV1().Routes(routesNamespace).Create(context.TODO(), route, metav1.CreateOptions{})
+			if err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			return nil
+		})
+	}
+	// Remaining routes are not desired anymore
+	for name := range existing {
+		name := name
+		errGroup.Go(func() error {
+			err := orClientSet.RouteV1().Routes(routesNamespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+	}
+	if created > 0 || len(existing) > 0 {
+		log.Infof("Reconciling routes %s: %d created, %d deleted", labelSelector, created, len(existing))
+	}
+	return errGroup.Wait()
+}
+
+// deleteRoutes removes all routes matching the label selector with a single DeleteCollection call
+func deleteRoutes(labelSelector string) error {
+	log.Infof("Deleting routes %s", labelSelector)
+	return orClientSet.RouteV1().Routes(routesNamespace).DeleteCollection(
+		context.TODO(),
+		metav1.DeleteOptions{},
+		metav1.ListOptions{LabelSelector: labelSelector},
+	)
+}
+
+// routeTargetPort returns the service port the given benchmark route must target in the scenario
+func routeTargetPort(cfg config.Config, route routev1.Route) intstr.IntOrString {
+	if cfg.TargetPort != "" && route.Name == fmt.Sprintf("%s-%s", serverName, cfg.Termination) {
+		return intstr.Parse(cfg.TargetPort)
+	}
+	return route.Spec.Port.TargetPort
+}
+
+// reconcileRoutePorts points the benchmark and SNI routes to the service port of the scenario,
+// routes of other terminations are set back to the port defined in the routes template
+func reconcileRoutePorts(cfg config.Config) error {
+	if len(existingRoutes) > 0 {
+		return nil
+	}
+	for _, route := range routes {
+		port := routeTargetPort(cfg, route)
+		if bundledServer() && !servicePortExists(port) {
+			return fmt.Errorf("service %s has no port %s", service.Name, port.String())
+		}
+		if err := updateRoutePort(route.Name, port); err != nil {
+			return err
+		}
+		if route.Name != fmt.Sprintf("%s-%s", serverName, cfg.Termination) {
+			continue
+		}
+		sniRoutes, err := orClientSet.RouteV1().Routes(routesNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: sniRouteLabel})
+		if err != nil {
+			return err
+		}
+		for _, r := range sniRoutes.Items {
+			if err := updateRoutePort(r.Name, port); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func servicePortExists(port intstr.IntOrString) bool {
+	for _, p := range service.Spec.Ports {
+		if (port.Type == intstr.String && p.Name == port.StrVal) || (port.Type == intstr.Int && p.Port == port.IntVal) {
+			return true
+		}
+	}
+	return false
+}
+
+func updateRoutePort(name string, port intstr.IntOrString) error {
+	r, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if r.Spec.Port != nil && r.Spec.Port.TargetPort == port {
+		return nil
+	}
+	log.Debugf("Updating route %s target port: %s", r.Name, port.String())
+	r.Spec.Port = &routev1.RoutePort{TargetPort: port}
+	_, err = orClientSet.RouteV1().Routes(routesNamespace).Update(context.TODO(), r, metav1.UpdateOptions{})
+	return err
+}
+
+// routeAnnotations returns the annotations of the benchmark routes required by the scenario
+func routeAnnotations(cfg config.Config) map[string]string {
+	annotations := make(map[string]string)
+	for k, v := range cfg.RouteAnnotations {
+		annotations[k] = v
+	}
+	if cfg.StickySessions {
+		annotations["router.openshift.io/cookie_name"] = stickyCookie
+	}
+	if cfg.RateLimit != nil {
+		annotations["haproxy.router.openshift.io/rate-limit-connections"] = "true"
+		for annotation, limit := range map[string]int{
+			"concurrent-tcp": cfg.RateLimit.ConcurrentTCP,
+			"rate-http":      cfg.RateLimit.RateHTTP,
+			"rate-tcp":       cfg.RateLimit.RateTCP,
+		} {
+			if limit > 0 {
+				annotations["haproxy.router.openshift.io/rate-limit-connections."+annotation] = strconv.Itoa(limit)
+			}
+		}
+	}
+	if cfg.Balance != "" {
+		annotations["haproxy.router.openshift.io/balance"] = cfg.Balance
+	}
+	if cfg.IPWhitelist > 0 {
+		annotations["haproxy.router.openshift.io/ip_whitelist"] = ipWhitelist(cfg)
+	}
+	// HAProxy doesn't understand compound durations like 1m30s
+	if cfg.RouteTimeout != 0 {
+		timeout := fmt.Sprintf("%dms", cfg.RouteTimeout.Milliseconds())
+		annotations["haproxy.router.openshift.io/timeout"] = timeout
+		annotations["haproxy.router.openshift.io/timeout-tunnel"] = timeout
+	}
+	return annotations
+}
+
+// ipWhitelist returns ipWhitelist CIDRs of the benchmark range 198.18.0.0/15, which no client uses, followed by the
+// allowed sources, any address by default
+func ipWhitelist(cfg config.Config) string {
+	cidrs := make([]string, 0, cfg.IPWhitelist+2)
+	for i := 0; i < cfg.IPWhitelist; i++ {
+		cidrs = append(cidrs, fmt.Sprintf("198.%d.%d.%d/32", 18+i>>16, i>>8&0xff, i&0xff))
+	}
+	if len(cfg.IPWhitelistSources) == 0 {
+		return strings.Join(append(cidrs, "0.0.0.0/0", "::/0"), " ")
+	}
+	return strings.Join(append(cidrs, cfg.IPWhitelistSources...), " ")
+}
+
+// reconcileRouteAnnotations sets the annotations of the benchmark routes to the ones defined in the
+// routes template plus the ones required by the scenario, annotations from previous scenarios are removed
+func reconcileRouteAnnotations(cfg config.Config) error {
+	if len(existingRoutes) > 0 {
+		return nil
+	}
+	for _, route := range routes {
+		annotations := routeAnnotations(cfg)
+		for k, v := range route.Annotations {
+			annotations[k] = v
+		}
+		r, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), route.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(r.Annotations, annotations) {
+			continue
+		}
+		log.Debugf("Updating route %s annotations: %v", r.Name, annotations)
+		r.Annotations = annotations
+		if _, err = orClientSet.RouteV1().Routes(routesNamespace).Update(context.TODO(), r, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -18,17 +18,26 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/cloud-bulldozer/go-commons/indexers"
 	"github.com/cloud-bulldozer/go-commons/prometheus"
+	"github.com/cloud-bulldozer/go-commons/version"
 
 	ocpmetadata "github.com/cloud-bulldozer/go-commons/ocp-metadata"
 	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/elastic"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/horreum"
+	ilog "github.com/cloud-bulldozer/ingress-perf/pkg/log"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/notify"
 	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/storage"
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -50,7 +59,107 @@ var clientSet *kubernetes.Clientset
 var istioClient *istioclient.Clientset
 var dynamicClient *dynamic.DynamicClient
 var orClientSet *openshiftrouteclientset.Clientset
-var currentTuning string
+
+// currentTuning last tuning patch applied to each IngressController
+var currentTuning = map[string]string{}
+
+// clientCluster runs the client pods, it's the target cluster unless a client kubeconfig or context is given
+var clientClusterConfig *rest.Config
+var clientCluster *kubernetes.Clientset
+
+// Kubeconfig file and context used to build the clients, empty values fall back to the default loading rules
+var kubeconfigPath, kubeContext string
+
+// Kubeconfig file and context of the cluster running the client pods, empty when it's the target cluster
+var clientKubeconfigPath, clientKubeContext string
+
+// SetKubeconfig selects the kubeconfig file and context used to connect to the cluster
+func SetKubeconfig(kubeconfig, context string) {
+	kubeconfigPath = kubeconfig
+	kubeContext = context
+}
+
+// SetClientKubeconfig runs the client pods in the cluster of the given kubeconfig file and context, while the routes,
+// backends and metrics are still taken from the target cluster
+func SetClientKubeconfig(kubeconfig, context string) {
+	clientKubeconfigPath = kubeconfig
+	clientKubeContext = context
+}
+
+// multiCluster returns whether the client pods run in a different cluster than the target one
+func multiCluster() bool {
+	return clientKubeconfigPath != "" || clientKubeContext != ""
+}
+
+// initClients initializes the kubernetes clients from the selected kubeconfig and context, when not set,
+// the KUBECONFIG environment variable, ~/.kube/config or the in-cluster configuration are used
+func initClients() error {
+	var err error
+	restConfig, err = loadRestConfig(kubeconfigPath, kubeContext)
+	if err != nil {
+		return err
+	}
+	log.Infof("Using cluster %s", restConfig.Host)
+	clientSet = kubernetes.NewForConfigOrDie(restConfig)
+	istioClient = istioclient.NewForConfigOrDie(restConfig)
+	orClientSet = openshiftrouteclientset.NewForConfigOrDie(restConfig)
+	dynamicClient = dynamic.NewForConfigOrDie(restConfig)
+	clientClusterConfig, clientCluster = restConfig, clientSet
+	if multiCluster() {
+		// The client kubeconfig defaults to the target one, so a context of the same kubeconfig can be given alone
+		path := clientKubeconfigPath
+		if path == "" {
+			path = kubeconfigPath
+		}
+		if clientClusterConfig, err = loadRestConfig(path, clientKubeContext); err != nil {
+			return fmt.Errorf("error loading the client cluster kubeconfig: %w", err)
+		}
+		log.Infof("Running client pods in cluster %s", clientClusterConfig.Host)
+		clientCluster = kubernetes.NewForConfigOrDie(clientClusterConfig)
+	}
+	return nil
+}
+
+// clusters returns the clients of the target cluster and, when different, of the client cluster
+func clusters() []*kubernetes.Clientset {
+	if multiCluster() {
+		return []*kubernetes.Clientset{clientSet, clientCluster}
+	}
+	return []*kubernetes.Clientset{clientSet}
+}
+
+func loadRestConfig(kubeconfig, context string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: context})
+	if context != "" {
+		rawConfig, err := clientConfig.RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rawConfig.Contexts[context]; !ok {
+			return nil, fmt.Errorf("context %q not found in kubeconfig", context)
+		}
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.QPS = apiQPS
+	config.Burst = apiBurst
+	limiter := newAdaptiveRateLimiter(config.Host, apiQPS, apiBurst)
+	config.RateLimiter = limiter
+	config.Wrap(limiter.wrap)
+	return config, nil
+}
+
+// DynamicClient returns a dynamic client of the selected cluster
+func DynamicClient() (dynamic.Interface, error) {
+	if err := initClients(); err != nil {
+		return nil, err
+	}
+	return dynamicClient, nil
+}
 
 func New(uuid string, cleanup bool, opts ...OptsFunctions) *Runner {
 	r := &Runner{
@@ -63,7 +172,9 @@ func New(uuid string, cleanup bool, opts ...OptsFunctions) *Runner {
 	return r
 }
 
-func WithIndexer(esServer, esIndex, resultsDir string, podMetrics bool) OptsFunctions {
+// WithIndexer indexes the results in the given Elasticsearch index, or data stream when dataStream is set, or in the
+// results directory when there's no Elasticsearch server
+func WithIndexer(esServer, esIndex, resultsDir string, podMetrics, dataStream bool) OptsFunctions {
 	return func(r *Runner) {
 		if esServer != "" || resultsDir != "" {
 			var indexerCfg indexers.IndexerConfig
@@ -79,21 +190,191 @@ func WithIndexer(esServer, esIndex, resultsDir string, podMetrics bool) OptsFunc
 					MetricsDirectory: resultsDir,
 				}
 			}
+			r.indexerCfg = indexerCfg
+			r.podMetrics = podMetrics
+			r.dataStream = dataStream && esServer != ""
+			// Documents that can't be indexed in Elasticsearch are stored in the results directory
+			if esServer != "" && resultsDir != "" {
+				r.spillDir = path.Join(resultsDir, pendingDir)
+			}
 			log.Infof("Creating %s indexer", indexerCfg.Type)
-			indexer, err := indexers.NewIndexer(indexerCfg)
+			indexer, err := r.newIndexer()
 			if err != nil {
-				log.Fatal(err)
+				if r.spillDir == "" {
+					log.Fatal(err)
+				}
+				log.Errorf("Error creating indexer, documents will be stored in %s: %v", r.spillDir, err)
+				return
 			}
 			r.indexer = indexer
-			r.podMetrics = podMetrics
 		}
 	}
 }
 
+// WithIndexTemplate creates or verifies the index template of the Elasticsearch index, along with an ILM
+// policy deleting indices older than the given retention, 0 disables the policy
+func WithIndexTemplate(esServer, esIndex string, retention time.Duration, dataStream bool) OptsFunctions {
+	return func(r *Runner) {
+		if esServer == "" {
+			return
+		}
+		if err := elastic.EnsureTemplate(esServer, esIndex, retention, dataStream); err != nil {
+			log.Fatalf("Error managing the index template: %v", err)
+		}
+	}
+}
+
+// WithProgress logs the run progress and ETA with the given interval, 0 disables periodic reporting
+func WithProgress(interval time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		r.progressInterval = interval
+	}
+}
+
+// WithDashboard renders a live dashboard in the terminal, refreshed with the given interval, 0 disables it
+func WithDashboard(interval time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		r.dashboard = interval
+	}
+}
+
+// WithMetrics exposes the live metrics of the run in the Prometheus format at the given address
+func WithMetrics(addr string) OptsFunctions {
+	return func(r *Runner) {
+		r.metricsAddr = addr
+	}
+}
+
+// WithNotification posts the run summary to the given URL when the run completes, format is webhook or slack
+func WithNotification(url, format string) OptsFunctions {
+	return func(r *Runner) {
+		r.notifyURL = url
+		r.notifyFormat = format
+	}
+}
+
+// WithEmail sends the run summary by email once the run finishes
+func WithEmail(cfg notify.EmailConfig) OptsFunctions {
+	return func(r *Runner) {
+		if cfg.From == "" || len(cfg.To) == 0 {
+			log.Fatal("Summary emails require the sender and at least one recipient")
+		}
+		r.email = &cfg
+	}
+}
+
+// WithGrafana posts annotations to the given Grafana instance marking each test and sample, token is optional
+func WithGrafana(url, token string) OptsFunctions {
+	return func(r *Runner) {
+		r.grafanaURL = url
+		r.grafanaToken = token
+	}
+}
+
+// WithTracing exports the spans of the run phases to the given OTLP/HTTP endpoint, i.e: http://otel-collector:4318
+func WithTracing(endpoint string) OptsFunctions {
+	return func(r *Runner) {
+		r.otlpEndpoint = endpoint
+	}
+}
+
+// WithEvents sends the run events to the given handler as they're produced
+func WithEvents(handler func(tools.Event)) OptsFunctions {
+	return func(r *Runner) {
+		r.events = handler
+	}
+}
+
+// WithSQL stores the results in the given database, in addition to the configured indexer
+func WithSQL(driver, dsn string) OptsFunctions {
+	return func(r *Runner) {
+		store, err := storage.NewSQL(driver, dsn)
+		if err != nil {
+			log.Fatalf("Error connecting to the %s database: %v", driver, err)
+		}
+		r.sqlStore = store
+	}
+}
+
+// WithHorreum uploads the run summary to the given Horreum instance once the run completes
+func WithHorreum(cfg horreum.Config) OptsFunctions {
+	return func(r *Runner) {
+		if cfg.Test == "" || cfg.Owner == "" {
+			log.Fatal("Horreum uploads require the test name and the owner role")
+		}
+		r.horreum = &cfg
+	}
+}
+
+// WithArtifacts stores the logs, the effective configuration and the commands executed in the client pods in the given directory
+func WithArtifacts(dir string) OptsFunctions {
+	return func(r *Runner) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+		artifactsDir = dir
+		f, err := ilog.TeeToFile(path.Join(dir, logFile))
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.logFile = f
+	}
+}
+
+// WithImages overrides the registry and repository of the client and server images, i.e: mirror.local:5000/cloud-bulldozer,
+// and the full client and server images, which take precedence over the registry
+func WithImages(registry, clientImg, serverImg string) OptsFunctions {
+	return func(r *Runner) {
+		if registry != "" {
+			clientImg = firstNonEmpty(clientImg, overrideRegistry(clientImage, registry))
+			serverImg = firstNonEmpty(serverImg, overrideRegistry(serverImage, registry))
+		}
+		if clientImg != "" {
+			client.Spec.Template.Spec.Containers[0].Image = clientImg
+		}
+		if serverImg != "" {
+			server.Spec.Template.Spec.Containers[0].Image = serverImg
+		}
+	}
+}
+
+// overrideRegistry replaces the registry and repository of the image, keeping its name and tag
+func overrideRegistry(image, registry string) string {
+	return strings.TrimSuffix(registry, "/") + image[strings.LastIndex(image, "/"):]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// WithHostNetwork runs the client pods in the host network namespace. It isn't allowed by the restricted pod security
+// profile, so the benchmark namespace is labeled to enforce the privileged profile instead
+func WithHostNetwork(enable bool) OptsFunctions {
+	return func(r *Runner) {
+		r.hostNetwork = enable
+		client.Spec.Template.Spec.HostNetwork = enable
+	}
+}
+
+// WithNamespaceMetadata adds the given labels and annotations to the benchmark namespaces, i.e: network policy
+// selectors or cost center labels. They're applied after the ingress-perf labels, so they can override them
+func WithNamespaceMetadata(labels, annotations map[string]string) OptsFunctions {
+	return func(r *Runner) {
+		r.nsLabels = labels
+		r.nsAnnotations = annotations
+	}
+}
+
 func WithServiceMesh(enable bool, igNamespace string) OptsFunctions {
 	return func(r *Runner) {
 		r.serviceMesh = enable
 		r.igNamespace = igNamespace
+		istioNamespace = igNamespace
 		config.PrometheusQueries["avg_cpu_usage_ingress_gateway_pods"] =
 			fmt.Sprintf("avg(avg_over_time(sum(irate(container_cpu_usage_seconds_total{name!='', namespace='%s', pod=~'istio-ingressgateway.+'}[2m])) by (pod)[ELAPSED:]))", igNamespace)
 		config.PrometheusQueries["avg_memory_usage_ingress_gateway_pods_bytes"] =
@@ -101,28 +382,61 @@ func WithServiceMesh(enable bool, igNamespace string) OptsFunctions {
 	}
 }
 
-func (r *Runner) Start() error {
-	var err error
-	var kubeconfig string
+func (r *Runner) Start() (err error) {
 	var benchmarkResult []tools.Result
 	var clusterMetadata tools.ClusterMetadata
+	defer func() { r.metadata = clusterMetadata }()
 	var benchmarkResultDocuments []interface{}
 	passed := true
-	if os.Getenv("KUBECONFIG") != "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
-	} else if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".kube", "config")); kubeconfig == "" && !os.IsNotExist(err) {
-		kubeconfig = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+	r.summary = tools.Summary{UUID: r.uuid}
+	eventHandler = r.events
+	defer func() {
+		r.summary.Passed = err == nil
+		if err != nil {
+			r.summary.Error = err.Error()
+		}
+		emit(EventFinished, 0, r.summary)
+		eventHandler = nil
+		if r.notifyURL != "" {
+			log.Infof("Sending %s notification", r.notifyFormat)
+			if notifyErr := notify.Send(r.notifyURL, r.notifyFormat, r.summary); notifyErr != nil {
+				log.Errorf("Notification error: %v", notifyErr)
+			}
+		}
+		if r.email != nil {
+			log.Infof("Sending summary email to %s", strings.Join(r.email.To, ", "))
+			if emailErr := notify.SendEmail(*r.email, r.summary); emailErr != nil {
+				log.Errorf("Email error: %v", emailErr)
+			}
+		}
+	}()
+	currentTest = 0
+	runStart := time.Now().UTC()
+	defer func() {
+		if err != nil {
+			collectDiagnostics(err.Error())
+		}
+	}()
+	if r.otlpEndpoint != "" {
+		runTracer = newTracer(r.otlpEndpoint)
+		runTracer.startRun(r.uuid)
+		defer func() {
+			runTracer.finish(err)
+			runTracer = nil
+		}()
 	}
-	restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
+	if r.logFile != nil {
+		defer r.logFile.Close()
+	}
+	if r.sqlStore != nil {
+		defer r.sqlStore.Close()
+	}
+	if err := writeConfig(config.Cfg); err != nil {
+		return err
+	}
+	if err = initClients(); err != nil {
 		return err
 	}
-	restConfig.QPS = 200
-	restConfig.Burst = 200
-	clientSet = kubernetes.NewForConfigOrDie(restConfig)
-	istioClient = istioclient.NewForConfigOrDie(restConfig)
-	orClientSet = openshiftrouteclientset.NewForConfigOrDie(restConfig)
-	dynamicClient = dynamic.NewForConfigOrDie(restConfig)
 	ocpMetadata, err := ocpmetadata.NewMetadata(restConfig)
 	if err != nil {
 		return err
@@ -147,13 +461,78 @@ func (r *Runner) Start() error {
 	} else {
 		log.Infof("HAProxy version: %s", clusterMetadata.HAProxyVersion)
 	}
+	r.updateIngressMetadata(&clusterMetadata)
+	if clusterMetadata.Architecture, err = selectArch(); err != nil {
+		return err
+	}
+	clusterMetadata.ClientImage = client.Spec.Template.Spec.Containers[0].Image
+	clusterMetadata.Backend = backendRef
+	clusterMetadata.Routes = routeRefs
+	clusterMetadata.ExternalClients = externalClients
+	if multiCluster() {
+		clusterMetadata.ClientCluster = clientClusterConfig.Host
+	}
+	if bundledServer() {
+		clusterMetadata.ServerImage = server.Spec.Template.Spec.Containers[0].Image
+	}
+	log.Infof("Client image: %s, server image: %s", clusterMetadata.ClientImage, clusterMetadata.ServerImage)
+	r.initSeed()
+	clusterMetadata.Seed = runSeed
+	if clusterMetadata.ConfigHash, err = configHash(config.Cfg); err != nil {
+		return err
+	}
+	log.Infof("Configuration hash: %s, seed: %d", clusterMetadata.ConfigHash, clusterMetadata.Seed)
+	runProgress = newProgress(config.Cfg)
+	if r.progressInterval > 0 {
+		progressCtx, cancelProgress := context.WithCancel(context.Background())
+		defer cancelProgress()
+		go runProgress.report(progressCtx, r.progressInterval)
+	}
+	liveMetrics.reset(r.uuid, len(config.Cfg))
+	if r.metricsAddr != "" {
+		serveMetrics(r.metricsAddr)
+	}
+	if r.pprofAddr != "" {
+		serveProfiling(r.pprofAddr)
+	}
+	if r.profileInterval > 0 {
+		profileCtx, cancelProfiles := context.WithCancel(context.Background())
+		profilesDone := make(chan struct{})
+		defer func() {
+			cancelProfiles()
+			<-profilesDone
+		}()
+		go dumpProfiles(profileCtx, r.profileDir, r.profileInterval, profilesDone)
+	}
+	if r.grafanaURL != "" {
+		annotator = newGrafanaAnnotator(r.grafanaURL, r.grafanaToken, r.uuid)
+		defer func() { annotator = nil }()
+	}
+	if r.dashboard > 0 {
+		dashboardCtx, cancelDashboard := context.WithCancel(context.Background())
+		defer cancelDashboard()
+		liveDashboard = newDashboard(p)
+		go liveDashboard.run(dashboardCtx, r.dashboard)
+	}
+	setPhase("deploy")
 	if err := r.deployAssets(); err != nil {
 		return err
 	}
 	for i, cfg := range config.Cfg {
 		cfg.UUID = r.uuid
+		testStart := time.Now()
+		currentTest = i + 1
+		ilog.SetField("test", currentTest)
+		runProgress.startTest(currentTest, cfg)
+		liveDashboard.startTest(cfg)
+		liveMetrics.startTest(currentTest, cfg)
+		runTracer.startTest(currentTest, cfg)
+		emit(EventTest, 0, cfg)
+		setPhase("reconcile")
+		r.summary.Tests = append(r.summary.Tests, tools.TestSummary{Test: currentTest, Config: cfg})
+		testSummary := &r.summary.Tests[len(r.summary.Tests)-1]
 		log.Infof("Running test %d/%d", i+1, len(config.Cfg))
-		log.Infof("Tool:%s termination:%v servers:%d concurrency:%d procs:%d connections:%d duration:%v",
+		log.Infof("Tool:%s termination:%v servers:%d concurrency:%d procs:%d connections:%d duration:%v backgroundRoutes:%d",
 			cfg.Tool,
 			cfg.Termination,
 			cfg.ServerReplicas,
@@ -161,39 +540,155 @@ func (r *Runner) Start() error {
 			cfg.Procs,
 			cfg.Connections,
 			cfg.Duration,
+			cfg.BackgroundRoutes,
 		)
+		if err := reconcileClientZones(cfg); err != nil {
+			return err
+		}
 		if err := reconcileNs(cfg); err != nil {
 			return err
 		}
-		if cfg.Tuning != "" {
-			currentTuning = cfg.Tuning
-			if err = applyTunning(cfg.Tuning); err != nil {
+		if err := reconcileBackgroundRoutes(cfg.BackgroundRoutes); err != nil {
+			return err
+		}
+		if err := reconcileTenants(cfg.Tenants); err != nil {
+			return err
+		}
+		if err := reconcileSNIRoutes(cfg); err != nil {
+			return err
+		}
+		if err := reconcileRouteAnnotations(cfg); err != nil {
+			return err
+		}
+		if err := reconcileControllerRoute(cfg); err != nil {
+			return err
+		}
+		// In service mesh mode routes target the ingress gateway
+		if !r.serviceMesh {
+			if err := reconcileRoutePorts(cfg); err != nil {
+				return err
+			}
+			if err := reconcileBackendWeights(cfg); err != nil {
+				return err
+			}
+			if err := reconcileCertificates(cfg); err != nil {
 				return err
 			}
 		}
-		if benchmarkResult, err = runBenchmark(cfg, clusterMetadata, p, r.podMetrics); err != nil {
+		if err := reconcileIngress(cfg); err != nil {
 			return err
 		}
-		if r.indexer != nil && !cfg.Warmup {
-			for _, res := range benchmarkResult {
-				benchmarkResultDocuments = append(benchmarkResultDocuments, res)
+		if err := waitForRouteAdmission(cfg); err != nil {
+			return err
+		}
+		if cfg.Tuning != "" {
+			setPhase("tuning")
+			currentTuning[tunedController(cfg)] = cfg.Tuning
+			if err = applyTunning(cfg); err != nil {
+				return err
+			}
+			r.updateIngressMetadata(&clusterMetadata)
+		}
+		if cfg.RoutePropagation > 0 {
+			propagationResult, err := runRoutePropagation(cfg, clusterMetadata)
+			if err != nil {
+				return err
 			}
-			// When not using local indexer, empty the documents array when all documents after indexing them
-			if _, ok := (*r.indexer).(*indexers.Local); !ok {
-				if indexDocuments(*r.indexer, benchmarkResultDocuments, indexers.IndexingOpts{}) != nil {
-					log.Errorf("Indexing error: %v", err.Error())
+			testSummary.Samples = len(propagationResult.Latencies)
+			emit(EventResult, 0, propagationResult)
+			if r.sqlStore != nil && !cfg.Warmup {
+				if err := r.sqlStore.StorePropagation(currentTest, cfg, propagationResult); err != nil {
+					log.Errorf("Error storing results in the database: %v", err)
+				}
+			}
+			if r.indexing() && !cfg.Warmup {
+				benchmarkResultDocuments = append(benchmarkResultDocuments, propagationResult)
+				if r.kubeBurnerDocs {
+					benchmarkResultDocuments = append(benchmarkResultDocuments, kubeBurnerPropagation(currentTest, cfg, propagationResult)...)
+				}
+			}
+		} else {
+			if benchmarkResult, err = runBenchmark(cfg, clusterMetadata, p, r.podMetrics); err != nil {
+				return err
+			}
+			summarizeTest(testSummary, benchmarkResult)
+			if testSummary.Unstable {
+				log.Warnf("Unstable test, samples vary beyond varianceThreshold (%.2f): RPS CV=%.2f P99 latency CV=%.2f, consider rerunning it",
+					cfg.VarianceThreshold, testSummary.RpsCV, testSummary.P99LatencyCV)
+			}
+			// The intervals and the instability flag are indexed with every sample, so dashboards can display them
+			// next to the aggregates
+			for i := range benchmarkResult {
+				benchmarkResult[i].Confidence = testSummary.Confidence
+				benchmarkResult[i].Unstable = testSummary.Unstable
+			}
+			if len(benchmarkResult) < cfg.Samples {
+				collectDiagnostics(fmt.Sprintf("%d of %d samples failed", cfg.Samples-len(benchmarkResult), cfg.Samples))
+			}
+			if r.sqlStore != nil && !cfg.Warmup {
+				if err := r.sqlStore.StoreResults(currentTest, cfg, benchmarkResult); err != nil {
+					log.Errorf("Error storing results in the database: %v", err)
 				}
+			}
+			if r.indexing() && !cfg.Warmup {
+				for _, res := range benchmarkResult {
+					benchmarkResultDocuments = append(benchmarkResultDocuments, res)
+				}
+				if r.kubeBurnerDocs {
+					benchmarkResultDocuments = append(benchmarkResultDocuments, kubeBurnerResults(currentTest, cfg, benchmarkResult)...)
+				}
+			}
+		}
+		testSummary.Passed = testSummary.Samples > 0
+		if r.kubeBurnerDocs && r.indexing() && !cfg.Warmup {
+			benchmarkResultDocuments = append(benchmarkResultDocuments, kubeBurnerJobSummary(currentTest, cfg, clusterMetadata, testStart, time.Now(), testSummary.Passed))
+		}
+		annotator.annotateTest(currentTest, cfg, testStart, time.Now())
+		if cfg.ClientPlacement == config.CrossZone && i > 0 && config.Cfg[i-1].ClientPlacement == config.SameZone {
+			logZonePenalty(r.summary.Tests[len(r.summary.Tests)-2], *testSummary)
+		}
+		if cfg.SweepGroup > 0 && (i == len(config.Cfg)-1 || config.Cfg[i+1].SweepGroup != cfg.SweepGroup) {
+			sweep := compareTimeouts(r.uuid, cfg.SweepGroup, r.summary.Tests)
+			if r.indexing() && !cfg.Warmup {
+				benchmarkResultDocuments = append(benchmarkResultDocuments, sweep)
+			}
+		}
+		// The comparison is done once the last IngressController of the group has been benchmarked
+		if cfg.ComparisonGroup > 0 && (i == len(config.Cfg)-1 || config.Cfg[i+1].ComparisonGroup != cfg.ComparisonGroup) {
+			comparison := compareControllers(r.uuid, cfg.ComparisonGroup, r.summary.Tests)
+			if r.indexing() && !cfg.Warmup {
+				benchmarkResultDocuments = append(benchmarkResultDocuments, comparison)
+			}
+		}
+		if r.indexing() && !cfg.Warmup {
+			setPhase("indexing")
+			// When not using local indexer, empty the documents array when all documents after indexing them
+			if r.indexerCfg.Type != indexers.LocalIndexer {
+				r.index(benchmarkResultDocuments)
 				benchmarkResultDocuments = []interface{}{}
 			}
 		}
 	}
-	if _, ok := (*r.indexer).(*indexers.Local); r.indexer != nil && ok {
-		if err := indexDocuments(*r.indexer, benchmarkResultDocuments, indexers.IndexingOpts{MetricName: r.uuid}); err != nil {
+	runTracer.endTest()
+	if r.horreum != nil {
+		r.uploadHorreum(runStart, clusterMetadata)
+	}
+	if r.indexer != nil && r.indexerCfg.Type == indexers.LocalIndexer {
+		if err := indexDocuments(r.indexer, benchmarkResultDocuments, indexers.IndexingOpts{MetricName: r.uuid}); err != nil {
 			log.Errorf("Indexing error: %v", err.Error())
+		} else if r.compress {
+			if err := compressResults(path.Join(r.indexerCfg.MetricsDirectory, r.uuid+".json")); err != nil {
+				log.Errorf("Error compressing results: %v", err)
+			}
 		}
 	}
+	if r.spillDir != "" {
+		setPhase("indexing")
+		r.flushPending()
+	}
 	if r.cleanup {
-		if cleanup(10*time.Minute) != nil {
+		setPhase("cleanup")
+		if err := cleanupResources(fmt.Sprintf("%s=%s", uuidLabel, r.uuid), cleanupTimeout); err != nil {
 			return err
 		}
 	}
@@ -203,62 +698,210 @@ func (r *Runner) Start() error {
 	return fmt.Errorf("some benchmark comparisons failed")
 }
 
-func indexDocuments(indexer indexers.Indexer, documents []interface{}, indexingOpts indexers.IndexingOpts) error {
-	msg, err := indexer.Index(documents, indexingOpts)
+// uploadHorreum uploads the summary of the tests, except warmups, to Horreum
+func (r *Runner) uploadHorreum(start time.Time, clusterMetadata tools.ClusterMetadata) {
+	run := horreum.Run{
+		UUID:     r.uuid,
+		Version:  fmt.Sprintf("%v@%v", version.Version, version.GitCommit),
+		Start:    start,
+		Stop:     time.Now().UTC(),
+		Passed:   true,
+		Metadata: clusterMetadata,
+	}
+	for _, t := range r.summary.Tests {
+		if t.Config.Warmup {
+			continue
+		}
+		run.Passed = run.Passed && t.Passed
+		run.Tests = append(run.Tests, t)
+	}
+	log.Infof("Uploading run to Horreum test %s", r.horreum.Test)
+	id, err := horreum.Upload(*r.horreum, run)
 	if err != nil {
-		return err
+		log.Errorf("Horreum upload error: %v", err)
+		return
 	}
-	log.Info(msg)
-	return nil
+	log.Infof("Horreum run %s created", id)
 }
 
-func cleanup(timeout time.Duration) error {
-	log.Info("Cleaning up resources")
-	if err := clientSet.CoreV1().Namespaces().Delete(context.TODO(), benchmarkNs.Name, metav1.DeleteOptions{}); err != nil {
-		return err
+// Summary returns the run summary, it's populated by Start
+func (r *Runner) Summary() tools.Summary {
+	return r.summary
+}
+
+// summarizeTest aggregates the valid samples of a test with the aggregation method of the scenario,
+// timeouts and HTTP errors are always added up
+func summarizeTest(summary *tools.TestSummary, results []tools.Result) {
+	summary.Samples = len(results)
+	summary.Aggregation = tools.AggregationMethod(summary.Config)
+	if summary.Samples == 0 {
+		return
+	}
+	var rps, avgLatency, p95Latency, p99Latency, routerCPU, rpsPerCore, cpuPer1k []float64
+	for _, res := range results {
+		if cpu, ok := res.InfraMetrics["avg_cpu_usage_router_pods"]; ok {
+			routerCPU = append(routerCPU, cpu)
+		}
+		if res.RpsPerRouterCore > 0 {
+			rpsPerCore = append(rpsPerCore, res.RpsPerRouterCore)
+			cpuPer1k = append(cpuPer1k, res.RouterCPUPer1kReq)
+		}
+		rps = append(rps, res.TotalAvgRps)
+		avgLatency = append(avgLatency, res.AvgLatency)
+		p95Latency = append(p95Latency, res.P95Latency)
+		p99Latency = append(p99Latency, res.P99Latency)
+		summary.Timeouts += res.Timeouts
+		summary.HTTPErrors += res.HTTPErrors
+		summary.Requests += res.Requests
+	}
+	summary.AvgRps = tools.Aggregate(rps, summary.Aggregation, true)
+	summary.AvgLatency = tools.Aggregate(avgLatency, summary.Aggregation, false)
+	summary.P95Latency = tools.Aggregate(p95Latency, summary.Aggregation, false)
+	summary.P99Latency = tools.Aggregate(p99Latency, summary.Aggregation, false)
+	summary.RouterCPU = tools.Aggregate(routerCPU, summary.Aggregation, false)
+	summary.RpsPerRouterCore = tools.Aggregate(rpsPerCore, summary.Aggregation, true)
+	summary.RouterCPUPer1kReq = tools.Aggregate(cpuPer1k, summary.Aggregation, false)
+	summary.Confidence = tools.Bootstrap(results, summary.Aggregation)
+	summary.RpsCV = tools.CoefficientOfVariation(rps)
+	summary.P99LatencyCV = tools.CoefficientOfVariation(p99Latency)
+	threshold := summary.Config.VarianceThreshold
+	summary.Unstable = threshold > 0 && (summary.RpsCV > threshold || summary.P99LatencyCV > threshold)
+}
+
+// updateIngressMetadata refreshes the ingress controller details, they may change after applying a tuning patch
+func (r *Runner) updateIngressMetadata(clusterMetadata *tools.ClusterMetadata) {
+	var err error
+	clusterMetadata.EndpointPublishingStrategy, clusterMetadata.RouterProxyProtocol, err = getEndpointPublishingStrategy()
+	if err != nil {
+		log.Errorf("Couldn't fetch ingress controller endpoint publishing strategy: %v", err)
+		return
 	}
-	err := wait.PollUntilContextTimeout(context.TODO(), time.Second, timeout, true, func(ctx context.Context) (bool, error) {
-		_, err := clientSet.CoreV1().Namespaces().Get(context.TODO(), benchmarkNs.Name, metav1.GetOptions{})
+	log.Infof("Endpoint publishing strategy: %s, PROXY protocol: %v", clusterMetadata.EndpointPublishingStrategy, clusterMetadata.RouterProxyProtocol)
+}
+
+// indexDocuments indexes the documents, retrying failed attempts against Elasticsearch
+func indexDocuments(indexer documentIndexer, documents []interface{}, indexingOpts indexers.IndexingOpts) error {
+	switch indexer.(type) {
+	case *elastic.Index, *elastic.DataStream:
+	default:
+		msg, err := indexer.Index(documents, indexingOpts)
 		if err != nil {
-			if errors.IsNotFound(err) {
-				return true, nil
-			}
-			return false, err
+			return err
 		}
-		return false, nil
+		log.Info(msg)
+		return nil
+	}
+	return withRetries("Indexing", func() error {
+		return esIndex(indexer, documents, indexingOpts)
 	})
+}
+
+func esIndex(indexer documentIndexer, documents []interface{}, indexingOpts indexers.IndexingOpts) error {
+	msg, err := indexer.Index(documents, indexingOpts)
 	if err != nil {
 		return err
 	}
-	return clientSet.RbacV1().ClusterRoleBindings().Delete(context.Background(), clientCRB.Name, metav1.DeleteOptions{})
+	log.Info(msg)
+	// The Elasticsearch indexer only reports the documents indexed successfully, i.e: created=2 updated=1
+	var indexed int
+	for _, stat := range strings.Fields(msg) {
+		var count int
+		if i := strings.Index(stat, "="); i > 0 {
+			if _, err := fmt.Sscanf(stat[i+1:], "%d", &count); err == nil {
+				indexed += count
+			}
+		}
+	}
+	if indexed < len(documents) {
+		return fmt.Errorf("only %d out of %d documents were indexed", indexed, len(documents))
+	}
+	return nil
 }
 
 func (r *Runner) deployAssets() error {
 	log.Infof("Deploying benchmark assets")
+	resourceLabels[uuidLabel] = r.uuid
+	for k, v := range resourceLabels {
+		benchmarkNs.Labels[k] = v
+		clientCRB.Labels[k] = v
+		for _, route := range routes {
+			route.Labels[k] = v
+		}
+	}
 	if r.serviceMesh {
 		log.Info("Service mesh mode enabled")
 		benchmarkNs.Labels["istio-injection"] = "enabled"
+		for i, cfg := range config.Cfg {
+			if len(cfg.BackendWeights) > 0 {
+				return fmt.Errorf("scenario %d: backendWeights aren't supported in service mesh mode", i+1)
+			}
+			if cfg.Certificate != nil {
+				return fmt.Errorf("scenario %d: certificate isn't supported in service mesh mode", i+1)
+			}
+			if envoyIngress(cfg) {
+				return fmt.Errorf("scenario %d: the %s ingress isn't supported in service mesh mode", i+1, cfg.Ingress)
+			}
+		}
 	}
-	_, err := clientSet.CoreV1().Namespaces().Create(context.TODO(), &benchmarkNs, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return err
+	if r.hostNetwork {
+		log.Info("Client pods use the host network, enforcing the privileged pod security profile")
+		for _, mode := range []string{"enforce", "audit", "warn"} {
+			benchmarkNs.Labels["pod-security.kubernetes.io/"+mode] = "privileged"
+		}
 	}
-	_, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Create(context.TODO(), &server, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return err
+	for k, v := range r.nsLabels {
+		benchmarkNs.Labels[k] = v
 	}
-	_, err = clientSet.RbacV1().ClusterRoleBindings().Create(context.TODO(), &clientCRB, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
+	if len(r.nsAnnotations) > 0 {
+		benchmarkNs.Annotations = r.nsAnnotations
+	}
+	if err := fetchPullSecret(); err != nil {
 		return err
 	}
-	_, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Create(context.TODO(), &client, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
+	// With a client cluster, the benchmark namespace exists in both clusters
+	for _, c := range clusters() {
+		_, err := c.CoreV1().Namespaces().Create(context.TODO(), &benchmarkNs, metav1.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		if err := copyPullSecret(c, benchmarkNs.Name); err != nil {
+			return err
+		}
+	}
+	var err error
+	switch {
+	case len(routeRefs) > 0:
+		if err := r.setupRoutes(); err != nil {
+			return err
+		}
+	case backendRef != "":
+		if err := r.setupBackend(); err != nil {
+			return err
+		}
+	default:
+		if err := createPayloads(benchmarkNs.Name); err != nil {
+			return err
+		}
+		_, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Create(context.TODO(), &server, metav1.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		_, err = clientSet.CoreV1().Services(benchmarkNs.Name).Create(context.TODO(), &service, metav1.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	if err := r.deployRBAC(); err != nil {
 		return err
 	}
-	_, err = clientSet.CoreV1().Services(benchmarkNs.Name).Create(context.TODO(), &service, metav1.CreateOptions{})
+	_, err = clientCluster.AppsV1().Deployments(benchmarkNs.Name).Create(context.TODO(), &client, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
+	// Only client pods are needed to target existing routes
+	if len(routeRefs) > 0 {
+		return nil
+	}
 	for _, route := range routes {
 		if r.serviceMesh {
 			route.Spec.To = v1.RouteTargetReference{
@@ -290,34 +933,80 @@ func (r *Runner) deployAssets() error {
 }
 
 func reconcileNs(cfg config.Config) error {
-	f := func(deployment appsv1.Deployment, replicas int32) error {
-		d, err := clientSet.AppsV1().Deployments(benchmarkNs.Name).Get(context.TODO(), deployment.Name, metav1.GetOptions{})
+	f := func(c *kubernetes.Clientset, deployment appsv1.Deployment, replicas int32) error {
+		d, err := c.AppsV1().Deployments(benchmarkNs.Name).Get(context.TODO(), deployment.Name, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
-		if d.Status.ReadyReplicas == replicas {
+		if d.Status.ReadyReplicas == replicas && equality.Semantic.DeepDerivative(deployment.Spec.Template, d.Spec.Template) {
 			return nil
 		}
 		deployment.Spec.Replicas = &replicas
-		_, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Update(context.TODO(), &deployment, metav1.UpdateOptions{})
+		_, err = c.AppsV1().Deployments(benchmarkNs.Name).Update(context.TODO(), &deployment, metav1.UpdateOptions{})
 		if err != nil {
 			return err
 		}
-		return waitForDeployment(benchmarkNs.Name, deployment.Name, time.Minute)
+		return waitForClusterDeployment(c, benchmarkNs.Name, deployment.Name, deploymentTimeout)
 	}
-	if err := f(server, cfg.ServerReplicas); err != nil {
-		return err
+	// The replicas of an existing backend are managed by the user
+	if bundledServer() {
+		if err := f(clientSet, server, cfg.ServerReplicas); err != nil {
+			return err
+		}
+	}
+	// With external clients, a client pod is still used to verify the routes and measure the route propagation
+	if len(externalClients) > 0 {
+		return f(clientCluster, clientDeployment(cfg), 1)
+	}
+	return f(clientCluster, clientDeployment(cfg), cfg.Concurrency)
+}
+
+// clientDeployment returns the client deployment customized for the given scenario
+func clientDeployment(cfg config.Config) appsv1.Deployment {
+	c := *client.DeepCopy()
+	if cfg.ClientSpread {
+		// Spread the client pods evenly across the eligible nodes, nodes never run more than one client pod above
+		// the rest, so each pod gets its own node as long as the concurrency doesn't exceed the nodes
+		c.Spec.Template.Spec.TopologySpreadConstraints[0].WhenUnsatisfiable = corev1.DoNotSchedule
+	}
+	if clientZoneRequirement != nil {
+		terms := c.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		for i := range terms {
+			terms[i].MatchExpressions = append(terms[i].MatchExpressions, *clientZoneRequirement)
+		}
+	}
+	return c
+}
+
+// deploymentTimeout is the time to wait for the replicas of the benchmark deployments to be ready, and
+// cleanupTimeout the time to wait for the benchmark namespaces to be deleted
+var deploymentTimeout, cleanupTimeout = time.Minute, 10 * time.Minute
+
+// WithTimeouts sets the timeouts of the benchmark deployments readiness and of the cleanup of the benchmark namespaces
+func WithTimeouts(deployment, cleanup time.Duration) OptsFunctions {
+	return func(r *Runner) {
+		deploymentTimeout, cleanupTimeout = deployment, cleanup
 	}
-	return f(client, cfg.Concurrency)
 }
 
 func waitForDeployment(ns, deployment string, maxWaitTimeout time.Duration) error {
+	return waitForClusterDeployment(clientSet, ns, deployment, maxWaitTimeout)
+}
+
+// waitForClusterDeployment waits for the replicas of a deployment of the given cluster to be ready
+func waitForClusterDeployment(c *kubernetes.Clientset, ns, deployment string, maxWaitTimeout time.Duration) error {
+	return waitForDeploymentContext(context.TODO(), c, ns, deployment, maxWaitTimeout)
+}
+
+// waitForDeploymentContext waits for the replicas of a deployment of the given cluster to be ready, until the
+// timeout expires or the context is canceled
+func waitForDeploymentContext(ctx context.Context, c *kubernetes.Clientset, ns, deployment string, maxWaitTimeout time.Duration) error {
 	var errMsg string
 	var dep *appsv1.Deployment
 	var err error
 	log.Infof("Waiting for replicas from deployment %s in ns %s to be ready", deployment, ns)
-	err = wait.PollUntilContextTimeout(context.TODO(), time.Second, maxWaitTimeout, true, func(ctx context.Context) (bool, error) {
-		dep, err = clientSet.AppsV1().Deployments(ns).Get(context.TODO(), deployment, metav1.GetOptions{})
+	err = wait.PollUntilContextTimeout(ctx, time.Second, maxWaitTimeout, true, func(ctx context.Context) (bool, error) {
+		dep, err = c.AppsV1().Deployments(ns).Get(ctx, deployment, metav1.GetOptions{})
 		if err != nil {
 			return false, err
 		}
@@ -331,7 +1020,7 @@ func waitForDeployment(ns, deployment string, maxWaitTimeout time.Duration) erro
 	})
 	if err != nil && errMsg != "" {
 		log.Error(errMsg)
-		failedPods, _ := clientSet.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{
+		failedPods, _ := c.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{
 			FieldSelector: "status.phase=Pending",
 			LabelSelector: labels.SelectorFromSet(dep.Spec.Selector.MatchLabels).String(),
 		})
@@ -0,0 +1,202 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// bundleManifest file of the bundle holding the sha256 checksums of its files
+	bundleManifest = "manifest.sha256"
+	// bundleSignature file of the bundle holding the base64 signature of the manifest
+	bundleSignature = "manifest.sha256.sig"
+)
+
+// WithSigningKey signs the run bundle with the PEM encoded PKCS8 private key (ed25519, RSA or ECDSA) in the given file
+func WithSigningKey(file string) OptsFunctions {
+	return func(r *Runner) {
+		if file == "" {
+			return
+		}
+		key, err := loadSigningKey(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.signingKey = key
+	}
+}
+
+// loadSigningKey reads a PEM encoded PKCS8 private key
+func loadSigningKey(file string) (crypto.Signer, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key %s: %w", file, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	return signer, nil
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+	return block, nil
+}
+
+// sign adds the checksum manifest of the files written and its signature to the bundle
+func (bw *bundleWriter) sign(dir string, key crypto.Signer) error {
+	manifest := bytes.Clone(bw.manifest.Bytes())
+	message, opts := signedMessage(key.Public(), manifest)
+	signature, err := key.Sign(rand.Reader, message, opts)
+	if err != nil {
+		return fmt.Errorf("error signing the bundle: %w", err)
+	}
+	if err := bw.write(path.Join(dir, bundleManifest), manifest); err != nil {
+		return err
+	}
+	return bw.write(path.Join(dir, bundleSignature), []byte(base64.StdEncoding.EncodeToString(signature)+"\n"))
+}
+
+// signedMessage returns the message to sign for the given key: ed25519 signs the manifest itself, RSA and ECDSA its digest
+func signedMessage(key crypto.PublicKey, manifest []byte) ([]byte, crypto.SignerOpts) {
+	if _, ok := key.(ed25519.PublicKey); ok {
+		return manifest, crypto.Hash(0)
+	}
+	digest := sha256.Sum256(manifest)
+	return digest[:], crypto.SHA256
+}
+
+// VerifyBundle checks that the files of a signed run bundle match its checksum manifest, with no file missing or
+// added, and that the manifest signature is valid for the PEM encoded PKIX public key in the given file
+func VerifyBundle(bundle, publicKeyFile string) error {
+	block, err := readPEM(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing public key %s: %w", publicKeyFile, err)
+	}
+	f, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	var manifest, signature []byte
+	checksums := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading bundle %s: %w", bundle, err)
+		}
+		switch path.Base(header.Name) {
+		case bundleManifest:
+			if manifest, err = io.ReadAll(tr); err != nil {
+				return err
+			}
+		case bundleSignature:
+			if signature, err = io.ReadAll(tr); err != nil {
+				return err
+			}
+		default:
+			h := sha256.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return err
+			}
+			checksums[header.Name] = fmt.Sprintf("%x", h.Sum(nil))
+		}
+	}
+	if manifest == nil || signature == nil {
+		return fmt.Errorf("bundle %s is not signed", bundle)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %w", err)
+	}
+	message, _ := signedMessage(key, manifest)
+	valid := false
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, message, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, message, sig) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, message, sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature of bundle %s", bundle)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		checksum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return fmt.Errorf("malformed manifest line: %s", scanner.Text())
+		}
+		actual, found := checksums[name]
+		if !found {
+			return fmt.Errorf("file %s missing from bundle %s", name, bundle)
+		}
+		if actual != checksum {
+			return fmt.Errorf("checksum mismatch for %s in bundle %s", name, bundle)
+		}
+		delete(checksums, name)
+	}
+	for name := range checksums {
+		return fmt.Errorf("file %s of bundle %s not listed in its manifest", name, bundle)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// writeKeys writes the PKCS8 private key and the PKIX public key of the given signer as PEM files
+func writeKeys(t *testing.T, key crypto.Signer) (string, string) {
+	t.Helper()
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	privateFile, publicFile := path.Join(dir, "key.pem"), path.Join(dir, "key.pub")
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privateFile, publicFile
+}
+
+// writeBundle writes a bundle with a results file, calling before and after around its signature. A nil key
+// leaves the bundle unsigned
+func writeBundle(t *testing.T, key crypto.Signer, before, after func(bw *bundleWriter) error) string {
+	t.Helper()
+	file := path.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	bw := &bundleWriter{tw: tar.NewWriter(zw)}
+	if err := bw.write("run/results.json", []byte(`[{"uuid": "run"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if before != nil {
+		if err := before(bw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if key != nil {
+		if err := bw.sign("run", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after != nil {
+		if err := after(bw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bw.tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestVerifyBundle(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []crypto.Signer{ed25519Key, ecdsaKey, rsaKey} {
+		t.Run(fmt.Sprintf("%T", key), func(t *testing.T) {
+			privateFile, publicFile := writeKeys(t, key)
+			signer, err := loadSigningKey(privateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyBundle(writeBundle(t, signer, nil, nil), publicFile); err != nil {
+				t.Errorf("unexpected error verifying a valid bundle: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyBundleErrors(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, publicFile := writeKeys(t, key)
+	_, otherPublicFile := writeKeys(t, otherKey)
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		before    func(bw *bundleWriter) error
+		after     func(bw *bundleWriter) error
+		publicKey string
+		wantErr   string
+	}{
+		{
+			name:      "unsigned",
+			publicKey: publicFile,
+			wantErr:   "is not signed",
+		},
+		{
+			name:      "wrong key",
+			key:       key,
+			publicKey: otherPublicFile,
+			wantErr:   "invalid signature",
+		},
+		{
+			name: "tampered file",
+			key:  key,
+			// The last entry of a duplicated name is the one extracted
+			after: func(bw *bundleWriter) error {
+				return bw.write("run/results.json", []byte(`[{"uuid": "other"}]`))
+			},
+			publicKey: publicFile,
+			wantErr:   "checksum mismatch for run/results.json",
+		},
+		{
+			name: "missing file",
+			key:  key,
+			before: func(bw *bundleWriter) error {
+				_, err := fmt.Fprintf(&bw.manifest, "%x  run/metadata.json\n", make([]byte, 32))
+				return err
+			},
+			publicKey: publicFile,
+			wantErr:   "file run/metadata.json missing",
+		},
+		{
+			name: "added file",
+			key:  key,
+			after: func(bw *bundleWriter) error {
+				return bw.write("run/extra.json", []byte("{}"))
+			},
+			publicKey: publicFile,
+			wantErr:   "file run/extra.json of bundle",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyBundle(writeBundle(t, tc.key, tc.before, tc.after), tc.publicKey)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
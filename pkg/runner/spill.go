@@ -0,0 +1,141 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/indexers"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/elastic"
+	log "github.com/sirupsen/logrus"
+)
+
+// pendingDir is the directory, within the results directory, holding the documents that couldn't be indexed
+const pendingDir = "pending"
+
+// indexing returns true when the result documents have to be collected, either to index or to spill them
+func (r *Runner) indexing() bool {
+	return r.indexer != nil || r.spillDir != ""
+}
+
+// documentIndexer indexes the result documents, either a go-commons indexer or a data stream
+type documentIndexer interface {
+	Index([]interface{}, indexers.IndexingOpts) (string, error)
+}
+
+// newIndexer creates the indexer of the configured index or data stream
+func (r *Runner) newIndexer() (documentIndexer, error) {
+	if r.dataStream {
+		ds, err := elastic.NewDataStream(r.indexerCfg.Servers[0], r.indexerCfg.Index)
+		if err != nil {
+			return nil, err
+		}
+		return ds, nil
+	}
+	// Results are indexed with stable IDs, so re-indexing them doesn't create duplicates
+	if r.indexerCfg.Type == indexers.ElasticIndexer {
+		idx, err := elastic.NewIndex(r.indexerCfg.Servers[0], r.indexerCfg.Index)
+		if err != nil {
+			return nil, err
+		}
+		return idx, nil
+	}
+	indexer, err := indexers.NewIndexer(r.indexerCfg)
+	if err != nil {
+		return nil, err
+	}
+	return *indexer, nil
+}
+
+// index indexes the given documents, spilling them to the pending directory when indexing fails
+func (r *Runner) index(documents []interface{}) {
+	if len(documents) == 0 {
+		return
+	}
+	if r.indexer != nil {
+		err := indexDocuments(r.indexer, documents, indexers.IndexingOpts{})
+		if err == nil {
+			return
+		}
+		log.Errorf("Indexing error: %v", err)
+	}
+	if r.spillDir == "" {
+		return
+	}
+	if err := r.spill(documents); err != nil {
+		log.Errorf("Error spilling documents: %v", err)
+		return
+	}
+	log.Warnf("%d documents stored in %s, indexing will be retried at the end of the run", len(documents), r.spillDir)
+}
+
+// spill stores the documents in a new file of the pending directory
+func (r *Runner) spill(documents []interface{}) error {
+	if err := os.MkdirAll(r.spillDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(documents)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(r.spillDir, fmt.Sprintf("%s-%d.json", r.uuid, time.Now().UnixNano())), data, 0644)
+}
+
+// flushPending retries indexing the pending documents, including the ones spilled by previous runs.
+// Files are removed once their documents are indexed, documents have stable IDs, so the ones partially
+// indexed by a failed attempt aren't duplicated
+func (r *Runner) flushPending() {
+	files, err := filepath.Glob(path.Join(r.spillDir, "*.json"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	if r.indexer == nil {
+		indexer, err := r.newIndexer()
+		if err != nil {
+			log.Errorf("Indexer still unavailable, %d pending files kept in %s: %v", len(files), r.spillDir, err)
+			return
+		}
+		r.indexer = indexer
+	}
+	log.Infof("Indexing %d pending files from %s", len(files), r.spillDir)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Errorf("Error reading %s: %v", file, err)
+			continue
+		}
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			log.Errorf("Error decoding %s: %v", file, err)
+			continue
+		}
+		documents := make([]interface{}, len(raw))
+		for i, doc := range raw {
+			documents[i] = doc
+		}
+		if err := indexDocuments(r.indexer, documents, indexers.IndexingOpts{}); err != nil {
+			log.Errorf("Indexing error, %s kept for the next run: %v", file, err)
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			log.Errorf("Error removing %s: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/version"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// compareTimeouts compares the error rates of the tests of a sweep group, logging a table along with the lowest
+// route timeout without errors. Returns the sweep document
+func compareTimeouts(uuid string, group int, tests []tools.TestSummary) tools.TimeoutSweep {
+	sweep := tools.TimeoutSweep{
+		SchemaVersion: tools.SchemaVersion,
+		UUID:          uuid,
+		Timestamp:     time.Now().UTC(),
+		SweepGroup:    group,
+		Version:       fmt.Sprintf("%v@%v", version.Version, version.GitCommit),
+	}
+	var recommended time.Duration
+	for _, t := range tests {
+		if t.Config.SweepGroup != group {
+			continue
+		}
+		sweep.Termination = t.Config.Termination
+		res := tools.TimeoutResult{
+			RouteTimeout: t.Config.RouteTimeout.String(),
+			TestID:       tools.TestID(uuid, t.Test),
+			Passed:       t.Passed,
+			AvgRps:       t.AvgRps,
+			AvgLatency:   t.AvgLatency,
+			P99Latency:   t.P99Latency,
+			Requests:     t.Requests,
+			HTTPErrors:   t.HTTPErrors,
+			Timeouts:     t.Timeouts,
+		}
+		if t.Requests > 0 {
+			res.ErrorRatio = float64(t.HTTPErrors+t.Timeouts) / float64(t.Requests)
+		}
+		if t.Passed && res.HTTPErrors+res.Timeouts == 0 && (recommended == 0 || t.Config.RouteTimeout < recommended) {
+			recommended = t.Config.RouteTimeout
+		}
+		sweep.Sweep = append(sweep.Sweep, res)
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE TIMEOUT\tRPS\tAVG LAT (ms)\tP99 LAT (ms)\tHTTP ERRORS\tTIMEOUTS\tERROR RATIO")
+	for _, s := range sweep.Sweep {
+		fmt.Fprintf(w, "%s\t%.0f\t%.2f\t%.2f\t%d\t%d\t%.2f%%\n", s.RouteTimeout, s.AvgRps, s.AvgLatency/1e3, s.P99Latency/1e3,
+			s.HTTPErrors, s.Timeouts, s.ErrorRatio*100)
+	}
+	w.Flush()
+	log.Infof("Route timeout sweep %d (%s):\n%s", group, sweep.Termination, sb.String())
+	if recommended != 0 {
+		log.Infof("Lowest route timeout without errors: %v", recommended)
+	} else {
+		log.Warn("All the route timeouts of the sweep had errors")
+	}
+	return sweep
+}
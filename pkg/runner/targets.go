@@ -0,0 +1,146 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// target is an endpoint hit by a client process
+type target struct {
+	url     string
+	host    string
+	tenant  string
+	address string
+	headers []string
+}
+
+// endpoint returns the URL used by the client tools to reach the given host
+func endpoint(cfg config.Config, host string) string {
+	if cfg.Termination == "http" {
+		return fmt.Sprintf("http://%v%v", host, cfg.Path)
+	}
+	return fmt.Sprintf("https://%v%v", host, cfg.Path)
+}
+
+// buildTargets returns the list of targets the client processes are distributed across,
+// procs is the total number of client processes
+func buildTargets(cfg config.Config, host string, pod corev1.Pod, procs int) ([]target, error) {
+	var targets []target
+	tenants, err := tenantHosts(cfg)
+	if err != nil {
+		return targets, err
+	}
+	sni, err := sniHosts(cfg)
+	if err != nil {
+		return targets, err
+	}
+	switch {
+	case len(tenants) > 0:
+		for ns, tenantHost := range tenants {
+			targets = append(targets, target{host: tenantHost, tenant: ns})
+		}
+		sort.Slice(targets, func(i, j int) bool { return targets[i].tenant < targets[j].tenant })
+	case len(sni) > 0:
+		for _, sniHost := range sni {
+			targets = append(targets, target{host: sniHost})
+		}
+	default:
+		targets = append(targets, target{host: host})
+	}
+	for i := range targets {
+		targets[i].url = endpoint(cfg, targets[i].host)
+	}
+	if envoyIngress(cfg) {
+		if err := ingressTargets(cfg, targets); err != nil {
+			return targets, err
+		}
+	}
+	if cfg.RouterAddress != "" {
+		if err := directTargets(cfg, targets); err != nil {
+			return targets, err
+		}
+	}
+	if cfg.AddressFamily != "" {
+		for i := range targets {
+			address, err := resolveHost(targets[i].host, cfg.AddressFamily, pod)
+			if err != nil {
+				return targets, err
+			}
+			// Connect to the resolved address and keep the route host in the Host header
+			targets[i].address = address
+			targets[i].url = endpoint(cfg, net.JoinHostPort(address, port(cfg)))
+			targets[i].headers = append(targets[i].headers, fmt.Sprintf("Host: %s", targets[i].host))
+		}
+		log.Infof("Targeting routes over %s: %s", cfg.AddressFamily, targets[0].address)
+	}
+	if err := verifyTargets(cfg, targets, pod); err != nil {
+		return targets, err
+	}
+	if cfg.StickySessions {
+		cookies, err := fetchCookies(cfg, targets[0], procs, pod)
+		if err != nil {
+			return targets, err
+		}
+		// Every client process gets its own session
+		sessions := make([]target, len(cookies))
+		for i, cookie := range cookies {
+			sessions[i] = targets[0]
+			sessions[i].headers = append(sessions[i].headers, fmt.Sprintf("Cookie: %s=%s", stickyCookie, cookie))
+		}
+		targets = sessions
+	}
+	return targets, nil
+}
+
+func port(cfg config.Config) string {
+	if cfg.Termination == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+// resolveHost resolves the given host from the client pod, returning the first address of the given family
+func resolveHost(host, family string, pod corev1.Pod) (string, error) {
+	db := "ahostsv4"
+	if family == "ipv6" {
+		db = "ahostsv6"
+	}
+	stdout, stderr, err := podExec(context.TODO(), pod, clientName, []string{"getent", db, host})
+	if err != nil {
+		return "", fmt.Errorf("couldn't resolve %s address of %s: %v %s", family, host, err, stderr)
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		// ahostsv6 returns IPv4-mapped addresses when the host has no AAAA records
+		if ip == nil || (family == "ipv6") == (ip.To4() != nil) {
+			continue
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("host %s has no %s addresses", host, family)
+}
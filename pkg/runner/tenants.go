@@ -0,0 +1,173 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	tenantLabel = "app=ingress-perf-tenant"
+	// Max number of tenants being reconciled in parallel
+	tenantWorkers = 10
+)
+
+func tenantNs(i int) string {
+	return fmt.Sprintf("%s-tenant-%d", benchmarkNs.Name, i)
+}
+
+// reconcileTenants makes sure that count tenant namespaces exist, each of them holding
+// a server deployment with one replica, its service and the benchmark routes
+func reconcileTenants(count int) error {
+	existing := make(map[string]bool)
+	nsList, err := clientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: tenantLabel})
+	if err != nil {
+		return err
+	}
+	for _, ns := range nsList.Items {
+		if ns.DeletionTimestamp == nil {
+			existing[ns.Name] = true
+		}
+	}
+	if len(existing) == count {
+		return nil
+	}
+	log.Infof("Reconciling tenant namespaces: %d → %d", len(existing), count)
+	errGroup := errgroup.Group{}
+	errGroup.SetLimit(tenantWorkers)
+	for i := 0; i < count; i++ {
+		ns := tenantNs(i)
+		if existing[ns] {
+			delete(existing, ns)
+			continue
+		}
+		errGroup.Go(func() error {
+			return deployTenant(ns)
+		})
+	}
+	for ns := range existing {
+		ns := ns
+		errGroup.Go(func() error {
+			err := clientSet.CoreV1().Namespaces().Delete(context.TODO(), ns, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+	}
+	return errGroup.Wait()
+}
+
+func deployTenant(ns string) error {
+	tenantNamespace := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ns,
+			Labels:      map[string]string{"app": "ingress-perf-tenant"},
+			Annotations: benchmarkNs.Annotations,
+		},
+	}
+	for k, v := range benchmarkNs.Labels {
+		tenantNamespace.Labels[k] = v
+	}
+	_, err := clientSet.CoreV1().Namespaces().Create(context.TODO(), &tenantNamespace, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	if err := copyPullSecret(clientSet, ns); err != nil {
+		return err
+	}
+	if err := createPayloads(ns); err != nil {
+		return err
+	}
+	tenantServer := *server.DeepCopy()
+	tenantServer.Spec.Replicas = ptr.To[int32](1)
+	_, err = clientSet.AppsV1().Deployments(ns).Create(context.TODO(), &tenantServer, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	_, err = clientSet.CoreV1().Services(ns).Create(context.TODO(), service.DeepCopy(), metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	for _, route := range routes {
+		_, err := orClientSet.RouteV1().Routes(ns).Create(context.TODO(), route.DeepCopy(), metav1.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return waitForDeployment(ns, tenantServer.Name, deploymentTimeout)
+}
+
+// tenantHosts returns the route hosts of the tenants receiving traffic, indexed by namespace
+func tenantHosts(cfg config.Config) (map[string]string, error) {
+	hosts := make(map[string]string)
+	sample := cfg.TenantSample
+	if sample == 0 || sample > cfg.Tenants {
+		sample = cfg.Tenants
+	}
+	for i := 0; i < sample; i++ {
+		ns := tenantNs(i)
+		r, err := orClientSet.RouteV1().Routes(ns).Get(context.TODO(), fmt.Sprintf("%s-%s", serverName, cfg.Termination), metav1.GetOptions{})
+		if err != nil {
+			return hosts, err
+		}
+		hosts[ns] = r.Spec.Host
+	}
+	return hosts, nil
+}
+
+// rollupTenants aggregates the pod results by tenant namespace
+func rollupTenants(result *tools.Result) {
+	tenants := make(map[string]*tools.TenantResult)
+	for _, pod := range result.Pods {
+		if pod.Tenant == "" {
+			continue
+		}
+		t, ok := tenants[pod.Tenant]
+		if !ok {
+			t = &tools.TenantResult{Namespace: pod.Tenant}
+			tenants[pod.Tenant] = t
+		}
+		t.Clients++
+		t.TotalAvgRps += pod.AvgRps
+		t.AvgLatency += pod.AvgLatency
+		t.P95Latency += pod.P95Latency
+		t.P99Latency += pod.P99Latency
+		t.Requests += pod.Requests
+		t.HTTPErrors += pod.HTTPErrors
+		t.Timeouts += pod.Timeouts
+	}
+	result.Tenants = nil
+	for _, t := range tenants {
+		t.AvgLatency /= float64(t.Clients)
+		t.P95Latency /= float64(t.Clients)
+		t.P99Latency /= float64(t.Clients)
+		result.Tenants = append(result.Tenants, *t)
+	}
+	sort.Slice(result.Tenants, func(i, j int) bool {
+		return result.Tenants[i].Namespace < result.Tenants[j].Namespace
+	})
+}
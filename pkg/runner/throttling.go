@@ -0,0 +1,148 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Client side rate limits of the API requests, the defaults suit route scale scenarios in dedicated clusters
+var apiQPS float32 = 200
+var apiBurst = 200
+
+// Adaptive throttling halves the rate on API server 429 responses, down to minAPIQPS, and doubles it back,
+// up to the configured one, after a throttleRecovery period without 429 responses
+const (
+	minAPIQPS        = 5
+	throttleRecovery = 30 * time.Second
+)
+
+// SetAPIRateLimits sets the client side QPS and burst of the requests sent to the API servers
+func SetAPIRateLimits(qps float32, burst int) {
+	apiQPS = qps
+	apiBurst = burst
+}
+
+// adaptiveRateLimiter is a token bucket rate limiter whose rate is lowered when the API server throttles
+// the requests and restored once it stops doing so. lastChange is the time of the last rate change and lastThrottle
+// the one of the last 429 response
+type adaptiveRateLimiter struct {
+	lock         sync.Mutex
+	host         string
+	maxQPS       float32
+	qps          float32
+	burst        int
+	limiter      flowcontrol.RateLimiter
+	lastChange   time.Time
+	lastThrottle time.Time
+}
+
+func newAdaptiveRateLimiter(host string, qps float32, burst int) *adaptiveRateLimiter {
+	return &adaptiveRateLimiter{
+		host:       host,
+		maxQPS:     qps,
+		qps:        qps,
+		burst:      burst,
+		limiter:    flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		lastChange: time.Now(),
+	}
+}
+
+// current returns the limiter in use, raising the rate first when the recovery period passed since the last change
+func (l *adaptiveRateLimiter) current() flowcontrol.RateLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.qps < l.maxQPS && time.Since(l.lastChange) > throttleRecovery && time.Since(l.lastThrottle) > throttleRecovery {
+		l.setQPS(l.qps * 2)
+		log.Debugf("No API throttling in %s, raising the API rate limit of %s to %.0f QPS", throttleRecovery, l.host, l.qps)
+	}
+	return l.limiter
+}
+
+// throttled halves the rate after a 429 response, at most once per second to absorb bursts of 429 responses
+func (l *adaptiveRateLimiter) throttled() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lastThrottle = time.Now()
+	if l.qps > minAPIQPS && time.Since(l.lastChange) > time.Second {
+		l.setQPS(l.qps / 2)
+		log.Warnf("API server %s is throttling requests, lowering the API rate limit to %.0f QPS", l.host, l.qps)
+	}
+}
+
+// setQPS replaces the token bucket, the burst is scaled down with the rate
+func (l *adaptiveRateLimiter) setQPS(qps float32) {
+	if qps > l.maxQPS {
+		qps = l.maxQPS
+	}
+	if qps < minAPIQPS {
+		qps = minAPIQPS
+	}
+	burst := int(float32(l.burst) * qps / l.maxQPS)
+	if burst < 1 {
+		burst = 1
+	}
+	l.limiter.Stop()
+	l.qps = qps
+	l.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	l.lastChange = time.Now()
+}
+
+func (l *adaptiveRateLimiter) TryAccept() bool {
+	return l.current().TryAccept()
+}
+
+func (l *adaptiveRateLimiter) Accept() {
+	l.current().Accept()
+}
+
+func (l *adaptiveRateLimiter) Wait(ctx context.Context) error {
+	return l.current().Wait(ctx)
+}
+
+func (l *adaptiveRateLimiter) Stop() {
+	l.current().Stop()
+}
+
+func (l *adaptiveRateLimiter) QPS() float32 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.qps
+}
+
+// wrap observes the responses of the API server, so 429 responses lower the rate of the limiter
+func (l *adaptiveRateLimiter) wrap(rt http.RoundTripper) http.RoundTripper {
+	return throttleObserver{rt: rt, limiter: l}
+}
+
+type throttleObserver struct {
+	rt      http.RoundTripper
+	limiter *adaptiveRateLimiter
+}
+
+// RoundTrip implements http.RoundTripper
+func (t throttleObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.limiter.throttled()
+	}
+	return resp, err
+}
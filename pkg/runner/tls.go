@@ -0,0 +1,65 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Number of requests used to measure the TLS handshake time
+const handshakeRequests = 20
+
+// measureHandshake runs handshakeRequests sequential requests from the given pod and returns the
+// average TLS handshake time in microseconds
+func measureHandshake(cfg config.Config, ep string, pod corev1.Pod) (float64, error) {
+	var total float64
+	var samples int
+	script := fmt.Sprintf(`for i in $(seq %d); do curl -o /dev/null %s -w '%%{time_connect} %%{time_appconnect}\n' %s; done`,
+		handshakeRequests, curlFlags(cfg), ep)
+	stdout, stderr, err := podExec(context.TODO(), pod, clientName, []string{"bash", "-c", script})
+	if err != nil {
+		return 0, fmt.Errorf("%v: %s", err, stderr)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		connect, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		appConnect, err := strconv.ParseFloat(fields[1], 64)
+		// time_appconnect is 0 when the handshake failed, i.e: unsupported cipher suite
+		if err != nil || appConnect == 0 {
+			continue
+		}
+		total += appConnect - connect
+		samples++
+	}
+	if samples == 0 {
+		return 0, fmt.Errorf("TLS handshake failed with version %q and cipher suites %q", cfg.TLSVersion, cfg.CipherSuites)
+	}
+	handshake := total / float64(samples) * 1e6
+	log.Infof("Average TLS handshake time: %.0fus", handshake)
+	return handshake, nil
+}
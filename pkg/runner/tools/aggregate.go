@@ -0,0 +1,86 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"math"
+	"sort"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+// Aggregate reduces the values of a metric across the samples of a scenario with the given method.
+// higherIsBetter selects the best value for the best-of-N method, i.e. RPS as opposed to latencies
+func Aggregate(values []float64, method string, higherIsBetter bool) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	switch method {
+	case config.AggregationMedian:
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	case config.AggregationTrimmedMean:
+		// Discard the lowest and highest values, when there are enough of them
+		if len(sorted) > 2 {
+			sorted = sorted[1 : len(sorted)-1]
+		}
+		return mean(sorted)
+	case config.AggregationBest:
+		if higherIsBetter {
+			return sorted[len(sorted)-1]
+		}
+		return sorted[0]
+	default:
+		return mean(sorted)
+	}
+}
+
+// AggregationMethod returns the effective aggregation method of a scenario
+func AggregationMethod(cfg config.Config) string {
+	if cfg.Aggregation == "" {
+		return config.AggregationMean
+	}
+	return cfg.Aggregation
+}
+
+// CoefficientOfVariation returns the standard deviation of the values relative to their mean
+func CoefficientOfVariation(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	if m == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum/float64(len(values))) / m
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
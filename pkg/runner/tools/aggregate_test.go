@@ -0,0 +1,77 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []float64
+		method         string
+		higherIsBetter bool
+		want           float64
+	}{
+		{"empty", nil, config.AggregationMean, true, 0},
+		{"mean", []float64{1, 2, 3, 10}, config.AggregationMean, true, 4},
+		{"default method is mean", []float64{1, 2, 3, 10}, "", true, 4},
+		{"median odd", []float64{5, 1, 3}, config.AggregationMedian, true, 3},
+		{"median even", []float64{4, 1, 3, 2}, config.AggregationMedian, true, 2.5},
+		{"trimmed mean drops extremes", []float64{100, 1, 2, 3, -50}, config.AggregationTrimmedMean, true, 2},
+		{"trimmed mean keeps two values", []float64{1, 3}, config.AggregationTrimmedMean, true, 2},
+		{"best higher", []float64{2, 9, 4}, config.AggregationBest, true, 9},
+		{"best lower", []float64{2, 9, 4}, config.AggregationBest, false, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Aggregate(tc.values, tc.method, tc.higherIsBetter); got != tc.want {
+				t.Errorf("Aggregate(%v, %q) = %v, want %v", tc.values, tc.method, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAggregateDoesNotModifyValues(t *testing.T) {
+	values := []float64{3, 1, 2}
+	Aggregate(values, config.AggregationMedian, true)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("values were modified: %v", values)
+	}
+}
+
+func TestCoefficientOfVariation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"single value", []float64{10}, 0},
+		{"zero mean", []float64{0, 0}, 0},
+		{"constant", []float64{5, 5, 5}, 0},
+		{"spread", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 0.4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CoefficientOfVariation(tc.values); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("CoefficientOfVariation(%v) = %v, want %v", tc.values, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"math/rand"
+	"sort"
+)
+
+const (
+	// ConfidenceLevel of the bootstrapped intervals
+	ConfidenceLevel    = 0.95
+	bootstrapResamples = 1000
+	// The resamples are seeded with a constant so the intervals of the same samples are reproducible
+	bootstrapSeed = 1
+)
+
+// Interval bounds of a confidence interval
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Overlaps returns true when both intervals share any value
+func (i Interval) Overlaps(o Interval) bool {
+	return i.Low <= o.High && o.Low <= i.High
+}
+
+// Confidence intervals of the aggregated measurements of a scenario
+type Confidence struct {
+	Level      float64  `json:"level"`
+	Rps        Interval `json:"rps"`
+	AvgLatency Interval `json:"avg_lat_us"`
+	P95Latency Interval `json:"p95_lat_us"`
+	P99Latency Interval `json:"p99_lat_us"`
+}
+
+// Bootstrap computes the confidence intervals of the aggregated RPS and latencies of the given samples,
+// resampling them with replacement and aggregating each resample with the given method. Returns nil with
+// less than 2 samples
+func Bootstrap(results []Result, method string) *Confidence {
+	if len(results) < 2 {
+		return nil
+	}
+	var rps, avgLatency, p95Latency, p99Latency []float64
+	for _, res := range results {
+		rps = append(rps, res.TotalAvgRps)
+		avgLatency = append(avgLatency, res.AvgLatency)
+		p95Latency = append(p95Latency, res.P95Latency)
+		p99Latency = append(p99Latency, res.P99Latency)
+	}
+	return &Confidence{
+		Level:      ConfidenceLevel,
+		Rps:        bootstrapInterval(rps, method, true),
+		AvgLatency: bootstrapInterval(avgLatency, method, false),
+		P95Latency: bootstrapInterval(p95Latency, method, false),
+		P99Latency: bootstrapInterval(p99Latency, method, false),
+	}
+}
+
+// bootstrapInterval returns the percentile interval of the aggregates of the resamples of values
+func bootstrapInterval(values []float64, method string, higherIsBetter bool) Interval {
+	rng := rand.New(rand.NewSource(bootstrapSeed))
+	aggregates := make([]float64, bootstrapResamples)
+	resample := make([]float64, len(values))
+	for i := range aggregates {
+		for j := range resample {
+			resample[j] = values[rng.Intn(len(values))]
+		}
+		aggregates[i] = Aggregate(resample, method, higherIsBetter)
+	}
+	sort.Float64s(aggregates)
+	tail := (1 - ConfidenceLevel) / 2
+	return Interval{
+		Low:  aggregates[int(tail*float64(bootstrapResamples))],
+		High: aggregates[int((1-tail)*float64(bootstrapResamples))-1],
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+func TestBootstrapNotEnoughSamples(t *testing.T) {
+	for _, results := range [][]Result{nil, {{TotalAvgRps: 100}}} {
+		if c := Bootstrap(results, config.AggregationMean); c != nil {
+			t.Errorf("Bootstrap of %d samples = %+v, want nil", len(results), c)
+		}
+	}
+}
+
+func TestBootstrapConstantSamples(t *testing.T) {
+	results := []Result{
+		{TotalAvgRps: 100, AvgLatency: 10, P95Latency: 20, P99Latency: 30},
+		{TotalAvgRps: 100, AvgLatency: 10, P95Latency: 20, P99Latency: 30},
+		{TotalAvgRps: 100, AvgLatency: 10, P95Latency: 20, P99Latency: 30},
+	}
+	want := &Confidence{
+		Level:      ConfidenceLevel,
+		Rps:        Interval{100, 100},
+		AvgLatency: Interval{10, 10},
+		P95Latency: Interval{20, 20},
+		P99Latency: Interval{30, 30},
+	}
+	if got := Bootstrap(results, config.AggregationMean); !reflect.DeepEqual(got, want) {
+		t.Errorf("Bootstrap = %+v, want %+v", got, want)
+	}
+}
+
+func TestBootstrapInterval(t *testing.T) {
+	results := []Result{
+		{TotalAvgRps: 90, AvgLatency: 12},
+		{TotalAvgRps: 100, AvgLatency: 10},
+		{TotalAvgRps: 105, AvgLatency: 9},
+		{TotalAvgRps: 95, AvgLatency: 11},
+		{TotalAvgRps: 110, AvgLatency: 8},
+	}
+	for _, method := range []string{config.AggregationMean, config.AggregationMedian, config.AggregationTrimmedMean, config.AggregationBest} {
+		t.Run(method, func(t *testing.T) {
+			c := Bootstrap(results, method)
+			// The aggregates of the resamples are bounded by the sample values
+			for name, i := range map[string]Interval{"rps": c.Rps, "avg_lat_us": c.AvgLatency} {
+				if i.Low > i.High {
+					t.Errorf("%s interval %+v is inverted", name, i)
+				}
+			}
+			if c.Rps.Low < 90 || c.Rps.High > 110 {
+				t.Errorf("rps interval %+v exceeds the sample values", c.Rps)
+			}
+			if c.AvgLatency.Low < 8 || c.AvgLatency.High > 12 {
+				t.Errorf("avg_lat_us interval %+v exceeds the sample values", c.AvgLatency)
+			}
+			// Resamples are seeded, so the same samples always produce the same intervals
+			if again := Bootstrap(results, method); !reflect.DeepEqual(c, again) {
+				t.Errorf("Bootstrap isn't reproducible: %+v != %+v", c, again)
+			}
+		})
+	}
+}
+
+func TestIntervalOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b Interval
+		want bool
+	}{
+		{Interval{1, 5}, Interval{4, 8}, true},
+		{Interval{4, 8}, Interval{1, 5}, true},
+		{Interval{1, 5}, Interval{5, 8}, true},
+		{Interval{1, 3}, Interval{2, 2}, true},
+		{Interval{1, 3}, Interval{4, 8}, false},
+		{Interval{4, 8}, Interval{1, 3}, false},
+	}
+	for _, tc := range tests {
+		if got := tc.a.Overlaps(tc.b); got != tc.want {
+			t.Errorf("%+v.Overlaps(%+v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
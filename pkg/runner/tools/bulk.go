@@ -0,0 +1,154 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+// curlTimeout is the curl exit code of transfers exceeding the maximum time
+const curlTimeout = 28
+
+// bulk downloads the scenario path back-to-back through each connection for the whole duration, measuring
+// the bytes per second of the TCP path rather than the request rate. Each transfer is reported in a line
+type bulk struct {
+	cmd      []string
+	duration float64
+	timeout  int
+}
+
+func init() {
+	toolMap["bulk"] = Bulk
+}
+
+func Bulk(cfg config.Config, ep string) Tool {
+	timeout := int(math.Ceil(cfg.RequestTimeout.Seconds()))
+	flags := "-sk -o /dev/null"
+	if cfg.HTTP2 {
+		flags += " --http2"
+	}
+	for _, h := range requestHeaders(cfg) {
+		flags += fmt.Sprintf(" -H '%s'", h)
+	}
+	for _, f := range CurlFlags(cfg) {
+		flags += " " + f
+	}
+	// Transfers in flight when the duration expires are cut, so they don't extend the sample
+	script := fmt.Sprintf(`end=$((SECONDS+%d))
+for c in $(seq %d); do
+  while [ $SECONDS -lt $end ]; do
+    left=$((end-SECONDS)); timeout=%d; [ $left -lt $timeout ] && timeout=$left
+    [ $timeout -lt 1 ] && break
+    out=$(curl %s --max-time $timeout -w '%%{size_download} %%{http_code} %%{time_total}' '%s'); echo "$out $? $timeout"
+  done &
+done
+wait`, int(cfg.Duration.Seconds()), cfg.Connections, timeout, flags, ep)
+	return &bulk{
+		cmd:      []string{"bash", "-c", script},
+		duration: cfg.Duration.Seconds(),
+		timeout:  timeout,
+	}
+}
+
+// CurlFlags returns the curl flags pinning the TLS version and cipher suites of the scenario, and sending the
+// PROXY protocol header
+func CurlFlags(cfg config.Config) []string {
+	var flags []string
+	if cfg.TLSVersion != "" {
+		flags = append(flags, fmt.Sprintf("--tlsv%s --tls-max %s", cfg.TLSVersion, cfg.TLSVersion))
+	}
+	if cfg.CipherSuites != "" {
+		if cfg.TLSVersion == "1.3" {
+			flags = append(flags, fmt.Sprintf("--tls13-ciphers %s", cfg.CipherSuites))
+		} else {
+			flags = append(flags, fmt.Sprintf("--ciphers %s", cfg.CipherSuites))
+		}
+	}
+	if cfg.ProxyProtocol {
+		flags = append(flags, "--haproxy-protocol")
+	}
+	return flags
+}
+
+func (b *bulk) Cmd() []string {
+	return b.cmd
+}
+
+// ParseResult aggregates the transfers, transfers cut by the end of the sample count their bytes but not as timeouts
+func (b *bulk) ParseResult(stdout, _ string) (PodResult, error) {
+	res := PodResult{StatusCodes: make(map[int]int64)}
+	var latencies []float64
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+		size, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return res, fmt.Errorf("invalid transfer %q: %w", line, err)
+		}
+		code, _ := strconv.Atoi(fields[1])
+		total, _ := strconv.ParseFloat(fields[2], 64)
+		exit, _ := strconv.Atoi(fields[3])
+		maxTime, _ := strconv.Atoi(fields[4])
+		res.Bytes += int64(size)
+		switch {
+		case exit == curlTimeout:
+			// Only transfers exceeding the request timeout are timeouts
+			if maxTime < b.timeout {
+				continue
+			}
+			res.Timeouts++
+		case exit != 0:
+			res.ReadErrors++
+		default:
+			res.Requests++
+			res.StatusCodes[code]++
+			if code >= 400 {
+				res.HTTPErrors++
+			}
+			latencies = append(latencies, total*1e6)
+		}
+	}
+	res.AvgRps = float64(res.Requests) / b.duration
+	res.AvgThgoughputBps = int64(float64(res.Bytes) / b.duration)
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		for _, l := range latencies {
+			res.AvgLatency += l
+		}
+		res.AvgLatency /= float64(len(latencies))
+		res.P90Latency = percentile(latencies, 90)
+		res.P95Latency = percentile(latencies, 95)
+		res.P99Latency = percentile(latencies, 99)
+		res.MaxLatency = latencies[len(latencies)-1]
+	}
+	return res, nil
+}
+
+// percentile returns the nearest-rank percentile from a sorted slice
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
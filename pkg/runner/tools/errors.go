@@ -0,0 +1,91 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// toolError is a known error pattern in the tool output, fatal errors mean the tool couldn't generate load
+type toolError struct {
+	pattern *regexp.Regexp
+	fatal   bool
+}
+
+var toolErrors = []toolError{
+	{regexp.MustCompile(`(?i)name or service not known|temporary failure in name resolution|no such host|could not resolve`), true},
+	{regexp.MustCompile(`(?i)unable to connect to`), true},
+	{regexp.MustCompile(`(?i)connection refused`), false},
+	{regexp.MustCompile(`(?i)connection reset by peer`), false},
+	{regexp.MustCompile(`(?i)too many open files`), false},
+	{regexp.MustCompile(`(?i)cannot assign requested address`), false},
+	{regexp.MustCompile(`(?i)certificate|tls: |ssl`), false},
+	{regexp.MustCompile(`Socket errors: connect [1-9]`), false},
+	{regexp.MustCompile(`^panic: |^PANIC: `), true},
+}
+
+// ScanErrors returns the lines of the tool output matching known error patterns, at most one line per pattern
+func ScanErrors(stdout, stderr string) []string {
+	var errs []string
+	seen := make(map[int]bool)
+	for _, line := range strings.Split(stdout+"\n"+stderr, "\n") {
+		line = strings.TrimSpace(line)
+		// Results are printed as JSON documents
+		if line == "" || strings.HasPrefix(line, "{") || strings.HasPrefix(line, "\"") {
+			continue
+		}
+		for i, te := range toolErrors {
+			if !seen[i] && te.pattern.MatchString(line) {
+				seen[i] = true
+				errs = append(errs, line)
+			}
+		}
+	}
+	return errs
+}
+
+// ErrorRatio returns the ratio of HTTP errors and timeouts to requests of the given results
+func ErrorRatio(results ...PodResult) float64 {
+	var requests, errors int64
+	for _, r := range results {
+		requests += r.Requests
+		errors += r.HTTPErrors + r.Timeouts
+	}
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// Malfunction returns an error when the tool clearly didn't work, either because it reported a fatal error
+// or because it didn't complete any request, so the pod result doesn't make it into the sample
+func Malfunction(result PodResult) error {
+	for _, e := range result.Errors {
+		for _, te := range toolErrors {
+			if te.fatal && te.pattern.MatchString(e) {
+				return fmt.Errorf("tool failed: %s", e)
+			}
+		}
+	}
+	if result.Requests == 0 {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("no requests completed: %s", strings.Join(result.Errors, "; "))
+		}
+		return fmt.Errorf("no requests completed")
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package tools
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
 )
@@ -30,3 +31,16 @@ func New(cfg config.Config, endpoint string) (Tool, error) {
 	}
 	return f(cfg, endpoint), nil
 }
+
+// requestHeaders returns the extra request headers defined by the scenario
+func requestHeaders(cfg config.Config) []string {
+	var headers []string
+	if cfg.AcceptEncoding != "" {
+		headers = append(headers, fmt.Sprintf("Accept-Encoding: %s", cfg.AcceptEncoding))
+	}
+	headers = append(headers, cfg.Headers...)
+	for i := 0; i < cfg.RequestHeaderCount; i++ {
+		headers = append(headers, fmt.Sprintf("X-Ingress-Perf-%d: %s", i, strings.Repeat("x", cfg.RequestHeaderSize)))
+	}
+	return headers
+}
@@ -43,6 +43,9 @@ func HLoader(cfg config.Config, ep string) Tool {
 		},
 		res: PodResult{},
 	}
+	for _, h := range requestHeaders(cfg) {
+		newHLoader.cmd = append(newHLoader.cmd, "-H", h)
+	}
 	return newHLoader
 }
 
@@ -0,0 +1,110 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+// idle opens the given number of keepalive connections, sends a request through each one and holds them idle for
+// the whole duration, then sends a last request through each connection to verify it survived the idle period.
+// Each connection reports the number of responses it got: 2 when it survived, 1 when the router closed it and 0
+// when it couldn't be established
+type idle struct {
+	cmd      []string
+	duration float64
+}
+
+func init() {
+	toolMap["idle"] = Idle
+}
+
+// idleGrace is the time given to the last request of each connection, in seconds
+const idleGrace = 5
+
+func Idle(cfg config.Config, ep string) Tool {
+	u, _ := url.Parse(ep)
+	host := u.Hostname()
+	var headers string
+	for _, h := range requestHeaders(cfg) {
+		// Direct targets carry the route host in a Host header
+		if name, value, ok := strings.Cut(h, ":"); ok && strings.EqualFold(name, "host") {
+			host = strings.TrimSpace(value)
+			continue
+		}
+		headers += h + `\r\n`
+	}
+	port := u.Port()
+	conn := fmt.Sprintf("exec 3<>/dev/tcp/%s/%s && { cat >&3 & cat <&3; }", u.Hostname(), defaultPort(port, "80"))
+	if u.Scheme == "https" {
+		conn = fmt.Sprintf("openssl s_client -quiet -connect %s -servername %s 2>/dev/null", net.JoinHostPort(u.Hostname(), defaultPort(port, "443")), host)
+	}
+	path := u.RequestURI()
+	req := fmt.Sprintf(`GET %s HTTP/1.1\r\nHost: %s\r\n%s\r\n`, path, host, headers)
+	last := fmt.Sprintf(`GET %s HTTP/1.1\r\nHost: %s\r\n%sConnection: close\r\n\r\n`, path, host, headers)
+	hold := int(cfg.Duration.Seconds())
+	script := fmt.Sprintf(`for c in $(seq %d); do
+  { { printf '%%b' %s; sleep %d; printf '%%b' %s; sleep %d; } | timeout %d bash -c %s | grep -c '^HTTP/1'; } &
+done
+wait`, cfg.Connections, shellQuote(req), hold, shellQuote(last), idleGrace, hold+2*idleGrace, shellQuote(conn))
+	return &idle{
+		cmd:      []string{"bash", "-c", script},
+		duration: cfg.Duration.Seconds(),
+	}
+}
+
+func defaultPort(port, def string) string {
+	if port == "" {
+		return def
+	}
+	return port
+}
+
+// shellQuote quotes the given string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (i *idle) Cmd() []string {
+	return i.cmd
+}
+
+func (i *idle) ParseResult(stdout, _ string) (PodResult, error) {
+	res := PodResult{StatusCodes: make(map[int]int64)}
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		responses, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			return res, fmt.Errorf("invalid connection result %q", line)
+		}
+		res.Requests += int64(responses)
+		switch responses {
+		case 0:
+			res.ReadErrors++
+		case 1:
+			res.OpenConnections++
+			res.DroppedConnections++
+		default:
+			res.OpenConnections++
+		}
+	}
+	res.AvgRps = float64(res.Requests) / i.duration
+	return res, nil
+}
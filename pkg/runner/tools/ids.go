@@ -0,0 +1,31 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import "fmt"
+
+// Identifiers are hierarchical: the run UUID, the test within the run and the sample within the test, i.e:
+// <uuid>-t2-s3 is the third sample of the second test. They're valid label values, so the same identifiers
+// stamped in the documents label the Kubernetes resources created for a test or sample
+
+// TestID returns the identifier of a test of the run, tests start from 1
+func TestID(uuid string, test int) string {
+	return fmt.Sprintf("%s-t%d", uuid, test)
+}
+
+// SampleID returns the identifier of a sample of a test, samples start from 1
+func SampleID(uuid string, test, sample int) string {
+	return fmt.Sprintf("%s-s%d", TestID(uuid, test), sample)
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+)
+
+// JobSummaryMetric metric name of the kube-burner job summary documents
+const JobSummaryMetric = "jobSummary"
+
+// KubeBurnerMetric follows the shape of the metric documents indexed by kube-burner
+type KubeBurnerMetric struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Value      float64           `json:"value"`
+	UUID       string            `json:"uuid"`
+	Query      string            `json:"query"`
+	MetricName string            `json:"metricName"`
+	JobName    string            `json:"jobName"`
+}
+
+// KubeBurnerJobSummary follows the shape of the job summary documents indexed by kube-burner, there's one per test
+type KubeBurnerJobSummary struct {
+	Timestamp    time.Time           `json:"timestamp"`
+	EndTimestamp time.Time           `json:"endTimestamp"`
+	UUID         string              `json:"uuid"`
+	MetricName   string              `json:"metricName"`
+	ElapsedTime  float64             `json:"elapsedTime"`
+	JobConfig    KubeBurnerJobConfig `json:"jobConfig"`
+	Version      string              `json:"version"`
+	Passed       bool                `json:"passed"`
+	ClusterMetadata
+}
+
+// KubeBurnerJobConfig holds the job name and type expected by kube-burner tooling plus the test configuration
+type KubeBurnerJobConfig struct {
+	Name    string `json:"name"`
+	JobType string `json:"jobType"`
+	config.Config
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion of the indexed documents. Adding optional fields doesn't require a new version, while renaming,
+// removing or changing the meaning of a field does, along with a migration from the previous version
+const SchemaVersion = 1
+
+// migrations upgrade a raw document from the version of the key to the next one. Fields were only added since
+// the documents indexed before schema versioning, so they're still version 1 and there's nothing to migrate yet
+var migrations = map[int]func(doc map[string]interface{}) error{}
+
+// Migrate upgrades a raw document to the current schema version, documents without
+// schemaVersion are considered version 1. Documents newer than the supported version are rejected
+func Migrate(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	version := 1
+	if v, ok := doc["schemaVersion"].(float64); ok {
+		version = int(v)
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("document schema version %d is newer than the supported one (%d), upgrade ingress-perf", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return data, nil
+	}
+	for ; version < SchemaVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration available from schema version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, fmt.Errorf("error migrating document from schema version %d: %w", version, err)
+		}
+	}
+	doc["schemaVersion"] = SchemaVersion
+	return json.Marshal(doc)
+}
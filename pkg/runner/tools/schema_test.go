@@ -0,0 +1,45 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	// Document indexed before schema versioning
+	legacy := []byte(`{"uuid":"uuid","sample":1,"total_avg_rps":1000,"config":{"tool":"wrk","termination":"edge"}}`)
+	data, err := Migrate(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.UUID != "uuid" || result.TotalAvgRps != 1000 || result.Config.Termination != "edge" {
+		t.Errorf("unexpected migrated document: %+v", result)
+	}
+	current := []byte(fmt.Sprintf(`{"schemaVersion":%d,"uuid":"uuid"}`, SchemaVersion))
+	if data, err := Migrate(current); err != nil || string(data) != string(current) {
+		t.Errorf("Migrate(current) = %s, %v, want the document unchanged", data, err)
+	}
+	newer := []byte(fmt.Sprintf(`{"schemaVersion":%d,"uuid":"uuid"}`, SchemaVersion+1))
+	if _, err := Migrate(newer); err == nil {
+		t.Error("expected an error migrating a document newer than the supported schema version")
+	}
+}
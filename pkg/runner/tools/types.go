@@ -24,7 +24,21 @@ import (
 // We need to embed ClusterMetadata in order to add extra fields to it
 type ClusterMetadata struct {
 	ocpmetadata.ClusterMetadata
-	HAProxyVersion string `json:"haproxyVersion,omitempty"`
+	HAProxyVersion             string   `json:"haproxyVersion,omitempty"`
+	EndpointPublishingStrategy string   `json:"endpointPublishingStrategy,omitempty"`
+	RouterProxyProtocol        bool     `json:"routerProxyProtocol"`
+	ClientImage                string   `json:"clientImage,omitempty"`
+	ServerImage                string   `json:"serverImage,omitempty"`
+	Architecture               string   `json:"architecture,omitempty"`
+	Backend                    string   `json:"backend,omitempty"`
+	Routes                     []string `json:"routes,omitempty"`
+	ExternalClients            []string `json:"externalClients,omitempty"`
+	ClientCluster              string   `json:"clientCluster,omitempty"`
+	// Reproducibility metadata: hash of the expanded configuration, digests of the images in use and run seed
+	ConfigHash         string   `json:"configHash"`
+	ClientImageDigests []string `json:"clientImageDigests,omitempty"`
+	ServerImageDigests []string `json:"serverImageDigests,omitempty"`
+	Seed               int64    `json:"seed"`
 }
 
 type Tool interface {
@@ -48,32 +62,269 @@ type PodResult struct {
 	ReadErrors       int64         `json:"read_errors"`
 	WriteErrors      int64         `json:"write_errors"`
 	Requests         int64         `json:"requests"`
+	Bytes            int64         `json:"bytes"`
 	Timeouts         int64         `json:"timeouts"`
 	AvgThgoughputBps int64         `json:"avg_throughput_bps"`
 	StatusCodes      map[int]int64 `json:"status_codes"`
+	Tenant           string        `json:"tenant,omitempty"`
+	Target           string        `json:"target,omitempty"`
+	// Partial is set when the pod failed before completing all the result intervals of the sample
+	Partial bool `json:"partial,omitempty"`
+	// Idle connections established and closed by the router during the idle period, only set by the idle tool
+	OpenConnections    int64 `json:"open_connections,omitempty"`
+	DroppedConnections int64 `json:"dropped_connections,omitempty"`
+	// Errors lines of the tool output matching known error patterns
+	Errors []string `json:"errors,omitempty"`
+}
+
+type TenantResult struct {
+	Namespace   string  `json:"namespace"`
+	Clients     int     `json:"clients"`
+	TotalAvgRps float64 `json:"total_avg_rps"`
+	AvgLatency  float64 `json:"avg_lat_us"`
+	P95Latency  float64 `json:"p95_lat_us"`
+	P99Latency  float64 `json:"p99_lat_us"`
+	Requests    int64   `json:"requests"`
+	HTTPErrors  int64   `json:"http_errors"`
+	Timeouts    int64   `json:"timeouts"`
+}
+
+// TargetResult aggregates the pods sending the requests of a request mix target
+type TargetResult struct {
+	Target      string  `json:"target"`
+	Clients     int     `json:"clients"`
+	TotalAvgRps float64 `json:"total_avg_rps"`
+	AvgLatency  float64 `json:"avg_lat_us"`
+	P95Latency  float64 `json:"p95_lat_us"`
+	P99Latency  float64 `json:"p99_lat_us"`
+	Requests    int64   `json:"requests"`
+	Bytes       int64   `json:"bytes"`
+	HTTPErrors  int64   `json:"http_errors"`
+	Timeouts    int64   `json:"timeouts"`
 }
 
 type Result struct {
-	UUID         string             `json:"uuid"`
-	Sample       int                `json:"sample"`
-	Config       config.Config      `json:"config"`
-	Pods         []PodResult        `json:"pods,omitempty"`
-	Timestamp    time.Time          `json:"timestamp"`
-	TotalAvgRps  float64            `json:"total_avg_rps"`
-	StdevRps     float64            `json:"rps_stdev"`
-	StdevLatency float64            `json:"stdev_lat"`
-	AvgLatency   float64            `json:"avg_lat_us"`
-	MaxLatency   float64            `json:"max_lat_us"`
-	P90Latency   float64            `json:"p90_lat_us"`
-	P95Latency   float64            `json:"p95_lat_us"`
-	P99Latency   float64            `json:"p99_lat_us"`
-	HTTPErrors   int64              `json:"http_errors"`
-	ReadErrors   int64              `json:"read_errors"`
-	WriteErrors  int64              `json:"write_errors"`
-	Requests     int64              `json:"requests"`
-	Timeouts     int64              `json:"timeouts"`
-	Version      string             `json:"version"`
-	InfraMetrics map[string]float64 `json:"infra_metrics"`
-	StatusCodes  map[int]int64      `json:"status_codes"`
+	SchemaVersion       int                `json:"schemaVersion"`
+	UUID                string             `json:"uuid"`
+	Test                int                `json:"test"`
+	TestID              string             `json:"testId"`
+	Sample              int                `json:"sample"`
+	SampleID            string             `json:"sampleId"`
+	Config              config.Config      `json:"config"`
+	Pods                []PodResult        `json:"pods,omitempty"`
+	Timestamp           time.Time          `json:"timestamp"`
+	TotalAvgRps         float64            `json:"total_avg_rps"`
+	StdevRps            float64            `json:"rps_stdev"`
+	StdevLatency        float64            `json:"stdev_lat"`
+	AvgLatency          float64            `json:"avg_lat_us"`
+	MaxLatency          float64            `json:"max_lat_us"`
+	P90Latency          float64            `json:"p90_lat_us"`
+	P95Latency          float64            `json:"p95_lat_us"`
+	P99Latency          float64            `json:"p99_lat_us"`
+	HTTPErrors          int64              `json:"http_errors"`
+	ReadErrors          int64              `json:"read_errors"`
+	WriteErrors         int64              `json:"write_errors"`
+	Requests            int64              `json:"requests"`
+	Bytes               int64              `json:"bytes"`
+	AvgBytesPerRequest  float64            `json:"avg_bytes_per_request"`
+	TotalThroughputBps  float64            `json:"total_throughput_bps,omitempty"`
+	OpenConnections     int64              `json:"open_connections,omitempty"`
+	DroppedConnections  int64              `json:"dropped_connections,omitempty"`
+	RouterMemoryPerConn float64            `json:"router_memory_per_connection_bytes,omitempty"`
+	RpsPerRouterCore    float64            `json:"rps_per_router_core,omitempty"`
+	RouterCPUPer1kReq   float64            `json:"router_cpu_ms_per_1k_requests,omitempty"`
+	JoulesPerMReq       float64            `json:"joules_per_million_requests,omitempty"`
+	RouterJoulesPerMReq float64            `json:"router_joules_per_million_requests,omitempty"`
+	Timeouts            int64              `json:"timeouts"`
+	Version             string             `json:"version"`
+	InfraMetrics        map[string]float64 `json:"infra_metrics"`
+	StatusCodes         map[int]int64      `json:"status_codes"`
+	Tenants             []TenantResult     `json:"tenants,omitempty"`
+	Targets             []TargetResult     `json:"targets,omitempty"`
+	Drain               *DrainResult       `json:"drain,omitempty"`
+	RouterStats         []RouterStat       `json:"router_stats,omitempty"`
+	RateLimit           *RateLimitResult   `json:"rate_limit,omitempty"`
+	TrafficSplit        *TrafficSplit      `json:"traffic_split,omitempty"`
+	Confidence          *Confidence        `json:"confidence,omitempty"`
+	ClientNodes         int                `json:"client_nodes,omitempty"`
+	AvgHandshakeLatency float64            `json:"avg_handshake_us,omitempty"`
+	CertificateKey      string             `json:"certificateKey,omitempty"`
+	IngressVersion      string             `json:"ingressVersion,omitempty"`
+	PartialPods         int                `json:"partial_pods,omitempty"`
+	ClockSkew           float64            `json:"max_clock_skew_ms"`
+	ToolErrors          []string           `json:"tool_errors,omitempty"`
+	ClientSaturated     bool               `json:"clientSaturated"`
+	Unstable            bool               `json:"unstable"`
+	ErrorCaptures       []string           `json:"error_captures,omitempty"`
+	RouterZones         []string           `json:"routerZones,omitempty"`
+	ClientZones         []string           `json:"clientZones,omitempty"`
 	ClusterMetadata
 }
+
+// RateLimitResult measures how accurately the HTTP rate limit of the routes is enforced per client pod
+type RateLimitResult struct {
+	// LimitRps requests per second allowed per source IP
+	LimitRps float64 `json:"limit_rps"`
+	// AcceptedRps average requests per second served per client pod
+	AcceptedRps float64 `json:"accepted_rps"`
+	// Accuracy ratio of the accepted requests per second to the limit, 1 when the limit is enforced exactly
+	Accuracy float64 `json:"accuracy"`
+	// Rejected requests and connections, i.e. reset or denied by the router
+	Rejected int64 `json:"rejected"`
+}
+
+// TrafficSplit compares the share of the traffic served by each backend of the routes with its weight
+type TrafficSplit struct {
+	Backends []BackendShare `json:"backends"`
+	// MaxDeviation largest absolute difference between the observed and the expected share of a backend
+	MaxDeviation float64 `json:"max_deviation"`
+}
+
+type BackendShare struct {
+	Service       string  `json:"service"`
+	Weight        int     `json:"weight"`
+	ExpectedShare float64 `json:"expected_share"`
+	ObservedShare float64 `json:"observed_share"`
+}
+
+type DrainResult struct {
+	Node         string    `json:"node"`
+	Timestamp    time.Time `json:"timestamp"`
+	EvictedPods  int       `json:"evicted_pods"`
+	RecoveryTime float64   `json:"recovery_time_s"`
+}
+
+// RouterStat is a sample of the HAProxy runtime socket of a router pod. Connections and rates come from show info,
+// sessions, queue and denials from the show stat counters of the benchmark backends and the router frontends
+type RouterStat struct {
+	Timestamp time.Time `json:"timestamp"`
+	Pod       string    `json:"pod"`
+	// CurrConns current and maximum allowed connections of the HAProxy process
+	CurrConns int64 `json:"curr_conns"`
+	MaxConn   int64 `json:"max_conn"`
+	// ConnRate and SessRate connections and sessions per second over the last second
+	ConnRate int64 `json:"conn_rate"`
+	SessRate int64 `json:"sess_rate"`
+	IdlePct  int64 `json:"idle_pct"`
+	// Sessions current sessions of the benchmark backends
+	Sessions int64 `json:"sessions"`
+	// Queue requests waiting for a free server connection in the benchmark backends, and its maximum
+	Queue    int64 `json:"queue"`
+	MaxQueue int64 `json:"max_queue"`
+	// Denied requests, connections and sessions since the router reload, i.e. by rate limits or ACLs
+	Denied int64 `json:"denied"`
+}
+
+type PropagationResult struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	UUID          string        `json:"uuid"`
+	Test          int           `json:"test"`
+	TestID        string        `json:"testId"`
+	Config        config.Config `json:"config"`
+	Timestamp     time.Time     `json:"timestamp"`
+	Latencies     []float64     `json:"latencies_ms"`
+	AvgLatency    float64       `json:"avg_propagation_ms"`
+	P50Latency    float64       `json:"p50_propagation_ms"`
+	P95Latency    float64       `json:"p95_propagation_ms"`
+	P99Latency    float64       `json:"p99_propagation_ms"`
+	MaxLatency    float64       `json:"max_propagation_ms"`
+	Failures      int           `json:"failures"`
+	Version       string        `json:"version"`
+	ClusterMetadata
+}
+
+// TimeoutSweep compares the error rates of the tests expanded from a timeoutSweep scenario
+type TimeoutSweep struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	UUID          string          `json:"uuid"`
+	Timestamp     time.Time       `json:"timestamp"`
+	SweepGroup    int             `json:"sweepGroup"`
+	Termination   string          `json:"termination"`
+	Sweep         []TimeoutResult `json:"sweep"`
+	Version       string          `json:"version"`
+}
+
+// TimeoutResult holds the aggregated results of a route timeout
+type TimeoutResult struct {
+	RouteTimeout string  `json:"routeTimeout"`
+	TestID       string  `json:"testId"`
+	Passed       bool    `json:"passed"`
+	AvgRps       float64 `json:"avg_rps"`
+	AvgLatency   float64 `json:"avg_lat_us"`
+	P99Latency   float64 `json:"p99_lat_us"`
+	Requests     int64   `json:"requests"`
+	HTTPErrors   int64   `json:"http_errors"`
+	Timeouts     int64   `json:"timeouts"`
+	ErrorRatio   float64 `json:"error_ratio"`
+}
+
+// ControllerComparison compares the tests expanded from an ingressControllers scenario against the first one
+type ControllerComparison struct {
+	SchemaVersion   int                `json:"schemaVersion"`
+	UUID            string             `json:"uuid"`
+	Timestamp       time.Time          `json:"timestamp"`
+	ComparisonGroup int                `json:"comparisonGroup"`
+	Baseline        string             `json:"baselineIngressController"`
+	Comparison      []ControllerResult `json:"comparison"`
+	Version         string             `json:"version"`
+}
+
+// ControllerResult holds the aggregated results of an IngressController and their relative difference,
+// in percentage, with the baseline ones
+type ControllerResult struct {
+	IngressController string  `json:"ingressController"`
+	TestID            string  `json:"testId"`
+	Passed            bool    `json:"passed"`
+	AvgRps            float64 `json:"avg_rps"`
+	AvgLatency        float64 `json:"avg_lat_us"`
+	P99Latency        float64 `json:"p99_lat_us"`
+	RpsDelta          float64 `json:"rps_delta_pct"`
+	AvgLatencyDelta   float64 `json:"avg_lat_delta_pct"`
+	P99LatencyDelta   float64 `json:"p99_lat_delta_pct"`
+}
+
+// Summary holds the per test aggregates of a run
+type Summary struct {
+	UUID   string        `json:"uuid"`
+	Passed bool          `json:"passed"`
+	Error  string        `json:"error,omitempty"`
+	Tests  []TestSummary `json:"tests"`
+}
+
+type TestSummary struct {
+	Test    int           `json:"test"`
+	Config  config.Config `json:"config"`
+	Passed  bool          `json:"passed"`
+	Samples int           `json:"samples"`
+	// Aggregation method of the samples
+	Aggregation string  `json:"aggregation"`
+	AvgRps      float64 `json:"avg_rps"`
+	AvgLatency  float64 `json:"avg_lat_us"`
+	P95Latency  float64 `json:"p95_lat_us"`
+	P99Latency  float64 `json:"p99_lat_us"`
+	Timeouts    int64   `json:"timeouts"`
+	HTTPErrors  int64   `json:"http_errors"`
+	Requests    int64   `json:"requests"`
+	// Aggregated CPU usage of the router pods, in cores
+	RouterCPU float64 `json:"avg_cpu_usage_router_pods"`
+	// Aggregated router efficiency: requests per second per router CPU core and CPU milliseconds per 1k requests
+	RpsPerRouterCore  float64 `json:"rps_per_router_core"`
+	RouterCPUPer1kReq float64 `json:"router_cpu_ms_per_1k_requests"`
+	// Bootstrapped confidence intervals of the aggregated RPS and latencies, with 2 or more samples
+	Confidence *Confidence `json:"confidence,omitempty"`
+	// Coefficients of variation of the RPS and P99 latency across the samples, the test is unstable when any
+	// of them exceeds the variance threshold of the scenario
+	RpsCV        float64 `json:"rps_cv"`
+	P99LatencyCV float64 `json:"p99_lat_cv"`
+	Unstable     bool    `json:"unstable"`
+}
+
+// Event is emitted as the run progresses: test and sample starts, phase changes,
+// progress updates, result documents and the run summary once it finishes
+type Event struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Test      int         `json:"test,omitempty"`
+	Sample    int         `json:"sample,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
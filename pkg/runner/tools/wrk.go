@@ -32,10 +32,31 @@ func init() {
 }
 
 func Wrk(cfg config.Config, ep string) Tool {
+	// recycle.lua extends json.lua, closing connections after the given number of requests,
+	// and pacing.lua extends recycle.lua with a think time between requests
+	script := "json.lua"
+	switch {
+	case cfg.ThinkTime > 0:
+		script = "pacing.lua"
+	case cfg.RequestsPerConnection > 0:
+		script = "recycle.lua"
+	}
 	newWrk := &wrk{
-		cmd: []string{"wrk", "-s", "json.lua", "-c", strconv.Itoa(cfg.Connections), "-d", fmt.Sprintf("%v", cfg.Duration.Seconds()), "--latency", ep, "--timeout", fmt.Sprintf("%v", cfg.RequestTimeout.Seconds())},
+		cmd: []string{"wrk", "-s", script, "-c", strconv.Itoa(cfg.Connections), "-d", fmt.Sprintf("%v", cfg.Duration.Seconds()), "--latency", ep, "--timeout", fmt.Sprintf("%v", cfg.RequestTimeout.Seconds())},
 		res: PodResult{},
 	}
+	for _, h := range requestHeaders(cfg) {
+		newWrk.cmd = append(newWrk.cmd, "-H", h)
+	}
+	// Script arguments must follow the rest of the options
+	if cfg.Method != "" || cfg.BodySize != 0 || script != "json.lua" {
+		method := cfg.Method
+		if method == "" {
+			method = "GET"
+		}
+		newWrk.cmd = append(newWrk.cmd, "--", method, strconv.Itoa(cfg.BodySize), strconv.Itoa(cfg.RequestsPerConnection),
+			fmt.Sprint(cfg.ThinkTime.Milliseconds()), fmt.Sprint(cfg.ThinkTimeJitter.Milliseconds()), fmt.Sprint(cfg.Seed))
+	}
 	return newWrk
 }
 
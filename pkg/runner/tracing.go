@@ -0,0 +1,280 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/go-commons/version"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// OTLP span status codes
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// span follows the OTLP/JSON span encoding
+type span struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []spanAttribute `json:"attributes,omitempty"`
+	Status       spanStatus      `json:"status"`
+}
+
+// spanAttribute OTLP key/value attribute
+type spanAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// spanStatus OTLP span status, the message is only set on errors
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// tracer records the spans of the run phases: the run span holds the deploy phase and one span per test,
+// each test holds its phases and samples, and each sample holds the benchmark and metrics phases.
+// Spans are exported in a single OTLP/HTTP request when the run finishes
+type tracer struct {
+	lock     sync.Mutex
+	endpoint string
+	traceID  string
+	run      *span
+	test     *span
+	sample   *span
+	phase    *span
+	spans    []span
+	client   *http.Client
+}
+
+// runTracer is nil when tracing is disabled
+var runTracer *tracer
+
+// newTracer returns a tracer exporting to the traces path of the given OTLP/HTTP endpoint
+func newTracer(endpoint string) *tracer {
+	return &tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		traceID:  randomID(16),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// randomID returns a random hex encoded ID of the given size in bytes, 16 for trace IDs and 8 for span IDs
+func randomID(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// nanos returns the given time in the OTLP/JSON encoding, nanoseconds since the epoch as a string
+func nanos(t time.Time) string {
+	return fmt.Sprint(t.UnixNano())
+}
+
+// attribute returns an OTLP attribute typed after the given value
+func attribute(key string, value interface{}) spanAttribute {
+	switch v := value.(type) {
+	case int:
+		return spanAttribute{Key: key, Value: map[string]interface{}{"intValue": fmt.Sprint(v)}}
+	case bool:
+		return spanAttribute{Key: key, Value: map[string]interface{}{"boolValue": v}}
+	default:
+		return spanAttribute{Key: key, Value: map[string]interface{}{"stringValue": fmt.Sprint(v)}}
+	}
+}
+
+// newSpan starts a span, the lock must be held
+func (t *tracer) newSpan(name string, parent *span, attrs ...spanAttribute) *span {
+	s := &span{
+		TraceID:    t.traceID,
+		SpanID:     randomID(8),
+		Name:       name,
+		Kind:       1, // SPAN_KIND_INTERNAL
+		Start:      nanos(time.Now()),
+		Attributes: attrs,
+		Status:     spanStatus{Code: statusOK},
+	}
+	if parent != nil {
+		s.ParentSpanID = parent.SpanID
+	}
+	return s
+}
+
+// endSpan ends the given span and records it, the lock must be held
+func (t *tracer) endSpan(s **span) {
+	if *s == nil {
+		return
+	}
+	(*s).End = nanos(time.Now())
+	t.spans = append(t.spans, **s)
+	*s = nil
+}
+
+// startRun starts the root span of the trace
+func (t *tracer) startRun(uuid string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.run = t.newSpan("run", nil, attribute("uuid", uuid), attribute("version", version.Version), attribute("tests", len(config.Cfg)))
+}
+
+// startTest ends the previous test and starts a new one, with the scenario configuration as attributes
+func (t *tracer) startTest(test int, cfg config.Config) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	t.endSpan(&t.sample)
+	t.endSpan(&t.test)
+	t.test = t.newSpan(fmt.Sprintf("test %d", test), t.run,
+		attribute("test", test),
+		attribute("testId", tools.TestID(cfg.UUID, test)),
+		attribute("tool", cfg.Tool),
+		attribute("termination", cfg.Termination),
+		attribute("concurrency", int(cfg.Concurrency)),
+		attribute("procs", cfg.Procs),
+		attribute("connections", cfg.Connections),
+		attribute("duration", cfg.Duration.String()),
+		attribute("warmup", cfg.Warmup),
+	)
+}
+
+// endTest ends the current test along with its open sample and phase
+func (t *tracer) endTest() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	t.endSpan(&t.sample)
+	t.endSpan(&t.test)
+}
+
+// startSample ends the previous sample and starts a new one within the current test
+func (t *tracer) startSample(sample int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	t.endSpan(&t.sample)
+	t.sample = t.newSpan(fmt.Sprintf("sample %d", sample), t.test, attribute("sample", sample))
+}
+
+// endSample ends the current sample along with its phase
+func (t *tracer) endSample() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	t.endSpan(&t.sample)
+}
+
+// startPhase ends the current phase and starts a new one within the current sample, test or run
+func (t *tracer) startPhase(phase string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	parent := t.run
+	if t.sample != nil {
+		parent = t.sample
+	} else if t.test != nil {
+		parent = t.test
+	}
+	t.phase = t.newSpan(phase, parent, attribute("phase", phase))
+}
+
+// finish ends all the open spans, flagging the run span with the given error, and exports them
+func (t *tracer) finish(err error) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endSpan(&t.phase)
+	t.endSpan(&t.sample)
+	t.endSpan(&t.test)
+	if t.run != nil && err != nil {
+		t.run.Status = spanStatus{Code: statusError, Message: err.Error()}
+	}
+	t.endSpan(&t.run)
+	if err := t.export(); err != nil {
+		log.Errorf("Error exporting traces: %v", err)
+	}
+}
+
+// export sends the recorded spans to the OTLP/HTTP endpoint, the lock must be held
+func (t *tracer) export() error {
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []spanAttribute{attribute("service.name", "ingress-perf")},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "ingress-perf"},
+						"spans": t.spans,
+					},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint returned status %d: %s", resp.StatusCode, msg)
+	}
+	log.Infof("Exported %d spans of trace %s", len(t.spans), t.traceID)
+	return nil
+}
@@ -16,26 +16,141 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-// ApplyTunning applies the given json merge patch to the default ingresscontroller CR
-// and then waits for the ingres-controller deployment reconciliation to take place
-func applyTunning(tuningPatch string) error {
-	log.Infof("Applying tuning patch to ingress controller: %v", tuningPatch)
-	_, err := dynamicClient.Resource(schema.GroupVersionResource{
-		Group:    "operator.openshift.io",
-		Version:  "v1",
-		Resource: "ingresscontrollers",
-	}).Namespace("openshift-ingress-operator").Patch(context.TODO(), "default", types.MergePatchType, []byte(tuningPatch), v1.PatchOptions{})
+const (
+	ingressOperatorNs = "openshift-ingress-operator"
+	// Annotation holding the ingresscontroller spec before applying any tuning patch
+	originalSpecAnnotation = "ingress-perf.cloud-bulldozer.io/original-spec"
+)
+
+var ingressControllerGVR = schema.GroupVersionResource{
+	Group:    "operator.openshift.io",
+	Version:  "v1",
+	Resource: "ingresscontrollers",
+}
+
+// routerRolloutTimeout maximum time the router deployment takes to roll out a tuning patch
+const routerRolloutTimeout = 5 * time.Minute
+
+// tunedController returns the name of the IngressController targeted by the scenario
+func tunedController(cfg config.Config) string {
+	if cfg.IngressController != "" {
+		return cfg.IngressController
+	}
+	return defaultIngressController
+}
+
+// ApplyTunning applies the tuning json merge patch of the scenario to its ingresscontroller CR
+// and then waits for the rollout of the router deployment to complete
+func applyTunning(cfg config.Config) error {
+	name := tunedController(cfg)
+	log.Infof("Applying tuning patch to ingress controller %s: %v", name, cfg.Tuning)
+	if err := saveOriginalSpec(name); err != nil {
+		return err
+	}
+	ic, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Patch(context.TODO(), name, types.MergePatchType, []byte(cfg.Tuning), v1.PatchOptions{})
 	if err != nil {
 		return err
 	}
-	time.Sleep(5 * time.Second) // ingress-controller operator takes some time to reconcile the deployment
-	return waitForDeployment("openshift-ingress", "router-default", 5*time.Minute)
+	return waitForRouterRollout(name, ic.GetGeneration())
+}
+
+// waitForRouterRollout waits for the ingress operator to reconcile the given generation of the ingresscontroller,
+// and for its router deployment to replace all the router pods
+func waitForRouterRollout(name string, generation int64) error {
+	deployment := "router-" + name
+	log.Infof("Waiting for the rollout of deployment %s in ns %s", deployment, routerNs)
+	return wait.PollUntilContextTimeout(context.TODO(), time.Second, routerRolloutTimeout, true, func(ctx context.Context) (bool, error) {
+		ic, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		// The deployment is only updated once the operator observes the new spec
+		if observed, found, _ := unstructured.NestedInt64(ic.Object, "status", "observedGeneration"); found && observed < generation {
+			return false, nil
+		}
+		dep, err := clientSet.AppsV1().Deployments(routerNs).Get(ctx, deployment, v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		replicas := *dep.Spec.Replicas
+		if dep.Status.ObservedGeneration < dep.Generation || dep.Status.UpdatedReplicas != replicas ||
+			dep.Status.Replicas != replicas || dep.Status.AvailableReplicas != replicas {
+			log.Debugf("%d/%d replicas of deployment %s updated", dep.Status.UpdatedReplicas, replicas, deployment)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// saveOriginalSpec stores the ingresscontroller spec in an annotation before it's tuned for the first time
+func saveOriginalSpec(name string) error {
+	ic, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Get(context.TODO(), name, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if _, ok := ic.GetAnnotations()[originalSpecAnnotation]; ok {
+		return nil
+	}
+	spec, err := json.Marshal(ic.Object["spec"])
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{originalSpecAnnotation: string(spec)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Patch(context.TODO(), name, types.MergePatchType, patch, v1.PatchOptions{})
+	return err
+}
+
+// revertTuning restores the spec of the ingresscontrollers saved before applying their first tuning patch
+func revertTuning() error {
+	icList, err := dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var reverted bool
+	for i := range icList.Items {
+		ic := &icList.Items[i]
+		annotations := ic.GetAnnotations()
+		originalSpec, ok := annotations[originalSpecAnnotation]
+		if !ok {
+			continue
+		}
+		var spec map[string]interface{}
+		if err := json.Unmarshal([]byte(originalSpec), &spec); err != nil {
+			return err
+		}
+		log.Infof("Reverting ingress controller %s tuning", ic.GetName())
+		ic.Object["spec"] = spec
+		delete(annotations, originalSpecAnnotation)
+		ic.SetAnnotations(annotations)
+		if ic, err = dynamicClient.Resource(ingressControllerGVR).Namespace(ingressOperatorNs).Update(context.TODO(), ic, v1.UpdateOptions{}); err != nil {
+			return err
+		}
+		if err := waitForRouterRollout(ic.GetName(), ic.GetGeneration()); err != nil {
+			return err
+		}
+		reverted = true
+	}
+	if !reverted {
+		log.Info("Ingress controllers weren't tuned, nothing to revert")
+	}
+	return nil
 }
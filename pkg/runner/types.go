@@ -15,9 +15,16 @@
 package runner
 
 import (
+	"crypto"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/cloud-bulldozer/go-commons/indexers"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/horreum"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/notify"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/storage"
 	routev1 "github.com/openshift/api/route/v1"
 	"istio.io/api/networking/v1beta1"
 	v1networking "istio.io/client-go/pkg/apis/networking/v1beta1"
@@ -38,24 +45,70 @@ const (
 
 type Runner struct {
 	uuid        string
-	indexer     *indexers.Indexer
+	indexer     documentIndexer
+	indexerCfg  indexers.IndexerConfig
+	spillDir    string
 	podMetrics  bool
 	cleanup     bool
 	serviceMesh bool
+	hostNetwork bool
 	igNamespace string
+	logFile     io.Closer
+
+	serviceAccount string
+	namespacedRBAC bool
+	nsLabels       map[string]string
+	nsAnnotations  map[string]string
+	seed           int64
+
+	progressInterval time.Duration
+	dashboard        time.Duration
+	metricsAddr      string
+	pprofAddr        string
+	profileDir       string
+	profileInterval  time.Duration
+	notifyURL        string
+	notifyFormat     string
+	grafanaURL       string
+	grafanaToken     string
+	otlpEndpoint     string
+	kubeBurnerDocs   bool
+	dataStream       bool
+	compress         bool
+	horreum          *horreum.Config
+	email            *notify.EmailConfig
+	events           func(tools.Event)
+	sqlStore         *storage.SQL
+	summary          tools.Summary
+	metadata         tools.ClusterMetadata
+	signingKey       crypto.Signer
 }
 
 type OptsFunctions func(r *Runner)
 
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	uuidLabel      = "ingress-perf.cloud-bulldozer.io/uuid"
+	testLabel      = "ingress-perf.cloud-bulldozer.io/test"
+	sampleLabel    = "ingress-perf.cloud-bulldozer.io/sample"
+	// heartbeatAnnotation time of the last activity of the run, updated in its namespaces at each test and sample
+	heartbeatAnnotation = "ingress-perf.cloud-bulldozer.io/heartbeat"
+)
+
+// resourceLabels labels added to all the resources created by ingress-perf
+var resourceLabels = map[string]string{
+	managedByLabel: "ingress-perf",
+}
+
 var routesNamespace = benchmarkNs.Name
 
 var benchmarkNs = corev1.Namespace{
 	ObjectMeta: metav1.ObjectMeta{
 		Name: "ingress-perf",
 		Labels: map[string]string{
-			"pod-security.kubernetes.io/warn":                "privileged",
-			"pod-security.kubernetes.io/audit":               "privileged",
-			"pod-security.kubernetes.io/enforce":             "privileged",
+			"pod-security.kubernetes.io/warn":                "restricted",
+			"pod-security.kubernetes.io/audit":               "restricted",
+			"pod-security.kubernetes.io/enforce":             "restricted",
 			"security.openshift.io/scc.podSecurityLabelSync": "false",
 		},
 	},
@@ -122,9 +175,16 @@ var server = appsv1.Deployment{
 							RunAsNonRoot:             ptr.To[bool](true),
 							SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
 						},
-						Ports: []corev1.ContainerPort{{Name: "http", Protocol: corev1.ProtocolTCP, ContainerPort: 8080}},
+						Ports:        []corev1.ContainerPort{{Name: "http", Protocol: corev1.ProtocolTCP, ContainerPort: 8080}},
+						VolumeMounts: []corev1.VolumeMount{{Name: payloadsName, MountPath: payloadsDir, ReadOnly: true}},
 					},
 				},
+				Volumes: []corev1.Volume{{
+					Name: payloadsName,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: payloadsName}},
+					},
+				}},
 			},
 		},
 	},
@@ -172,7 +232,6 @@ var client = appsv1.Deployment{
 				}},
 				Affinity:                      workerAffinity,
 				TerminationGracePeriodSeconds: ptr.To[int64](0),
-				HostNetwork:                   true, // Enable hostNetwork in client pods
 				Containers: []corev1.Container{
 					{
 						Command:         []string{"sleep", "inf"},
@@ -194,7 +253,8 @@ var client = appsv1.Deployment{
 
 var clientCRB = rbac.ClusterRoleBinding{
 	ObjectMeta: metav1.ObjectMeta{
-		Name: clientName,
+		Name:   clientName,
+		Labels: map[string]string{},
 	},
 	Subjects: []rbac.Subject{
 		{
@@ -0,0 +1,106 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// watchdog tracks the tool executions still in progress in each client pod, every pod runs procs of them,
+// so the pods with any execution that didn't complete by the sample deadline can be restarted
+type watchdog struct {
+	lock    sync.Mutex
+	pending map[string]int
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{pending: make(map[string]int)}
+}
+
+// sampleContext returns a context canceled when the sample exceeds its expected duration by watchdogMargin
+func sampleContext(cfg config.Config) (context.Context, context.CancelFunc) {
+	if cfg.WatchdogMargin == 0 {
+		return context.WithCancel(context.TODO())
+	}
+	return context.WithTimeout(context.TODO(), cfg.StartBarrier+cfg.Duration+cfg.WatchdogMargin)
+}
+
+func (w *watchdog) start(pod string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.pending[pod]++
+}
+
+func (w *watchdog) done(pod string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.pending[pod]--; w.pending[pod] <= 0 {
+		delete(w.pending, pod)
+	}
+}
+
+// hung returns the pods with any execution that didn't complete
+func (w *watchdog) hung() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	pods := make([]string, 0, len(w.pending))
+	for pod := range w.pending {
+		pods = append(pods, pod)
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+// filterPods returns the given pods with any of the given names, i.e. the hung ones
+func filterPods(pods []corev1.Pod, names []string) []corev1.Pod {
+	var filtered []corev1.Pod
+	for _, pod := range pods {
+		for _, name := range names {
+			if pod.Name == name {
+				filtered = append(filtered, pod)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// restartClientPods deletes the given client pods, stuck processes can outlive the exec session, and waits
+// for the client deployment to replace them. External clients and the pods of noExec can't be restarted
+func restartClientPods(pods []string) error {
+	if len(externalClients) > 0 || noExec {
+		return nil
+	}
+	for _, pod := range pods {
+		log.Warnf("Restarting hung client pod %s", pod)
+		err := clientCluster.CoreV1().Pods(benchmarkNs.Name).Delete(context.TODO(), pod, metav1.DeleteOptions{GracePeriodSeconds: ptr.To[int64](0)})
+		if err != nil {
+			return fmt.Errorf("error deleting hung client pod %s: %w", pod, err)
+		}
+	}
+	// Give the deployment controller some time to notice the deleted pods
+	time.Sleep(time.Second)
+	return waitForClusterDeployment(clientCluster, benchmarkNs.Name, clientName, deploymentTimeout)
+}
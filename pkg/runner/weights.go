@@ -0,0 +1,214 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	routev1 "github.com/openshift/api/route/v1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	alternateBackendLabel = "app=ingress-perf-alternate"
+	// backendResponsesPrefix prefixes the infra metrics holding the responses served by each backend service
+	backendResponsesPrefix = "backend_responses_"
+)
+
+func alternateBackend(i int) string {
+	return fmt.Sprintf("%s-alternate-%d", serverName, i)
+}
+
+// backendServices returns the services backing the benchmark routes, in the same order as the weights
+func backendServices(cfg config.Config) []string {
+	services := []string{service.Name}
+	for i := 1; i < len(cfg.BackendWeights); i++ {
+		services = append(services, alternateBackend(i))
+	}
+	return services
+}
+
+// reconcileAlternateBackends makes sure an alternate backend, a copy of the server deployment and its service,
+// exists for each weight but the first one, with the server replicas of the scenario. Unused ones are removed
+func reconcileAlternateBackends(cfg config.Config) error {
+	existing := make(map[string]bool)
+	deployments, err := clientSet.AppsV1().Deployments(benchmarkNs.Name).List(context.TODO(), metav1.ListOptions{LabelSelector: alternateBackendLabel})
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments.Items {
+		existing[d.Name] = true
+	}
+	for _, name := range backendServices(cfg)[1:] {
+		delete(existing, name)
+		if err := deployAlternateBackend(name, cfg.ServerReplicas); err != nil {
+			return err
+		}
+	}
+	for name := range existing {
+		log.Infof("Removing alternate backend %s", name)
+		err := clientSet.AppsV1().Deployments(benchmarkNs.Name).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		err = clientSet.CoreV1().Services(benchmarkNs.Name).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func deployAlternateBackend(name string, replicas int32) error {
+	labels := map[string]string{"app": "ingress-perf-alternate"}
+	for k, v := range resourceLabels {
+		labels[k] = v
+	}
+	selector := map[string]string{"app": name}
+	d := *server.DeepCopy()
+	d.Name = name
+	d.Labels = labels
+	d.Spec.Replicas = ptr.To(replicas)
+	d.Spec.Selector.MatchLabels = selector
+	d.Spec.Template.Labels = selector
+	d.Spec.Template.Spec.TopologySpreadConstraints[0].LabelSelector.MatchLabels = selector
+	current, err := clientSet.AppsV1().Deployments(benchmarkNs.Name).Get(context.TODO(), name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		log.Infof("Deploying alternate backend %s", name)
+		if _, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Create(context.TODO(), &d, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case current.Status.ReadyReplicas == replicas:
+		return nil
+	default:
+		current.Spec.Replicas = ptr.To(replicas)
+		if _, err = clientSet.AppsV1().Deployments(benchmarkNs.Name).Update(context.TODO(), current, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	svc := *service.DeepCopy()
+	svc.Name = name
+	svc.Labels = labels
+	svc.Spec.Selector = selector
+	_, err = clientSet.CoreV1().Services(benchmarkNs.Name).Create(context.TODO(), &svc, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return waitForDeployment(benchmarkNs.Name, name, deploymentTimeout)
+}
+
+// reconcileBackendWeights points the benchmark routes to the backends of the scenario with their weights, routes
+// of scenarios without backendWeights target the server service alone
+func reconcileBackendWeights(cfg config.Config) error {
+	if !bundledServer() {
+		return nil
+	}
+	if err := reconcileAlternateBackends(cfg); err != nil {
+		return err
+	}
+	to := routev1.RouteTargetReference{Kind: "Service", Name: service.Name}
+	var alternates []routev1.RouteTargetReference
+	if len(cfg.BackendWeights) > 0 {
+		to.Weight = ptr.To(int32(cfg.BackendWeights[0]))
+		for i, name := range backendServices(cfg)[1:] {
+			alternates = append(alternates, routev1.RouteTargetReference{Kind: "Service", Name: name, Weight: ptr.To(int32(cfg.BackendWeights[i+1]))})
+		}
+	}
+	names := make([]string, 0, len(routes)+1)
+	for _, route := range routes {
+		names = append(names, route.Name)
+	}
+	if shardedController(cfg) {
+		names = append(names, controllerRouteName(cfg))
+	}
+	for _, name := range names {
+		r, err := orClientSet.RouteV1().Routes(routesNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		// The API server defaults the weight of the route target to 100
+		if r.Spec.To.Name == to.Name && ptr.Deref(r.Spec.To.Weight, 100) == ptr.Deref(to.Weight, 100) &&
+			reflect.DeepEqual(r.Spec.AlternateBackends, alternates) {
+			continue
+		}
+		log.Debugf("Updating route %s backends: %s %v", r.Name, to.Name, alternates)
+		r.Spec.To = to
+		r.Spec.AlternateBackends = alternates
+		if _, err = orClientSet.RouteV1().Routes(routesNamespace).Update(context.TODO(), r, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backendQueries returns the queries of the responses, or connections with passthrough termination, served by
+// each backend of the benchmark routes
+func backendQueries(cfg config.Config) map[string]string {
+	queries := make(map[string]string)
+	if len(cfg.BackendWeights) == 0 {
+		return queries
+	}
+	metric := "haproxy_server_http_responses_total"
+	if cfg.Termination == "passthrough" {
+		metric = "haproxy_server_connections_total"
+	}
+	for _, svc := range backendServices(cfg) {
+		queries[backendResponsesPrefix+strings.ReplaceAll(svc, "-", "_")] = fmt.Sprintf(
+			"sum(increase(%s{exported_namespace='%s', service='%s'}[ELAPSED]))", metric, benchmarkNs.Name, svc)
+	}
+	return queries
+}
+
+// trafficSplit computes the share of the traffic served by each backend and its deviation from the expected one
+func trafficSplit(cfg config.Config, result *tools.Result) {
+	if len(cfg.BackendWeights) == 0 {
+		return
+	}
+	split := &tools.TrafficSplit{}
+	var totalWeight int
+	var totalResponses float64
+	for i, svc := range backendServices(cfg) {
+		totalWeight += cfg.BackendWeights[i]
+		totalResponses += result.InfraMetrics[backendResponsesPrefix+strings.ReplaceAll(svc, "-", "_")]
+	}
+	if totalResponses == 0 {
+		log.Warn("No responses found for the route backends, the traffic split can't be verified")
+		return
+	}
+	for i, svc := range backendServices(cfg) {
+		share := tools.BackendShare{
+			Service:       svc,
+			Weight:        cfg.BackendWeights[i],
+			ExpectedShare: float64(cfg.BackendWeights[i]) / float64(totalWeight),
+			ObservedShare: result.InfraMetrics[backendResponsesPrefix+strings.ReplaceAll(svc, "-", "_")] / totalResponses,
+		}
+		split.MaxDeviation = math.Max(split.MaxDeviation, math.Abs(share.ObservedShare-share.ExpectedShare))
+		split.Backends = append(split.Backends, share)
+		log.Infof("Backend %s: weight=%d expected share=%.3f observed share=%.3f", svc, share.Weight, share.ExpectedShare, share.ObservedShare)
+	}
+	result.TrafficSplit = split
+}
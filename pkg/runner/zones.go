@@ -0,0 +1,138 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clientZoneRequirement pins the client pods to a set of zones, nil when the scenario doesn't set a client placement
+var clientZoneRequirement *corev1.NodeSelectorRequirement
+
+// routerPodSelector selects the router pods of the IngressController targeted by the scenario
+func routerPodSelector(cfg config.Config) string {
+	if shardedController(cfg) {
+		return "ingresscontroller.operator.openshift.io/deployment-ingresscontroller=" + cfg.IngressController
+	}
+	return routerSelector
+}
+
+// routerZones returns the zones of the nodes running the router pods of the scenario
+func routerZones(cfg config.Config) ([]string, error) {
+	pods, err := clientSet.CoreV1().Pods(routerNs).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: routerPodSelector(cfg),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]bool)
+	for _, pod := range pods.Items {
+		nodes[pod.Spec.NodeName] = true
+	}
+	return nodeZones(clientSet, nodes)
+}
+
+// nodeZones returns the sorted zones of the given nodes of a cluster, nodes without zone label are ignored
+func nodeZones(c kubernetes.Interface, nodes map[string]bool) ([]string, error) {
+	zones := make(map[string]bool)
+	for name := range nodes {
+		node, err := c.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fetch node %s: %w", name, err)
+		}
+		if zone := node.Labels[corev1.LabelTopologyZone]; zone != "" {
+			zones[zone] = true
+		}
+	}
+	var sorted []string
+	for zone := range zones {
+		sorted = append(sorted, zone)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// reconcileClientZones pins the client pods to the zones of the router pods, or to the rest of zones, according to
+// the client placement of the scenario. It fails when no worker node satisfies the placement
+func reconcileClientZones(cfg config.Config) error {
+	clientZoneRequirement = nil
+	if cfg.ClientPlacement == "" {
+		return nil
+	}
+	if len(externalClients) > 0 {
+		return fmt.Errorf("zoneComparison can't be used with external clients")
+	}
+	zones, err := routerZones(cfg)
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("router pods aren't running in nodes labeled with %s", corev1.LabelTopologyZone)
+	}
+	operator := corev1.NodeSelectorOpIn
+	if cfg.ClientPlacement == config.CrossZone {
+		operator = corev1.NodeSelectorOpNotIn
+	}
+	requirement := corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: operator, Values: zones}
+	nodes, err := clientCluster.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "node-role.kubernetes.io/worker,!node-role.kubernetes.io/infra",
+	})
+	if err != nil {
+		return err
+	}
+	var candidates int
+	for _, node := range nodes.Items {
+		zone, ok := node.Labels[corev1.LabelTopologyZone]
+		if ok && contains(zones, zone) == (operator == corev1.NodeSelectorOpIn) {
+			candidates++
+		}
+	}
+	if candidates == 0 {
+		return fmt.Errorf("no worker nodes available for %s client placement, router zones: %v", cfg.ClientPlacement, zones)
+	}
+	log.Infof("Client placement %s, router zones: %v, candidate nodes: %d", cfg.ClientPlacement, zones, candidates)
+	clientZoneRequirement = &requirement
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// logZonePenalty logs the relative difference of the cross-zone test with the same-zone one
+func logZonePenalty(sameZone, crossZone tools.TestSummary) {
+	if sameZone.Samples == 0 || crossZone.Samples == 0 {
+		return
+	}
+	log.Infof("Cross-zone penalty: rps %+.1f%%, avg latency %+.1f%%, p99 latency %+.1f%%",
+		deltaPct(crossZone.AvgRps, sameZone.AvgRps),
+		deltaPct(crossZone.AvgLatency, sameZone.AvgLatency),
+		deltaPct(crossZone.P99Latency, sameZone.P99Latency))
+}
@@ -0,0 +1,360 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	ilog "github.com/cloud-bulldozer/ingress-perf/pkg/log"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	uid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// Interval used to poll new log lines when streaming them
+const logPollInterval = time.Second
+
+// Run holds the status of a run submitted through the API
+type Run struct {
+	UUID      string         `json:"uuid"`
+	State     State          `json:"state"`
+	Progress  string         `json:"progress,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	StartTime time.Time      `json:"startTime"`
+	EndTime   *time.Time     `json:"endTime,omitempty"`
+	Summary   *tools.Summary `json:"-"`
+	logs      bytes.Buffer
+	events    []tools.Event
+}
+
+// Server exposes an HTTP API to submit runs and query their status, logs and results.
+// The runner relies on global state, so only one run can be in progress at a time
+type Server struct {
+	lock      sync.Mutex
+	runs      map[string]*Run
+	active    *Run
+	newRunner func(uuid string) *runner.Runner
+	// token required as bearer token by every request when not empty
+	token string
+}
+
+// New returns a server, newRunner builds the runner of each submitted run. When token isn't empty, requests must
+// carry it in the Authorization header as a bearer token
+func New(newRunner func(uuid string) *runner.Runner, token string) *Server {
+	s := &Server{
+		runs:      make(map[string]*Run),
+		newRunner: newRunner,
+		token:     token,
+	}
+	log.AddHook(s)
+	return s
+}
+
+// Levels implements logrus.Hook
+func (s *Server) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook, it stores the log entries of the run in progress
+func (s *Server) Fire(entry *log.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active != nil {
+		s.active.logs.WriteString(line)
+	}
+	return nil
+}
+
+// addEvent stores an event of the run in progress
+func (s *Server) addEvent(event tools.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active != nil {
+		s.active.events = append(s.active.events, event)
+	}
+}
+
+// ListenAndServe serves the API in the given address
+func (s *Server) ListenAndServe(addr string) error {
+	log.Infof("Serving API at %s", addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil && s.token == "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			log.Warnf("Serving the API at %s without a token, anyone reaching it can submit runs against the cluster", addr)
+		}
+	}
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Handler returns the API handler:
+//
+//	POST /runs                 submits a run, the body is the configuration in YAML or JSON
+//	GET  /runs                 lists the runs
+//	GET  /runs/<uuid>          returns the status and progress of a run
+//	GET  /runs/<uuid>/logs     streams the logs of a run until it finishes
+//	GET  /runs/<uuid>/events   streams the events of a run as server-sent events until it finishes
+//	GET  /runs/<uuid>/results  returns the summary of a finished run
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runs", s.handleRuns)
+	mux.HandleFunc("/runs/", s.handleRun)
+	if s.token == "" {
+		return mux
+	}
+	return s.authenticate(mux)
+}
+
+// authenticate rejects the requests without the server token as bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *Server) handleRuns(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		s.lock.Lock()
+		runs := make([]Run, 0, len(s.runs))
+		for _, run := range s.runs {
+			runs = append(runs, s.status(run))
+		}
+		s.lock.Unlock()
+		sort.Slice(runs, func(i, j int) bool {
+			return runs[i].StartTime.Before(runs[j].StartTime)
+		})
+		writeJSON(w, http.StatusOK, runs)
+	case http.MethodPost:
+		s.submit(w, req)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) submit(w http.ResponseWriter, req *http.Request) {
+	run, code, err := s.newRun(req)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	s.lock.Lock()
+	status := s.status(run)
+	s.lock.Unlock()
+	go s.execute(run)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// newRun loads the configuration from the request and registers the run as active
+func (s *Server) newRun(req *http.Request) (*Run, int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active != nil {
+		return nil, http.StatusConflict, fmt.Errorf("run %s in progress", s.active.UUID)
+	}
+	uuid := req.URL.Query().Get("uuid")
+	if uuid == "" {
+		uuid = uid.NewV4().String()
+	}
+	if _, ok := s.runs[uuid]; ok {
+		return nil, http.StatusConflict, fmt.Errorf("run %s already exists", uuid)
+	}
+	if err := config.LoadReader(req.Body); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid configuration: %v", err)
+	}
+	run := &Run{
+		UUID:      uuid,
+		State:     StateRunning,
+		StartTime: time.Now().UTC(),
+	}
+	s.runs[uuid] = run
+	s.active = run
+	return run, 0, nil
+}
+
+func (s *Server) execute(run *Run) {
+	ilog.SetField("uuid", run.UUID)
+	log.Infof("Starting run %s", run.UUID)
+	r := s.newRunner(run.UUID)
+	runner.WithEvents(s.addEvent)(r)
+	err := r.Start()
+	summary := r.Summary()
+	now := time.Now().UTC()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	run.Summary = &summary
+	run.EndTime = &now
+	run.State = StateSucceeded
+	if err != nil {
+		run.State = StateFailed
+		run.Error = err.Error()
+	}
+	s.active = nil
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	uuid, action, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/runs/"), "/")
+	s.lock.Lock()
+	run, ok := s.runs[uuid]
+	s.lock.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("run %s not found", uuid), http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "":
+		s.lock.Lock()
+		status := s.status(run)
+		s.lock.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	case "logs":
+		s.streamLogs(w, req, run)
+	case "events":
+		s.streamEvents(w, req, run)
+	case "results":
+		s.lock.Lock()
+		summary := run.Summary
+		s.lock.Unlock()
+		if summary == nil {
+			http.Error(w, fmt.Sprintf("run %s in progress", uuid), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// status returns a copy of the run status, the server lock must be held
+func (s *Server) status(run *Run) Run {
+	status := Run{
+		UUID:      run.UUID,
+		State:     run.State,
+		Error:     run.Error,
+		StartTime: run.StartTime,
+		EndTime:   run.EndTime,
+	}
+	if run == s.active {
+		status.Progress = runner.Progress()
+	}
+	return status
+}
+
+// streamLogs writes the logs of the run as they're produced until the run finishes or the client goes away
+func (s *Server) streamLogs(w http.ResponseWriter, req *http.Request, run *Run) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	var offset int
+	for {
+		s.lock.Lock()
+		logs := run.logs.Bytes()[offset:]
+		chunk := make([]byte, len(logs))
+		copy(chunk, logs)
+		finished := run.State != StateRunning
+		s.lock.Unlock()
+		if len(chunk) > 0 {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			offset += len(chunk)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if finished {
+			return
+		}
+		select {
+		case <-time.After(logPollInterval):
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// streamEvents writes the events of the run as server-sent events, replaying the past ones first,
+// until the run finishes or the client goes away
+func (s *Server) streamEvents(w http.ResponseWriter, req *http.Request, run *Run) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	var offset int
+	for {
+		s.lock.Lock()
+		events := append([]tools.Event{}, run.events[offset:]...)
+		finished := run.State != StateRunning
+		s.lock.Unlock()
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Errorf("Error encoding event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		offset += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return
+		}
+		select {
+		case <-time.After(logPollInterval):
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Error encoding response: %v", err)
+	}
+}
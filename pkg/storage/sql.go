@@ -0,0 +1,217 @@
+// Copyright 2024 The ingress-perf Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloud-bulldozer/ingress-perf/pkg/config"
+	"github.com/cloud-bulldozer/ingress-perf/pkg/runner/tools"
+	// PostgreSQL driver
+	_ "github.com/lib/pq"
+	// SQLite driver, pure Go so the binary can still be built without CGO
+	_ "modernc.org/sqlite"
+)
+
+// sqliteScheme DSN scheme selecting the SQLite driver, followed by the database file path
+const sqliteScheme = "sqlite://"
+
+// Statements only use the SQL dialect shared by PostgreSQL and SQLite
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS runs (
+		uuid TEXT PRIMARY KEY,
+		timestamp TIMESTAMP NOT NULL,
+		version TEXT,
+		platform TEXT,
+		ocp_version TEXT,
+		k8s_version TEXT,
+		sdn_type TEXT,
+		worker_nodes INTEGER,
+		infra_nodes INTEGER,
+		haproxy_version TEXT,
+		endpoint_publishing_strategy TEXT
+	)`,
+	`CREATE TABLE IF NOT EXISTS tests (
+		uuid TEXT NOT NULL REFERENCES runs(uuid) ON DELETE CASCADE,
+		test INTEGER NOT NULL,
+		tool TEXT,
+		termination TEXT,
+		path TEXT,
+		concurrency INTEGER,
+		procs INTEGER,
+		connections INTEGER,
+		server_replicas INTEGER,
+		duration_seconds DOUBLE PRECISION,
+		tuning_patch TEXT,
+		config TEXT,
+		PRIMARY KEY (uuid, test)
+	)`,
+	`CREATE TABLE IF NOT EXISTS samples (
+		uuid TEXT NOT NULL,
+		test INTEGER NOT NULL,
+		sample INTEGER NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		rps DOUBLE PRECISION,
+		avg_lat_us DOUBLE PRECISION,
+		p90_lat_us DOUBLE PRECISION,
+		p95_lat_us DOUBLE PRECISION,
+		p99_lat_us DOUBLE PRECISION,
+		max_lat_us DOUBLE PRECISION,
+		requests BIGINT,
+		bytes BIGINT,
+		http_errors BIGINT,
+		timeouts BIGINT,
+		PRIMARY KEY (uuid, test, sample),
+		FOREIGN KEY (uuid, test) REFERENCES tests(uuid, test) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS metrics (
+		uuid TEXT NOT NULL,
+		test INTEGER NOT NULL,
+		sample INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		value DOUBLE PRECISION,
+		PRIMARY KEY (uuid, test, sample, name),
+		FOREIGN KEY (uuid, test, sample) REFERENCES samples(uuid, test, sample) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS route_propagation (
+		uuid TEXT NOT NULL,
+		test INTEGER NOT NULL,
+		avg_ms DOUBLE PRECISION,
+		p50_ms DOUBLE PRECISION,
+		p95_ms DOUBLE PRECISION,
+		p99_ms DOUBLE PRECISION,
+		max_ms DOUBLE PRECISION,
+		failures INTEGER,
+		PRIMARY KEY (uuid, test),
+		FOREIGN KEY (uuid, test) REFERENCES tests(uuid, test) ON DELETE CASCADE
+	)`,
+}
+
+// Driver returns the database driver and data source of the given DSN. sqlite://<path> DSNs use SQLite, with
+// foreign keys enabled so deletes cascade like in PostgreSQL, and any other DSN is handed to the PostgreSQL driver
+func Driver(dsn string) (string, string) {
+	if !strings.HasPrefix(dsn, sqliteScheme) {
+		return "postgres", dsn
+	}
+	path := strings.TrimPrefix(dsn, sqliteScheme)
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return "sqlite", path + sep + "_pragma=foreign_keys(1)"
+}
+
+// SQL stores the results in a relational database with one table for runs, tests, samples and metrics
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL connects to the given database and creates the schema if needed
+func NewSQL(driver, dsn string) (*SQL, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating schema: %w", err)
+		}
+	}
+	return &SQL{db: db}, nil
+}
+
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+// StoreResults stores the benchmark results of a test in a single transaction
+func (s *SQL) StoreResults(test int, cfg config.Config, results []tools.Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := storeTest(tx, test, cfg, results[0].UUID, results[0].Timestamp, results[0].Version, results[0].ClusterMetadata); err != nil {
+		return err
+	}
+	for _, r := range results {
+		_, err := tx.Exec(`INSERT INTO samples (uuid, test, sample, timestamp, rps, avg_lat_us, p90_lat_us, p95_lat_us, p99_lat_us, max_lat_us, requests, bytes, http_errors, timeouts)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) ON CONFLICT DO NOTHING`,
+			r.UUID, test, r.Sample, r.Timestamp, r.TotalAvgRps, r.AvgLatency, r.P90Latency, r.P95Latency, r.P99Latency, r.MaxLatency,
+			r.Requests, r.Bytes, r.HTTPErrors, r.Timeouts)
+		if err != nil {
+			return err
+		}
+		for name, value := range r.InfraMetrics {
+			_, err := tx.Exec(`INSERT INTO metrics (uuid, test, sample, name, value) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+				r.UUID, test, r.Sample, name, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// StorePropagation stores the route propagation result of a test
+func (s *SQL) StorePropagation(test int, cfg config.Config, result tools.PropagationResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := storeTest(tx, test, cfg, result.UUID, result.Timestamp, result.Version, result.ClusterMetadata); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO route_propagation (uuid, test, avg_ms, p50_ms, p95_ms, p99_ms, max_ms, failures)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`,
+		result.UUID, test, result.AvgLatency, result.P50Latency, result.P95Latency, result.P99Latency, result.MaxLatency, result.Failures)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// storeTest inserts the run, if it doesn't exist yet, and the test
+func storeTest(tx *sql.Tx, test int, cfg config.Config, uuid string, timestamp time.Time, version string, md tools.ClusterMetadata) error {
+	_, err := tx.Exec(`INSERT INTO runs (uuid, timestamp, version, platform, ocp_version, k8s_version, sdn_type, worker_nodes, infra_nodes, haproxy_version, endpoint_publishing_strategy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT DO NOTHING`,
+		uuid, timestamp, version, md.Platform, md.OCPVersion, md.K8SVersion, md.SDNType, md.WorkerNodesCount, md.InfraNodesCount,
+		md.HAProxyVersion, md.EndpointPublishingStrategy)
+	if err != nil {
+		return err
+	}
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO tests (uuid, test, tool, termination, path, concurrency, procs, connections, server_replicas, duration_seconds, tuning_patch, config)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT DO NOTHING`,
+		uuid, test, cfg.Tool, cfg.Termination, cfg.Path, cfg.Concurrency, cfg.Procs, cfg.Connections, cfg.ServerReplicas,
+		cfg.Duration.Seconds(), cfg.Tuning, string(cfgJSON))
+	return err
+}